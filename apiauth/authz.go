@@ -0,0 +1,105 @@
+package apiauth
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ErrForbidden is returned when the principal is not allowed to call the endpoint
+var ErrForbidden = errors.New("forbidden")
+
+// Policy is a custom authorization hook evaluated before the handler runs (e.g. resource ownership check).
+// The principal is nil for public endpoints. Errors wrapping ErrForbidden result in 403, other errors in 500.
+type Policy interface {
+	Authorize(req *http.Request, p *Principal) error
+}
+
+// PolicyFunc is a function implementing Policy
+type PolicyFunc func(req *http.Request, p *Principal) error
+
+func (f PolicyFunc) Authorize(req *http.Request, p *Principal) error {
+	return f(req, p)
+}
+
+// Requirement defines roles and scopes required to call the endpoint
+type Requirement struct {
+	Roles  []string // any of
+	Scopes []string // all of
+}
+
+// Empty reports whether there is nothing to check
+func (r Requirement) Empty() bool {
+	return len(r.Roles) == 0 && len(r.Scopes) == 0
+}
+
+// Check returns *ForbiddenError if the principal does not meet the requirement
+func (r Requirement) Check(p *Principal) error {
+	if r.Empty() {
+		return nil
+	}
+	if p == nil {
+		return &ForbiddenError{Reason: "not authenticated", Roles: r.Roles, Scopes: r.Scopes}
+	}
+	if len(r.Roles) > 0 && !slices.ContainsFunc(r.Roles, p.HasRole) {
+		return &ForbiddenError{Reason: "missing role", Roles: r.Roles}
+	}
+	var missing []string
+	for _, scope := range r.Scopes {
+		if !p.HasScope(scope) {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) > 0 {
+		return &ForbiddenError{Reason: "missing scope", Scopes: missing}
+	}
+	return nil
+}
+
+// HasRole reports whether the principal has the role
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// HasScope reports whether the principal has the scope
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// ForbiddenError describes authorization failure. It is replied as a JSON object.
+type ForbiddenError struct {
+	Reason string   // human readable reason
+	Roles  []string // required roles (any of)
+	Scopes []string // missing scopes
+}
+
+// Forbidden returns ForbiddenError with the reason, to be used by policies
+func Forbidden(reason string) error {
+	return &ForbiddenError{Reason: reason}
+}
+
+func (e *ForbiddenError) Error() string {
+	msg := ErrForbidden.Error() + ": " + e.Reason
+	if len(e.Roles) > 0 {
+		msg += " (roles: " + strings.Join(e.Roles, ", ") + ")"
+	}
+	if len(e.Scopes) > 0 {
+		msg += " (scopes: " + strings.Join(e.Scopes, ", ") + ")"
+	}
+	return msg
+}
+
+func (e *ForbiddenError) Unwrap() error {
+	return ErrForbidden
+}
+
+// ErrorBody returns the structured reply
+func (e *ForbiddenError) ErrorBody() any {
+	return struct {
+		Error          string   `json:"error"`
+		Reason         string   `json:"reason"`
+		RequiredRoles  []string `json:"requiredRoles,omitempty"`
+		RequiredScopes []string `json:"requiredScopes,omitempty"`
+	}{ErrForbidden.Error(), e.Reason, e.Roles, e.Scopes}
+}
@@ -0,0 +1,117 @@
+package basic
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/bhmj/goblocks/apiauth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	providerName = "basic"
+	defaultRealm = "api"
+)
+
+var (
+	errInvalidCredentials = errors.New("invalid user name or password")
+	errInvalidHash        = errors.New("not a bcrypt hash")
+)
+
+// User is a user name with bcrypt password hash
+type User struct {
+	Name   string   `yaml:"name" description:"User name"`
+	Hash   string   `yaml:"hash" description:"bcrypt password hash (htpasswd -B)"`
+	Roles  []string `yaml:"roles" description:"Roles granted to the user"`
+	Scopes []string `yaml:"scopes" description:"Scopes granted to the user"`
+}
+
+// Config defines HTTP Basic authentication users
+type Config struct {
+	Realm string `yaml:"realm" description:"Authentication realm" default:"api"`
+	Users []User `yaml:"users" description:"Users with bcrypt password hashes"`
+	File  string `yaml:"file" description:"htpasswd file with bcrypt hashes (user:hash per line)"`
+}
+
+// Auth checks HTTP Basic credentials against bcrypt hashes
+type Auth struct {
+	realm string
+	users map[string]User
+	dummy []byte // used for unknown users to equalize response time
+}
+
+func New(cfg Config) (*Auth, error) {
+	a := &Auth{realm: cfg.Realm, users: make(map[string]User)}
+	if a.realm == "" {
+		a.realm = defaultRealm
+	}
+	users := cfg.Users
+	if cfg.File != "" {
+		fileUsers, err := ReadFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, fileUsers...)
+	}
+	for _, u := range users {
+		if _, err := bcrypt.Cost([]byte(u.Hash)); err != nil {
+			return nil, fmt.Errorf("user %s: %w", u.Name, errInvalidHash)
+		}
+		a.users[u.Name] = u
+	}
+	a.dummy, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	return a, nil
+}
+
+// ReadFile reads users from htpasswd file
+func ReadFile(fname string) ([]User, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, fmt.Errorf("open htpasswd: %w", err)
+	}
+	defer f.Close()
+
+	var users []User
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, hash, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		users = append(users, User{Name: name, Hash: hash})
+	}
+	return users, scanner.Err() //nolint:wrapcheck
+}
+
+func (a *Auth) Authorized(req *http.Request) error {
+	_, err := a.Authenticate(req)
+	return err
+}
+
+func (a *Auth) Authenticate(req *http.Request) (*apiauth.Principal, error) {
+	name, password, ok := req.BasicAuth()
+	if !ok {
+		return nil, fmt.Errorf("%w: basic", apiauth.ErrNoCredentials)
+	}
+	user, found := a.users[name]
+	hash := []byte(user.Hash)
+	if !found {
+		hash = a.dummy
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || !found {
+		return nil, errInvalidCredentials
+	}
+	return &apiauth.Principal{Name: name, Provider: providerName, Roles: user.Roles, Scopes: user.Scopes}, nil
+}
+
+func (a *Auth) Challenge() string {
+	return fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", a.realm)
+}
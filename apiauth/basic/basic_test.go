@@ -0,0 +1,50 @@
+package basic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bhmj/goblocks/apiauth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticate(t *testing.T) {
+	a := assert.New(t)
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pa55"), bcrypt.MinCost)
+	auth, err := New(Config{Users: []User{{Name: "bob", Hash: string(hash), Roles: []string{"admin"}, Scopes: []string{"orders:read"}}}})
+	a.NoError(err)
+
+	for _, tc := range []struct {
+		name   string
+		header string
+		err    error
+	}{
+		{"valid", "Basic Ym9iOnBhNTU=", nil},                              // bob:pa55
+		{"wrong password", "Basic Ym9iOndyb25n", errInvalidCredentials},   // bob:wrong
+		{"unknown user", "Basic YWxpY2U6cGE1NQ==", errInvalidCredentials}, // alice:pa55
+		{"malformed", "Basic not-base64", apiauth.ErrNoCredentials},
+		{"other scheme", "Bearer Ym9iOnBhNTU=", apiauth.ErrNoCredentials},
+		{"no header", "", apiauth.ErrNoCredentials},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		p, err := auth.Authenticate(req)
+		if tc.err != nil {
+			a.ErrorIs(err, tc.err, tc.name)
+			a.Nil(p, tc.name)
+			continue
+		}
+		a.NoError(err, tc.name)
+		ctx := apiauth.WithPrincipal(req.Context(), p)
+		a.Equal(&apiauth.Principal{Name: "bob", Provider: "basic", Roles: []string{"admin"}, Scopes: []string{"orders:read"}}, apiauth.FromContext(ctx))
+	}
+
+	a.Equal(`Basic realm="api", charset="UTF-8"`, auth.Challenge())
+	_, err = New(Config{Users: []User{{Name: "eve", Hash: "plain"}}})
+	a.ErrorIs(err, errInvalidHash)
+}
@@ -0,0 +1,136 @@
+package cert
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/bhmj/goblocks/apiauth"
+)
+
+const providerName = "cert"
+
+var (
+	errNoCertificate = errors.New("missing or unverified client certificate")
+	errNotAllowed    = errors.New("client certificate is not allowed")
+)
+
+// Identity describes a verified client certificate
+type Identity struct {
+	CommonName     string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []string
+	IPAddresses    []string
+	Fingerprint    string // SHA-256 of the certificate (hex)
+	SerialNumber   string // hex
+	Issuer         string
+	NotAfter       time.Time
+	Certificate    *x509.Certificate
+}
+
+// NewIdentity extracts identity from the certificate
+func NewIdentity(c *x509.Certificate) *Identity {
+	sum := sha256.Sum256(c.Raw)
+	id := &Identity{
+		CommonName:     c.Subject.CommonName,
+		DNSNames:       c.DNSNames,
+		EmailAddresses: c.EmailAddresses,
+		Fingerprint:    hex.EncodeToString(sum[:]),
+		SerialNumber:   c.SerialNumber.Text(16), //nolint:mnd
+		Issuer:         c.Issuer.String(),
+		NotAfter:       c.NotAfter,
+		Certificate:    c,
+	}
+	for _, uri := range c.URIs {
+		id.URIs = append(id.URIs, uri.String())
+	}
+	for _, ip := range c.IPAddresses {
+		id.IPAddresses = append(id.IPAddresses, ip.String())
+	}
+	return id
+}
+
+// FromRequest returns identity of the verified client certificate or nil if there is none.
+func FromRequest(r *http.Request) *Identity {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return NewIdentity(r.TLS.VerifiedChains[0][0])
+}
+
+// Names returns subject CN followed by all SANs
+func (id *Identity) Names() []string {
+	var names []string
+	if id.CommonName != "" {
+		names = append(names, id.CommonName)
+	}
+	names = append(names, id.DNSNames...)
+	names = append(names, id.EmailAddresses...)
+	names = append(names, id.URIs...)
+	return append(names, id.IPAddresses...)
+}
+
+// Matches reports whether the CN or any of the SANs matches one of the patterns.
+// Patterns may contain shell-style wildcards matched within a single DNS label:
+// "*.internal.example.com" matches "api.internal.example.com" but not "a.b.internal.example.com".
+func (id *Identity) Matches(patterns []string) bool {
+	for _, name := range id.Names() {
+		for _, pattern := range patterns {
+			if strings.EqualFold(name, pattern) || matchLabels(strings.ToLower(pattern), strings.ToLower(name)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchLabels matches the name against the pattern label by label
+func matchLabels(pattern, name string) bool {
+	patternLabels, nameLabels := strings.Split(pattern, "."), strings.Split(name, ".")
+	if len(patternLabels) != len(nameLabels) {
+		return false
+	}
+	for i, label := range patternLabels {
+		if ok, _ := path.Match(label, nameLabels[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Auth authorizes requests presenting a verified client certificate
+// with CN or SAN in the allowlist (any verified certificate if the list is empty).
+type Auth struct {
+	allowed []string
+}
+
+func New(allowed ...string) *Auth {
+	return &Auth{allowed: allowed}
+}
+
+func (a *Auth) Authorized(req *http.Request) error {
+	_, err := a.Authenticate(req)
+	return err
+}
+
+func (a *Auth) Authenticate(req *http.Request) (*apiauth.Principal, error) {
+	id := FromRequest(req)
+	if id == nil {
+		return nil, fmt.Errorf("%w: %w", apiauth.ErrNoCredentials, errNoCertificate)
+	}
+	if len(a.allowed) > 0 && !id.Matches(a.allowed) {
+		return nil, errNotAllowed
+	}
+	return &apiauth.Principal{
+		Name:     id.CommonName,
+		Provider: providerName,
+		Claims:   map[string]any{"fingerprint": id.Fingerprint, "names": id.Names(), "issuer": id.Issuer},
+	}, nil
+}
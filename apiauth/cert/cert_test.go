@@ -0,0 +1,50 @@
+package cert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuth(t *testing.T) {
+	a := assert.New(t)
+
+	spiffe, _ := url.Parse("spiffe://example.org/billing")
+	c := &x509.Certificate{
+		Raw:          []byte("certificate"),
+		SerialNumber: big.NewInt(255),
+		Subject:      pkix.Name{CommonName: "worker-1"},
+		DNSNames:     []string{"worker-1.jobs.internal"},
+		URIs:         []*url.URL{spiffe},
+	}
+	id := NewIdentity(c)
+	a.Equal("ff", id.SerialNumber)
+	a.Len(id.Fingerprint, 64)
+	a.Equal([]string{"worker-1", "worker-1.jobs.internal", "spiffe://example.org/billing"}, id.Names())
+	a.True(id.Matches([]string{"*.JOBS.internal"}))
+	a.True(id.Matches([]string{"spiffe://example.org/billing"}))
+	a.False(id.Matches([]string{"worker-2", "*.web.internal"}))
+	a.False(id.Matches([]string{"*.internal"}), "wildcard matches a single label")
+
+	multi := NewIdentity(&x509.Certificate{DNSNames: []string{"a.b.example.com"}})
+	a.False(multi.Matches([]string{"*.example.com"}))
+	a.True(multi.Matches([]string{"*.*.example.com"}))
+	a.True(multi.Matches([]string{"a.b*.example.com"}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	a.ErrorIs(New().Authorized(req), errNoCertificate)
+
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{c}}}
+	a.NoError(New().Authorized(req))
+	a.NoError(New("worker-*").Authorized(req))
+	a.ErrorIs(New("admin").Authorized(req), errNotAllowed)
+
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{c}} // presented but not verified
+	a.ErrorIs(New().Authorized(req), errNoCertificate)
+}
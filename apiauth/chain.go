@@ -0,0 +1,42 @@
+package apiauth
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Chain tries the providers in order; the first one accepting the request wins.
+// Providers returning ErrNoCredentials are skipped, otherwise the first failure is reported.
+type Chain []Auth
+
+func (c Chain) Authorized(req *http.Request) error {
+	_, err := c.Authenticate(req)
+	return err
+}
+
+func (c Chain) Authenticate(req *http.Request) (*Principal, error) {
+	var firstErr error
+	for _, a := range c {
+		p, err := Authenticate(a, req)
+		if err == nil {
+			return p, nil
+		}
+		if firstErr == nil && !errors.Is(err, ErrNoCredentials) {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = ErrNoCredentials
+	}
+	return nil, firstErr
+}
+
+// Challenge returns challenge of the first provider having one
+func (c Chain) Challenge() string {
+	for _, a := range c {
+		if challenger, ok := a.(Challenger); ok {
+			return challenger.Challenge()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package apiauth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bhmj/goblocks/apiauth"
+	"github.com/bhmj/goblocks/apiauth/basic"
+	"github.com/bhmj/goblocks/apiauth/token"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestChain(t *testing.T) {
+	a := assert.New(t)
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pa55"), bcrypt.MinCost)
+	htpasswd := filepath.Join(t.TempDir(), "htpasswd")
+	a.NoError(os.WriteFile(htpasswd, []byte("# users\nbob:"+string(hash)+"\n"), 0o600))
+	basicAuth, err := basic.New(basic.Config{Realm: "api", File: htpasswd})
+	a.NoError(err)
+
+	chain := apiauth.Chain{
+		token.NewNamed(token.Named{Name: "ci", Token: "t1"}, token.Named{Name: "deploy", Token: "t2"}),
+		basicAuth,
+	}
+	a.Equal(`Basic realm="api", charset="UTF-8"`, chain.Challenge())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err = chain.Authenticate(req)
+	a.ErrorIs(err, apiauth.ErrNoCredentials)
+
+	req.Header.Set("Api-Token", "t2")
+	p, err := chain.Authenticate(req)
+	a.NoError(err)
+	a.Equal(&apiauth.Principal{Name: "deploy", Provider: "token"}, p)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("bob", "pa55")
+	p, err = chain.Authenticate(req)
+	a.NoError(err)
+	a.Equal("bob", p.Name)
+
+	// invalid credentials of one provider are reported even if the other finds none
+	req.SetBasicAuth("bob", "wrong")
+	_, err = chain.Authenticate(req)
+	a.Error(err)
+	a.NotErrorIs(err, apiauth.ErrNoCredentials)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Api-Token", "t3")
+	a.Error(chain.Authorized(req))
+
+	ctx := apiauth.WithPrincipal(req.Context(), p)
+	a.Equal(p, apiauth.FromContext(ctx))
+	a.Nil(apiauth.FromContext(req.Context()))
+}
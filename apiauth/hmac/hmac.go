@@ -0,0 +1,230 @@
+package hmac
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bhmj/goblocks/apiauth"
+)
+
+const (
+	providerName = "hmac"
+
+	HeaderKey       = "X-Auth-Key"
+	HeaderTimestamp = "X-Auth-Timestamp"
+	HeaderNonce     = "X-Auth-Nonce"
+	HeaderSignature = "X-Auth-Signature"
+
+	defaultWindow      = 5 * time.Minute
+	defaultMaxBodySize = 10 << 20
+	nonceSize          = 16
+)
+
+var (
+	errUnknownKey     = errors.New("unknown signing key")
+	errTimestamp      = errors.New("request timestamp is out of the allowed window")
+	errReplay         = errors.New("nonce already used")
+	errSignature      = errors.New("invalid request signature")
+	errBodyTooLarge   = errors.New("request body too large to verify")
+	errMissingHeaders = errors.New("missing signature headers")
+)
+
+// Key is a shared signing secret identified by key ID
+type Key struct {
+	ID     string   `yaml:"id" description:"Key ID (principal name)"`
+	Secret string   `yaml:"secret" description:"Shared secret"`
+	Roles  []string `yaml:"roles" description:"Roles granted to the key"`
+	Scopes []string `yaml:"scopes" description:"Scopes granted to the key"`
+}
+
+// Config defines HMAC request signing verification
+type Config struct {
+	Keys        []Key         `yaml:"keys" description:"Signing keys"`
+	Window      time.Duration `yaml:"window" description:"Maximum request timestamp deviation" default:"5m"`
+	MaxBodySize int64         `yaml:"maxBodySize" description:"Maximum size of signed request body" default:"10485760"`
+}
+
+// NonceStore remembers used nonces. Use returns false if the nonce was already used.
+// Nonces older than the timestamp window are rejected anyway, so they may be forgotten after expires.
+type NonceStore interface {
+	Use(nonce string, expires time.Time) bool
+}
+
+// Auth verifies HMAC-SHA256 request signatures.
+//
+// The signature is hex(HMAC-SHA256(secret, canonical)) where canonical is
+//
+//	METHOD \n request URI \n timestamp \n nonce \n hex(SHA256(body))
+//
+// passed in X-Auth-Signature header along with X-Auth-Key, X-Auth-Timestamp (unix seconds) and X-Auth-Nonce.
+type Auth struct {
+	keys        map[string]Key
+	window      time.Duration
+	maxBodySize int64
+	nonces      NonceStore
+	now         func() time.Time
+}
+
+// New returns HMAC provider. A nil nonce store means in-memory store.
+func New(cfg Config, nonces NonceStore) *Auth {
+	a := &Auth{
+		keys:        make(map[string]Key),
+		window:      cfg.Window,
+		maxBodySize: cfg.MaxBodySize,
+		nonces:      nonces,
+		now:         time.Now,
+	}
+	if a.window <= 0 {
+		a.window = defaultWindow
+	}
+	if a.maxBodySize <= 0 {
+		a.maxBodySize = defaultMaxBodySize
+	}
+	if a.nonces == nil {
+		a.nonces = NewMemoryNonceStore()
+	}
+	for _, k := range cfg.Keys {
+		a.keys[k.ID] = k
+	}
+	return a
+}
+
+func (a *Auth) Authorized(req *http.Request) error {
+	_, err := a.Authenticate(req)
+	return err
+}
+
+func (a *Auth) Authenticate(req *http.Request) (*apiauth.Principal, error) {
+	keyID := req.Header.Get(HeaderKey)
+	signature := req.Header.Get(HeaderSignature)
+	if keyID == "" && signature == "" {
+		return nil, fmt.Errorf("%w: request signature", apiauth.ErrNoCredentials)
+	}
+	timestamp := req.Header.Get(HeaderTimestamp)
+	nonce := req.Header.Get(HeaderNonce)
+	if keyID == "" || signature == "" || timestamp == "" || nonce == "" {
+		return nil, errMissingHeaders
+	}
+	key, found := a.keys[keyID]
+	if !found {
+		return nil, errUnknownKey
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, errTimestamp
+	}
+	now := a.now()
+	if d := now.Sub(time.Unix(ts, 0)); d > a.window || d < -a.window {
+		return nil, errTimestamp
+	}
+
+	bodyHash, err := hashBody(req, a.maxBodySize)
+	if err != nil {
+		return nil, err
+	}
+	expected := sign([]byte(key.Secret), canonical(req, timestamp, nonce, bodyHash))
+	sig, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, expected) {
+		return nil, errSignature
+	}
+
+	// the nonce is consumed only by correctly signed requests
+	if !a.nonces.Use(keyID+":"+nonce, now.Add(2*a.window)) { //nolint:mnd
+		return nil, errReplay
+	}
+
+	return &apiauth.Principal{Name: keyID, Provider: providerName, Roles: key.Roles, Scopes: key.Scopes}, nil
+}
+
+// Sign adds signature headers to the request (client side).
+func Sign(req *http.Request, keyID, secret string) error {
+	bodyHash, err := hashBody(req, -1)
+	if err != nil {
+		return err
+	}
+	nonceBytes := make([]byte, nonceSize)
+	_, _ = rand.Read(nonceBytes)
+	nonce := hex.EncodeToString(nonceBytes)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set(HeaderKey, keyID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, hex.EncodeToString(sign([]byte(secret), canonical(req, timestamp, nonce, bodyHash))))
+	return nil
+}
+
+func canonical(req *http.Request, timestamp, nonce, bodyHash string) string {
+	return strings.Join([]string{req.Method, req.URL.RequestURI(), timestamp, nonce, bodyHash}, "\n")
+}
+
+func sign(secret []byte, data string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// hashBody reads the body (restoring it for the handler) and returns its hex SHA-256. Negative limit means no limit.
+func hashBody(req *http.Request, limit int64) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		sum := sha256.Sum256(nil)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	var reader io.Reader = req.Body
+	if limit >= 0 {
+		reader = io.LimitReader(req.Body, limit+1)
+	}
+	body, err := io.ReadAll(reader)
+	req.Body.Close()
+	if err != nil {
+		return "", fmt.Errorf("read body: %w", err)
+	}
+	if limit >= 0 && int64(len(body)) > limit {
+		return "", errBodyTooLarge
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// MemoryNonceStore is an in-process NonceStore
+type MemoryNonceStore struct {
+	sync.Mutex
+	nonces    map[string]time.Time
+	lastClean time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+func (s *MemoryNonceStore) Use(nonce string, expires time.Time) bool {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastClean) > time.Minute {
+		for n, exp := range s.nonces {
+			if now.After(exp) {
+				delete(s.nonces, n)
+			}
+		}
+		s.lastClean = now
+	}
+	if exp, found := s.nonces[nonce]; found && now.Before(exp) {
+		return false
+	}
+	s.nonces[nonce] = expires
+	return true
+}
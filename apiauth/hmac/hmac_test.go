@@ -0,0 +1,77 @@
+package hmac
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignature(t *testing.T) {
+	a := assert.New(t)
+
+	auth := New(Config{Keys: []Key{{ID: "client1", Secret: "k1"}}, MaxBodySize: 100}, nil)
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/orders?x=1", strings.NewReader(body))
+		a.NoError(Sign(req, "client1", "k1"))
+		return req
+	}
+
+	req := newRequest(`{"id":1}`)
+	p, err := auth.Authenticate(req)
+	a.NoError(err)
+	a.Equal("client1", p.Name)
+	body, _ := io.ReadAll(req.Body)
+	a.Equal(`{"id":1}`, string(body), "body is restored for the handler")
+
+	// form body
+	form := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader("a=1&b=2"))
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	a.NoError(Sign(form, "client1", "k1"))
+	_, err = auth.Authenticate(form)
+	a.NoError(err)
+	a.NoError(form.ParseForm())
+	a.Equal("2", form.PostForm.Get("b"), "form is parsed from the restored body")
+
+	// replay
+	req.Body = io.NopCloser(strings.NewReader(`{"id":1}`))
+	_, err = auth.Authenticate(req)
+	a.ErrorIs(err, errReplay)
+
+	// tampered body
+	req = newRequest(`{"id":1}`)
+	req.Body = io.NopCloser(strings.NewReader(`{"id":2}`))
+	_, err = auth.Authenticate(req)
+	a.ErrorIs(err, errSignature)
+
+	// tampered path
+	req = newRequest("")
+	req.URL.RawQuery = "x=2"
+	_, err = auth.Authenticate(req)
+	a.ErrorIs(err, errSignature)
+
+	// stale timestamp
+	req = newRequest("")
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+	_, err = auth.Authenticate(req)
+	a.ErrorIs(err, errTimestamp)
+
+	// unknown key
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	a.NoError(Sign(req, "client2", "k1"))
+	_, err = auth.Authenticate(req)
+	a.ErrorIs(err, errUnknownKey)
+
+	// large body
+	_, err = auth.Authenticate(newRequest(strings.Repeat("x", 101)))
+	a.ErrorIs(err, errBodyTooLarge)
+
+	// not signed
+	_, err = auth.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+	a.ErrorContains(err, "no credentials")
+}
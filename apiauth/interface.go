@@ -1,8 +1,76 @@
 package apiauth
 
-import "net/http"
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/bhmj/goblocks/log"
+)
+
+// ErrNoCredentials is returned by providers when the request carries no credentials of their kind,
+// so the next provider of a chain may try.
+var ErrNoCredentials = errors.New("no credentials")
 
 // Auth is authentication provider
 type Auth interface {
 	Authorized(req *http.Request) error
 }
+
+// Authenticator is authentication provider which identifies the caller
+type Authenticator interface {
+	Authenticate(req *http.Request) (*Principal, error)
+}
+
+// Challenger provides WWW-Authenticate header value for unauthenticated requests
+type Challenger interface {
+	Challenge() string
+}
+
+// Principal is an authenticated caller
+type Principal struct {
+	Name     string         // user name, token name, key ID, certificate CN or JWT subject
+	Provider string         // provider which authenticated the request
+	Roles    []string       // roles granted to the principal
+	Scopes   []string       // scopes granted to the principal (e.g. OAuth2 token scopes)
+	Claims   map[string]any // provider-specific attributes (e.g. JWT claims)
+}
+
+type contextKey string
+
+const contextPrincipal contextKey = "principal"
+
+// Authenticate authenticates the request with the provider. Providers not implementing
+// Authenticator produce an anonymous principal.
+func Authenticate(a Auth, req *http.Request) (*Principal, error) {
+	if authenticator, ok := a.(Authenticator); ok {
+		return authenticator.Authenticate(req)
+	}
+	if err := a.Authorized(req); err != nil {
+		return nil, err
+	}
+	return &Principal{}, nil
+}
+
+// WithPrincipal returns context carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextPrincipal, p)
+}
+
+// FromContext returns the authenticated principal or nil
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(contextPrincipal).(*Principal)
+	return p
+}
+
+func init() { //nolint:gochecknoinits
+	log.RegisterContextFields(logFields)
+}
+
+// logFields returns the principal of the context named as in httpserver request logs
+func logFields(ctx context.Context) []log.Field {
+	if p := FromContext(ctx); p != nil && p.Name != "" {
+		return []log.Field{log.String("principal", p.Name), log.String("auth", p.Provider)}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bhmj/goblocks/apiauth"
+)
+
+const providerName = "jwt"
+
+var (
+	errMalformedToken   = errors.New("malformed token")
+	errAlgorithm        = errors.New("algorithm not allowed")
+	errSignature        = errors.New("invalid signature")
+	errNoKey            = errors.New("no matching key")
+	errExpired          = errors.New("token expired")
+	errNotYetValid      = errors.New("token not valid yet")
+	errIssuer           = errors.New("invalid issuer")
+	errAudience         = errors.New("invalid audience")
+	errNoKeySource      = errors.New("no key source configured (secret, publicKeyFile, jwksFile or jwksURL)")
+	errUnsupportedKey   = errors.New("unsupported key type")
+	errMissingExpiresAt = errors.New("token has no expiration time")
+)
+
+// Config defines JWT bearer token validation
+type Config struct {
+	Secret        string        `yaml:"secret" description:"HMAC secret (HS256, HS384, HS512)"`
+	PublicKeyFile string        `yaml:"publicKeyFile" description:"PEM public key or certificate (RS*, PS*, ES*)"`
+	JWKSFile      string        `yaml:"jwksFile" description:"JWKS file"`                                           //nolint:tagliatelle
+	JWKSURL       string        `yaml:"jwksURL" description:"JWKS URL (e.g. https://issuer/.well-known/jwks.json)"` //nolint:tagliatelle
+	JWKSRefresh   time.Duration `yaml:"jwksRefresh" description:"JWKS cache lifetime" default:"1h"`                 //nolint:tagliatelle
+	Issuer        string        `yaml:"issuer" description:"Required issuer (iss)"`
+	Audience      string        `yaml:"audience" description:"Required audience (aud)"`
+	Algorithms    []string      `yaml:"algorithms" description:"Allowed algorithms (all supported by the keys if empty)"`
+	Leeway        time.Duration `yaml:"leeway" description:"Allowed clock skew" default:"1m"`
+	RequireExpiry bool          `yaml:"requireExpiry" description:"Reject tokens without exp claim"`
+	RolesClaim    string        `yaml:"rolesClaim" description:"Claim containing roles (array or space separated string)" default:"roles"`
+	ScopesClaim   string        `yaml:"scopesClaim" description:"Claim containing scopes (array or space separated string)" default:"scope"`
+}
+
+// Auth validates JWT passed in "Authorization: Bearer" header
+type Auth struct {
+	cfg  Config
+	keys *keySet
+	now  func() time.Time
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func New(cfg Config) (*Auth, error) {
+	keys, err := newKeySet(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+	if cfg.ScopesClaim == "" {
+		cfg.ScopesClaim = "scope"
+	}
+	return &Auth{cfg: cfg, keys: keys, now: time.Now}, nil
+}
+
+func (a *Auth) Authorized(req *http.Request) error {
+	_, err := a.Authenticate(req)
+	return err
+}
+
+func (a *Auth) Authenticate(req *http.Request) (*apiauth.Principal, error) {
+	token, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !found || strings.Count(token, ".") != 2 { //nolint:mnd
+		return nil, fmt.Errorf("%w: bearer JWT", apiauth.ErrNoCredentials)
+	}
+	claims, err := a.Verify(req.Context(), strings.TrimSpace(token))
+	if err != nil {
+		return nil, err
+	}
+	sub, _ := claims["sub"].(string)
+	return &apiauth.Principal{
+		Name:     sub,
+		Provider: providerName,
+		Roles:    stringList(claims[a.cfg.RolesClaim]),
+		Scopes:   stringList(claims[a.cfg.ScopesClaim]),
+		Claims:   claims,
+	}, nil
+}
+
+func (a *Auth) Challenge() string {
+	return "Bearer"
+}
+
+// Verify checks the token signature and registered claims and returns the claims
+func (a *Auth) Verify(ctx context.Context, token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 { //nolint:mnd
+		return nil, errMalformedToken
+	}
+	var hdr header
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, err
+	}
+	if !a.algorithmAllowed(hdr.Alg) {
+		return nil, fmt.Errorf("%w: %s", errAlgorithm, hdr.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errMalformedToken
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	candidates, err := a.keys.lookup(ctx, hdr.Kid, hdr.Alg)
+	if err != nil {
+		return nil, err
+	}
+	verified := false
+	for _, key := range candidates {
+		if verify(hdr.Alg, key, signed, sig) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errSignature
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := a.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (a *Auth) algorithmAllowed(alg string) bool {
+	if _, found := algorithms[alg]; !found {
+		return false // includes "none"
+	}
+	return len(a.cfg.Algorithms) == 0 || slices.Contains(a.cfg.Algorithms, alg)
+}
+
+func (a *Auth) validateClaims(claims map[string]any) error {
+	now := a.now()
+	if exp, ok := numericDate(claims["exp"]); ok {
+		if now.After(exp.Add(a.cfg.Leeway)) {
+			return errExpired
+		}
+	} else if a.cfg.RequireExpiry {
+		return errMissingExpiresAt
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(a.cfg.Leeway).Before(nbf) {
+		return errNotYetValid
+	}
+	if a.cfg.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != a.cfg.Issuer {
+			return errIssuer
+		}
+	}
+	if a.cfg.Audience != "" && !hasAudience(claims["aud"], a.cfg.Audience) {
+		return errAudience
+	}
+	return nil
+}
+
+// stringList converts array or space separated string claim to a list
+func stringList(v any) []string {
+	switch val := v.(type) {
+	case string:
+		return strings.Fields(val)
+	case []any:
+		list := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func numericDate(v any) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+func hasAudience(aud any, expected string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == expected
+	case []any:
+		for _, item := range v {
+			if s, _ := item.(string); s == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errMalformedToken
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errMalformedToken
+	}
+	return nil
+}
+
+type algorithm struct {
+	hash crypto.Hash
+	kind string // HS, RS, PS, ES
+}
+
+var algorithms = map[string]algorithm{ //nolint:gochecknoglobals
+	"HS256": {crypto.SHA256, "HS"},
+	"HS384": {crypto.SHA384, "HS"},
+	"HS512": {crypto.SHA512, "HS"},
+	"RS256": {crypto.SHA256, "RS"},
+	"RS384": {crypto.SHA384, "RS"},
+	"RS512": {crypto.SHA512, "RS"},
+	"PS256": {crypto.SHA256, "PS"},
+	"PS384": {crypto.SHA384, "PS"},
+	"PS512": {crypto.SHA512, "PS"},
+	"ES256": {crypto.SHA256, "ES"},
+	"ES384": {crypto.SHA384, "ES"},
+	"ES512": {crypto.SHA512, "ES"},
+}
+
+var esCurveBits = map[string]int{"ES256": 256, "ES384": 384, "ES512": 521} //nolint:gochecknoglobals
+
+// keyFits reports whether the key type matches the algorithm
+func keyFits(alg string, key any) bool {
+	switch algorithms[alg].kind {
+	case "HS":
+		_, ok := key.([]byte)
+		return ok
+	case "RS", "PS":
+		_, ok := key.(*rsa.PublicKey)
+		return ok
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		return ok && k.Curve.Params().BitSize == esCurveBits[alg]
+	}
+	return false
+}
+
+func verify(alg string, key any, signed, sig []byte) error {
+	a := algorithms[alg]
+	if a.kind == "HS" {
+		mac := hmac.New(a.hash.New, key.([]byte)) //nolint:forcetypeassert
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errSignature
+		}
+		return nil
+	}
+
+	h := a.hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if a.kind == "PS" {
+			return rsa.VerifyPSS(k, a.hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) //nolint:wrapcheck
+		}
+		return rsa.VerifyPKCS1v15(k, a.hash, digest, sig) //nolint:wrapcheck
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8 //nolint:mnd
+		if len(sig) != 2*size {
+			return errSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errSignature
+		}
+		return nil
+	}
+	return errUnsupportedKey
+}
@@ -0,0 +1,186 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHS256(t *testing.T) {
+	a := assert.New(t)
+
+	auth, err := New(Config{Secret: "s3cret", Issuer: "goblocks", Audience: "api", Leeway: time.Minute})
+	a.NoError(err)
+
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	token, err := Sign(map[string]any{
+		"sub": "alice", "iss": "goblocks", "aud": []string{"web", "api"}, "exp": exp,
+		"roles": []string{"admin"}, "scope": "orders:read orders:write",
+	}, "HS256", "", []byte("s3cret"))
+	a.NoError(err)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	p, err := auth.Authenticate(req)
+	a.NoError(err)
+	a.Equal("alice", p.Name)
+	a.Equal("jwt", p.Provider)
+	a.Equal([]string{"admin"}, p.Roles)
+	a.Equal([]string{"orders:read", "orders:write"}, p.Scopes)
+
+	for name, tc := range map[string]struct {
+		claims map[string]any
+		key    []byte
+		err    error
+	}{
+		"wrong key": {map[string]any{"iss": "goblocks", "aud": "api", "exp": exp}, []byte("other"), errSignature},
+		"expired":   {map[string]any{"iss": "goblocks", "aud": "api", "exp": float64(time.Now().Add(-time.Hour).Unix())}, []byte("s3cret"), errExpired},
+		"nbf":       {map[string]any{"iss": "goblocks", "aud": "api", "nbf": float64(time.Now().Add(time.Hour).Unix())}, []byte("s3cret"), errNotYetValid},
+		"issuer":    {map[string]any{"iss": "evil", "aud": "api", "exp": exp}, []byte("s3cret"), errIssuer},
+		"audience":  {map[string]any{"iss": "goblocks", "aud": "web", "exp": exp}, []byte("s3cret"), errAudience},
+	} {
+		token, err := Sign(tc.claims, "HS256", "", tc.key)
+		a.NoError(err)
+		_, err = auth.Verify(context.Background(), token)
+		a.ErrorIs(err, tc.err, name)
+	}
+
+	// alg=none must be rejected
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"x"}`)) + "."
+	_, err = auth.Verify(context.Background(), none)
+	a.ErrorIs(err, errAlgorithm)
+
+	// no bearer token: let the next provider try
+	_, err = auth.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+	a.ErrorContains(err, "no credentials")
+}
+
+func TestJWKS(t *testing.T) {
+	a := assert.New(t)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "EC", "kid": "ec1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "RSA", "kid": "rsa1", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+	}})
+
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches++
+		_, _ = w.Write(jwks)
+	}))
+	defer server.Close()
+
+	auth, err := New(Config{JWKSURL: server.URL, JWKSRefresh: time.Hour})
+	a.NoError(err)
+	claims := map[string]any{"sub": "svc", "exp": float64(time.Now().Add(time.Hour).Unix())}
+	for _, tc := range []struct {
+		alg, kid string
+		key      any
+	}{{"ES256", "ec1", ecKey}, {"RS256", "rsa1", rsaKey}, {"PS256", "rsa1", rsaKey}} {
+		token, err := Sign(claims, tc.alg, tc.kid, tc.key)
+		a.NoError(err)
+		got, err := auth.Verify(context.Background(), token)
+		a.NoError(err, tc.alg)
+		a.Equal("svc", got["sub"])
+	}
+	a.Equal(1, fetches, "JWKS is cached")
+
+	// key of the wrong type for the algorithm
+	token, _ := Sign(claims, "HS256", "rsa1", []byte("guess"))
+	_, err = auth.Verify(context.Background(), token)
+	a.ErrorIs(err, errNoKey)
+
+	// PEM public key file
+	der, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	fname := filepath.Join(t.TempDir(), "key.pem")
+	a.NoError(os.WriteFile(fname, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+	auth, err = New(Config{PublicKeyFile: fname, Algorithms: []string{"RS256"}})
+	a.NoError(err)
+	token, _ = Sign(claims, "RS256", "", rsaKey)
+	_, err = auth.Verify(context.Background(), token)
+	a.NoError(err)
+	token, _ = Sign(claims, "PS256", "", rsaKey)
+	_, err = auth.Verify(context.Background(), token)
+	a.ErrorIs(err, errAlgorithm)
+}
+
+func TestMixedJWKS(t *testing.T) {
+	a := assert.New(t)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	okp := map[string]string{"kty": "OKP", "kid": "ed1", "crv": "Ed25519", "x": b64(make([]byte, 32))}
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		okp,
+		{"kty": "RSA", "kid": "rsa1", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec1", "crv": "secp256k1", "x": b64(make([]byte, 32)), "y": b64(make([]byte, 32))},
+	}})
+	keys, err := parseJWKS(jwks)
+	a.NoError(err)
+	a.Len(keys, 1)
+	a.Equal("rsa1", keys[0].kid)
+
+	fname := filepath.Join(t.TempDir(), "jwks.json")
+	a.NoError(os.WriteFile(fname, jwks, 0o600))
+	auth, err := New(Config{JWKSFile: fname})
+	a.NoError(err)
+	token, _ := Sign(map[string]any{"sub": "svc", "exp": float64(time.Now().Add(time.Hour).Unix())}, "RS256", "rsa1", rsaKey)
+	_, err = auth.Verify(context.Background(), token)
+	a.NoError(err)
+
+	only, _ := json.Marshal(map[string]any{"keys": []map[string]string{okp}})
+	_, err = parseJWKS(only)
+	a.ErrorIs(err, errUnsupportedKey, "no usable key")
+}
+
+func TestJWKSUnavailable(t *testing.T) {
+	a := assert.New(t)
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		<-release
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	auth, err := New(Config{Secret: "s3cret", JWKSURL: server.URL})
+	a.NoError(err)
+	claims := map[string]any{"sub": "svc", "exp": float64(time.Now().Add(time.Hour).Unix())}
+	token, _ := Sign(claims, "HS256", "", []byte("s3cret"))
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := auth.Verify(context.Background(), token)
+			a.NoError(err, "static key matches while JWKS is down")
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	a.Equal(int32(1), fetches.Load(), "concurrent requests share one fetch")
+}
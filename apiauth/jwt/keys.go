@@ -0,0 +1,278 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	jwksFetchTimeout    = 10 * time.Second
+	jwksMinRefetch      = time.Minute // on unknown kid, refetch no more often than this
+	jwksMaxResponseSize = 1 << 20
+	defaultJWKSRefresh  = time.Hour
+)
+
+// jsonWebKey is a JWK (RFC 7517) subset
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+type keyEntry struct {
+	kid string
+	key any // []byte, *rsa.PublicKey or *ecdsa.PublicKey
+}
+
+// keySet holds static keys and caches remote JWKS
+type keySet struct {
+	static  []keyEntry
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	group   singleflight.Group
+	mu      sync.Mutex // guards remote and fetched
+	remote  []keyEntry
+	fetched time.Time
+}
+
+func newKeySet(cfg Config) (*keySet, error) {
+	ks := &keySet{url: cfg.JWKSURL, refresh: cfg.JWKSRefresh, client: &http.Client{Timeout: jwksFetchTimeout}}
+	if ks.refresh <= 0 {
+		ks.refresh = defaultJWKSRefresh
+	}
+	if cfg.Secret != "" {
+		ks.static = append(ks.static, keyEntry{key: []byte(cfg.Secret)})
+	}
+	if cfg.PublicKeyFile != "" {
+		key, err := readPublicKey(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		ks.static = append(ks.static, keyEntry{key: key})
+	}
+	if cfg.JWKSFile != "" {
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("read JWKS: %w", err)
+		}
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, err
+		}
+		ks.static = append(ks.static, keys...)
+	}
+	if len(ks.static) == 0 && ks.url == "" {
+		return nil, errNoKeySource
+	}
+	return ks, nil
+}
+
+// lookup returns keys matching the key ID (if any) and fitting the algorithm
+func (ks *keySet) lookup(ctx context.Context, kid, alg string) ([]any, error) {
+	keys := ks.match(ks.static, kid, alg)
+	if ks.url == "" {
+		if len(keys) == 0 {
+			return nil, errNoKey
+		}
+		return keys, nil
+	}
+
+	ks.mu.Lock()
+	remote := ks.match(ks.remote, kid, alg)
+	refetch := time.Since(ks.fetched) > ks.refresh || (len(remote) == 0 && len(keys) == 0 && time.Since(ks.fetched) > jwksMinRefetch)
+	ks.mu.Unlock()
+	if refetch {
+		// a single fetch for concurrent requests, not bound to the request that started it
+		_, err, _ := ks.group.Do("jwks", func() (any, error) {
+			return nil, ks.fetch(context.WithoutCancel(ctx))
+		})
+		ks.mu.Lock()
+		remote = ks.match(ks.remote, kid, alg)
+		ks.mu.Unlock()
+		if err != nil && len(keys) == 0 && len(remote) == 0 {
+			return nil, err
+		}
+	}
+	keys = append(keys, remote...)
+	if len(keys) == 0 {
+		return nil, errNoKey
+	}
+	return keys, nil
+}
+
+func (ks *keySet) match(entries []keyEntry, kid, alg string) []any {
+	var keys []any
+	for _, e := range entries {
+		if kid != "" && e.kid != "" && e.kid != kid {
+			continue
+		}
+		if keyFits(alg, e.key) {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys
+}
+
+// fetch downloads JWKS; the previous keys are kept on error
+func (ks *keySet) fetch(ctx context.Context) error {
+	ks.mu.Lock()
+	ks.fetched = time.Now()
+	ks.mu.Unlock()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.url, nil)
+	if err != nil {
+		return fmt.Errorf("JWKS request: %w", err)
+	}
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch JWKS: %s", resp.Status) //nolint:err113
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, jwksMaxResponseSize))
+	if err != nil {
+		return fmt.Errorf("read JWKS: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	ks.mu.Lock()
+	ks.remote = keys
+	ks.mu.Unlock()
+	return nil
+}
+
+func parseJWKS(data []byte) ([]keyEntry, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+	var keys []keyEntry
+	unsupported := 0
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if errors.Is(err, errUnsupportedKey) { // sets often mix key types (e.g. OKP), use the supported ones
+			unsupported++
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("JWK %s: %w", jwk.Kid, err)
+		}
+		keys = append(keys, keyEntry{kid: jwk.Kid, key: key})
+	}
+	if len(keys) == 0 && unsupported > 0 {
+		return nil, fmt.Errorf("%w: no usable keys in JWKS", errUnsupportedKey)
+	}
+	return keys, nil
+}
+
+func (jwk *jsonWebKey) publicKey() (any, error) {
+	switch jwk.Kty {
+	case "oct":
+		return decodeB64(jwk.K)
+	case "RSA":
+		n, err := decodeB64(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeB64(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: curve %s", errUnsupportedKey, jwk.Crv)
+		}
+		x, err := decodeB64(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeB64(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", errUnsupportedKey, jwk.Kty)
+}
+
+func decodeB64(s string) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %w", err)
+	}
+	return data, nil
+}
+
+// readPublicKey reads PEM public key or certificate
+func readPublicKey(fname string) (any, error) {
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, fmt.Errorf("read public key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s is not PEM", errUnsupportedKey, fname)
+	}
+	var key any
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+		key = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+	return nil, errUnsupportedKey
+}
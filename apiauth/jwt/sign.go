@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// Sign creates a signed token. The key is []byte for HS*, *rsa.PrivateKey for RS* and PS*,
+// *ecdsa.PrivateKey for ES* algorithms.
+func Sign(claims map[string]any, alg, kid string, key any) (string, error) {
+	a, found := algorithms[alg]
+	if !found {
+		return "", fmt.Errorf("%w: %s", errAlgorithm, alg)
+	}
+	hdr := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		hdr["kid"] = kid
+	}
+	hdrJSON, err := json.Marshal(hdr)
+	if err != nil {
+		return "", fmt.Errorf("marshal header: %w", err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("marshal claims: %w", err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(hdrJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		if a.kind != "HS" {
+			return "", errUnsupportedKey
+		}
+		mac := hmac.New(a.hash.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		h := a.hash.New()
+		h.Write([]byte(signed))
+		switch a.kind {
+		case "RS":
+			sig, err = rsa.SignPKCS1v15(rand.Reader, k, a.hash, h.Sum(nil))
+		case "PS":
+			sig, err = rsa.SignPSS(rand.Reader, k, a.hash, h.Sum(nil), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		default:
+			return "", errUnsupportedKey
+		}
+	case *ecdsa.PrivateKey:
+		if a.kind != "ES" || k.Curve.Params().BitSize != esCurveBits[alg] {
+			return "", errUnsupportedKey
+		}
+		h := a.hash.New()
+		h.Write([]byte(signed))
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, h.Sum(nil))
+		if err == nil {
+			size := (k.Curve.Params().BitSize + 7) / 8 //nolint:mnd
+			sig = make([]byte, 2*size)
+			r.FillBytes(sig[:size])
+			s.FillBytes(sig[size:])
+		}
+	default:
+		return "", errUnsupportedKey
+	}
+	if err != nil {
+		return "", fmt.Errorf("sign: %w", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
@@ -1,25 +1,77 @@
 package token
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
 	"errors"
+	"fmt"
 	"net/http"
+
+	"github.com/bhmj/goblocks/apiauth"
+)
+
+const (
+	providerName  = "token"
+	defaultHeader = "Api-Token"
 )
 
 var errInvalidToken = errors.New("missing or invalid token")
 
+// Named is a static token identified by name
+type Named struct {
+	Name   string   `yaml:"name" description:"Token name (principal name)"`
+	Token  string   `yaml:"token" description:"Secret token"`
+	Roles  []string `yaml:"roles" description:"Roles granted to the token"`
+	Scopes []string `yaml:"scopes" description:"Scopes granted to the token"`
+}
+
+// Auth compares the request token with the configured ones in constant time
 type Auth struct {
-	secret string
+	header string
+	tokens []namedHash
+}
+
+type namedHash struct {
+	Named
+	hash [sha256.Size]byte
 }
 
+// New returns provider with a single secret token passed in Api-Token header
 func New(secret string) *Auth {
-	return &Auth{secret: secret}
+	return NewNamed(Named{Name: providerName, Token: secret})
+}
+
+// NewNamed returns provider with several named tokens passed in Api-Token header
+func NewNamed(tokens ...Named) *Auth {
+	a := &Auth{header: defaultHeader}
+	for _, t := range tokens {
+		a.tokens = append(a.tokens, namedHash{Named: t, hash: sha256.Sum256([]byte(t.Token))})
+	}
+	return a
 }
 
 func (a *Auth) Authorized(req *http.Request) error {
-	headerToken := req.Header.Get("Api-Token")
-	if headerToken == a.secret {
-		return nil
+	_, err := a.Authenticate(req)
+	return err
+}
+
+func (a *Auth) Authenticate(req *http.Request) (*apiauth.Principal, error) {
+	headerToken := req.Header.Get(a.header)
+	if headerToken == "" {
+		return nil, fmt.Errorf("%w: %s header", apiauth.ErrNoCredentials, a.header)
+	}
+
+	// hashes have equal length, so the comparison time does not depend on the token
+	hash := sha256.Sum256([]byte(headerToken))
+	var matched *Named
+	for i := range a.tokens {
+		if subtle.ConstantTimeCompare(hash[:], a.tokens[i].hash[:]) == 1 {
+			matched = &a.tokens[i].Named
+		}
+	}
+	if matched == nil {
+		return nil, errInvalidToken
 	}
 
-	return errInvalidToken
+	return &apiauth.Principal{Name: matched.Name, Provider: providerName, Roles: matched.Roles, Scopes: matched.Scopes}, nil
 }
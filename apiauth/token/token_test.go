@@ -0,0 +1,57 @@
+package token
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bhmj/goblocks/apiauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticate(t *testing.T) {
+	a := assert.New(t)
+
+	auth := NewNamed(
+		Named{Name: "ci", Token: "t1", Scopes: []string{"orders:read"}},
+		Named{Name: "deploy", Token: "t2", Roles: []string{"ops"}},
+	)
+	for _, tc := range []struct {
+		name      string
+		token     string
+		principal string
+		err       error
+	}{
+		{"first token", "t1", "ci", nil},
+		{"second token", "t2", "deploy", nil},
+		{"unknown token", "t3", "", errInvalidToken},
+		{"token prefix", "t", "", errInvalidToken},
+		{"no header", "", "", apiauth.ErrNoCredentials},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.token != "" {
+			req.Header.Set("Api-Token", tc.token)
+		}
+		p, err := auth.Authenticate(req)
+		if tc.err != nil {
+			a.ErrorIs(err, tc.err, tc.name)
+			a.Nil(p, tc.name)
+			continue
+		}
+		a.NoError(err, tc.name)
+		ctx := apiauth.WithPrincipal(req.Context(), p)
+		a.Equal(tc.principal, apiauth.FromContext(ctx).Name, tc.name)
+		a.Equal("token", p.Provider)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer t1")
+	_, err := auth.Authenticate(req)
+	a.ErrorIs(err, apiauth.ErrNoCredentials, "token in a wrong header")
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Api-Token", "legacy")
+	p, err := New("legacy").Authenticate(req)
+	a.NoError(err)
+	a.Equal("token", p.Name)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"html/template"
+	"io/fs"
 	syslog "log"
 	"os"
 	"os/signal"
@@ -13,23 +15,32 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/bhmj/goblocks/apiauth/token"
 	"github.com/bhmj/goblocks/appstatus"
 	"github.com/bhmj/goblocks/gorillarouter"
+	"github.com/bhmj/goblocks/httpreply"
 	"github.com/bhmj/goblocks/httpserver"
 	"github.com/bhmj/goblocks/log"
 	"github.com/bhmj/goblocks/metrics"
 	"github.com/bhmj/goblocks/sentry"
+	"github.com/bhmj/goblocks/session"
 	"github.com/bhmj/goblocks/statserver"
+	"github.com/bhmj/goblocks/templates"
+	"github.com/bhmj/goblocks/tracing"
 	"go.uber.org/automaxprocs/maxprocs"
 	"golang.org/x/sync/errgroup"
 )
 
+const tracingShutdownTimeout = 5 * time.Second
+
 var (
 	errInvalidServiceName = errors.New("service name must match the unquoted yaml key format (e.g. [a-zA-Z_]+)")
 	errEmptyConfig        = errors.New("config is empty or invalid YAML document")
 )
 
 type application struct {
+	name        string
+	version     string
 	services    map[string]Service
 	serviceDefs map[string]registeredService
 	logger      log.MetaLogger
@@ -37,6 +48,13 @@ type application struct {
 	cfgPath     string
 	httpServer  httpserver.Server
 	statServer  statserver.Server
+	metrics     *metrics.Registry
+
+	templatesFS    fs.FS
+	templatesFuncs template.FuncMap
+
+	sessionStore session.Store
+	sessions     *session.Manager
 }
 
 type registeredService struct {
@@ -54,7 +72,7 @@ func New(appName, appVersion string) Application {
 	syslog.Printf("Starting %s, version %s\n", appName, appVersion)
 	syslog.Printf("username: %s, uid: %s, gid: %s", currentUser.Username, currentUser.Uid, currentUser.Gid)
 
-	return &application{cfg: &Config{}}
+	return &application{name: appName, version: appVersion, cfg: &Config{}}
 }
 
 // RegisterService registers a service with the application
@@ -83,6 +101,24 @@ func (a *application) RegisterService(name string, cfg any, factory ServiceFacto
 	return nil
 }
 
+// SetTemplates sets an embedded templates FS (e.g. embed.FS) and/or template functions.
+// Call it before Run.
+func (a *application) SetTemplates(fsys fs.FS, funcs template.FuncMap) {
+	a.templatesFS = fsys
+	a.templatesFuncs = funcs
+}
+
+// SetSessionStore sets a session store (e.g. session.NewPostgresStore) used instead of the one
+// configured by session.store. Call it before Run.
+func (a *application) SetSessionStore(store session.Store) {
+	a.sessionStore = store
+}
+
+// SetLogger sets a logger (e.g. logtest.New in tests) used instead of the one configured by log. Call it before Run.
+func (a *application) SetLogger(logger log.MetaLogger) {
+	a.logger = logger
+}
+
 // Run starts the application. config is optional explicit config. If nil, config is read from file.
 func (a *application) Run(config any) {
 	// set GOMAXPROCS
@@ -108,9 +144,35 @@ func (a *application) Run(config any) {
 	}
 
 	// logger
-	logger, err := log.New(a.cfg.LogLevel, false)
-	if err != nil {
-		syslog.Fatal(err.Error())
+	logger := a.logger
+	if logger == nil {
+		logConfig := a.cfg.Log
+		if logConfig.Level == "" {
+			logConfig.Level = a.cfg.LogLevel
+		}
+		logger, err = log.NewWithConfig(logConfig)
+		if err != nil {
+			syslog.Fatal(err.Error())
+		}
+	}
+	log.SetDefault(logger)
+	if err := log.RegisterMetrics(logger, metricsRegistry.Get()); err != nil {
+		logger.Fatal("log metrics", log.Error(err))
+	}
+
+	// tracing
+	if a.cfg.Tracing.Enabled {
+		shutdownTracing, err := tracing.Init(context.Background(), a.cfg.Tracing, a.name, a.version)
+		if err != nil {
+			logger.Fatal("init tracing", log.Error(err))
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				logger.Error("shutdown tracing", log.Error(err))
+			}
+		}()
 	}
 
 	// sentry
@@ -119,11 +181,29 @@ func (a *application) Run(config any) {
 		logger.Fatal("create sentry service", log.Error(err))
 	}
 
+	// templates
+	renderer, err := a.newRenderer()
+	if err != nil {
+		logger.Fatal("load templates", log.Error(err))
+	}
+
+	// sessions
+	if a.cfg.Session.Enabled {
+		store := a.sessionStore
+		if store == nil {
+			store, err = session.NewStore(a.cfg.Session)
+			if err != nil {
+				logger.Fatal("create session store", log.Error(err))
+			}
+		}
+		a.sessions = session.NewManager(a.cfg.Session, store)
+	}
+
 	// router
 	router := gorillarouter.New()
 
 	// app + metrics http servers
-	a.httpServer, err = httpserver.NewServer(a.cfg.HTTP, a.cfg.HTTP.Metrics, router, logger, metricsRegistry, sentryService.GetHandler())
+	a.httpServer, err = httpserver.NewServer(a.cfg.HTTP, a.cfg.HTTP.Metrics, router, log.Named(logger, "httpserver"), metricsRegistry, sentryService.GetHandler())
 	if err != nil {
 		logger.Fatal("create app http server", log.Error(err))
 	}
@@ -131,8 +211,12 @@ func (a *application) Run(config any) {
 	if err != nil {
 		logger.Fatal("create stats http server", log.Error(err))
 	}
+	if a.cfg.HTTP.StatsToken != "" {
+		a.statServer.HandleLogLevels(log.LevelsOf(logger), token.New(a.cfg.HTTP.StatsToken))
+	}
 
 	a.logger = logger
+	a.metrics = metricsRegistry
 
 	// create services
 	a.services = make(map[string]Service)
@@ -142,9 +226,11 @@ func (a *application) Run(config any) {
 			logger.Fatal("create service reporter", log.String("service", name), log.Error(err))
 		}
 		options := Options{
-			Logger:          a.logger,
+			Logger:          log.Named(a.logger, name),
 			MetricsRegistry: metricsRegistry,
 			ServiceReporter: serviceReporter,
+			Templates:       renderer,
+			Sessions:        a.sessions,
 			Production:      a.cfg.Production,
 			ConfigPath:      a.cfgPath,
 		}
@@ -153,6 +239,9 @@ func (a *application) Run(config any) {
 			logger.Fatal("create service", log.String("service", name), log.Error(err))
 		}
 		a.services[name] = service
+		if err := a.addHandlers(name, service); err != nil {
+			logger.Fatal("register handlers", log.String("service", name), log.Error(err))
+		}
 	}
 
 	a.runEverything(appReporter)
@@ -160,6 +249,19 @@ func (a *application) Run(config any) {
 	a.logger.Sync() //nolint:errcheck
 }
 
+// newRenderer creates templates renderer if templates are configured. Templates are reloaded on change in non-production mode.
+func (a *application) newRenderer() (*templates.Renderer, error) {
+	if a.templatesFS == nil && a.cfg.Templates.Dir == "" {
+		return nil, nil //nolint:nilnil
+	}
+	renderer, err := templates.New(a.cfg.Templates, a.templatesFS, a.templatesFuncs, !a.cfg.Production)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	httpreply.SetRenderer(renderer)
+	return renderer, nil
+}
+
 // Run starts the application
 func (a *application) runEverything(appReporter appstatus.ServiceStatusReporter) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -180,9 +282,19 @@ func (a *application) runEverything(appReporter appstatus.ServiceStatusReporter)
 		return nil
 	})
 
+	// metrics exporters (statsd, push, otlp)
+	eg.Go(func() error {
+		return a.metrics.Run(ctx, log.Named(a.logger, "metrics"))
+	})
+
+	if a.sessions != nil {
+		eg.Go(func() error {
+			return a.sessions.Run(ctx)
+		})
+	}
+
 	// run services
-	for name, service := range a.services {
-		a.addHandlers(name, service.GetHandlers(), service.GetSessionData)
+	for _, service := range a.services {
 		eg.Go(func() error {
 			return service.Run(ctx)
 		})
@@ -218,13 +330,33 @@ func (a *application) runEverything(appReporter appstatus.ServiceStatusReporter)
 	a.logger.Info("terminated successfully")
 }
 
-func (a *application) addHandlers(service string, handlers []HandlerDefinition, sessionGetter httpserver.SessionDataGetter) {
-	for _, h := range handlers {
-		if !h.Options.SIDRequired {
-			sessionGetter = nil // do not query user storage
-		} else if sessionGetter == nil {
-			a.logger.Fatal("SID required but no session getter provided", log.String("service", service), log.String("endpoint", h.Endpoint))
+func (a *application) addHandlers(name string, service Service) error {
+	var sessionLoader httpserver.SessionLoader = httpserver.SessionDataGetter(service.GetSessionData)
+	if a.sessions != nil {
+		sessionLoader = a.sessions
+	}
+	for _, h := range service.GetHandlers() {
+		var session httpserver.SessionLoader // do not query user storage unless SID required
+		if h.Options.SIDRequired {
+			session = sessionLoader
+		}
+		opts := httpserver.EndpointOptions{
+			Session:         session,
+			SessionStrict:   session != nil && a.sessions != nil && a.sessions.Strict(),
+			CSRFExempt:      h.Options.CSRFExempt,
+			SecurityHeaders: h.Options.Headers,
+			PathPrefix:      h.Options.PathPrefix,
+			Compression:     h.Options.Compression,
+			Listeners:       h.Options.Listeners,
+			Auth:            h.Options.Auth,
+			NoAuth:          h.Options.NoAuth,
+			Roles:           h.Options.Roles,
+			Scopes:          h.Options.Scopes,
+			Policy:          h.Options.Policy,
+		}
+		if err := a.httpServer.HandleFunc(name, h.Endpoint, h.Method, h.Path, h.Func, opts); err != nil {
+			return fmt.Errorf("%s %s: %w", h.Method, h.Path, err)
 		}
-		a.httpServer.HandleFunc(service, h.Endpoint, h.Method, h.Path, h.Func, sessionGetter)
 	}
+	return nil
 }
@@ -19,6 +19,7 @@ import (
 	"github.com/bhmj/goblocks/httpreply"
 	"github.com/bhmj/goblocks/httpserver"
 	"github.com/bhmj/goblocks/log"
+	"github.com/bhmj/goblocks/log/logtest"
 	"github.com/bhmj/goblocks/metrics"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
@@ -197,7 +198,7 @@ func TestAppResponse(t *testing.T) {
 	a.NoError(err)
 	var found bool
 	for _, line := range lines {
-		if strings.HasPrefix(line, "testapp_request_latency_bucket") {
+		if strings.HasPrefix(line, "testapp_httpserver_request_duration_seconds_bucket") {
 			found = true
 			break
 		}
@@ -234,6 +235,42 @@ func TestAppShutdown(t *testing.T) {
 	a.False(getReady(cfg.App.HTTP.StatsPort))
 }
 
+func TestAppLogs(t *testing.T) {
+	a := assert.New(t)
+
+	cfg, logs := startTestApp(t)
+
+	resp, err := get(fmt.Sprintf("http://127.0.0.1:%d/api/factorial/abc", cfg.App.HTTP.Port))
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	logs.AssertLogged(t, "info", "starting server", log.String("listener", "api"))
+	logs.AssertLogged(t, "error", "runtime", log.String("error", `invalid number: strconv.Atoi: parsing "abc": invalid syntax`))
+	a.Equal("httpserver", logs.Filter("error", "runtime")[0].Logger)
+}
+
+// startTestApp runs the test service in-process with a recording logger and waits until it is ready
+func startTestApp(t *testing.T) (*TestConfig, *logtest.Logger) {
+	t.Helper()
+	cfg := CreateTestConfig()
+	logs := logtest.New()
+	app := app.New("test app", "v.test")
+	app.SetLogger(logs)
+	if err := app.RegisterService(serviceName, &serviceConfig{}, FactoryForTestService); err != nil {
+		t.Fatal(err)
+	}
+	go func() { app.Run(cfg) }()
+	started := time.Now()
+	for !getReady(cfg.App.HTTP.StatsPort) {
+		if time.Since(started) > 5*time.Second {
+			t.Fatalf("app not ready\n%v", logs.Entries())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cfg, logs
+}
+
 func CreateTestConfig() *TestConfig {
 	ports := getFreeTCPPorts(2)
 	return &TestConfig{
@@ -279,8 +316,12 @@ func getMetrics(port int) ([]string, error) {
 }
 
 func getFactorial(port, number int) (*http.Response, error) {
+	return get(fmt.Sprintf("http://127.0.0.1:%d/api/factorial/%d", port, number))
+}
+
+func get(url string) (*http.Response, error) {
 	httpClient := &http.Client{}
-	req, _ := http.NewRequest("GET", fmt.Sprintf("http://127.0.0.1:%d/api/factorial/%d", port, number), nil)
+	req, _ := http.NewRequest("GET", url, nil)
 	return httpClient.Do(req)
 }
 
@@ -4,13 +4,21 @@ import (
 	"time"
 
 	"github.com/bhmj/goblocks/httpserver"
+	"github.com/bhmj/goblocks/log"
 	"github.com/bhmj/goblocks/sentry"
+	"github.com/bhmj/goblocks/session"
+	"github.com/bhmj/goblocks/templates"
+	"github.com/bhmj/goblocks/tracing"
 )
 
 type Config struct {
 	HTTP          httpserver.Config `yaml:"http" group:"HTTP endpoint configuration"`
 	Sentry        sentry.Config     `yaml:"sentry" group:"Sentry configuration"`
+	Templates     templates.Config  `yaml:"templates" group:"HTML templates configuration"`
+	Session       session.Config    `yaml:"session" group:"Session configuration"`
+	Tracing       tracing.Config    `yaml:"tracing" group:"OpenTelemetry tracing configuration"`
+	Log           log.Config        `yaml:"log" group:"Logging configuration"`
 	ShutdownDelay time.Duration     `yaml:"shutdownDelay" description:"Time to wait before shutting down"`
-	LogLevel      string            `yaml:"logLevel" description:"Log level in production mode" default:"info" choices:"debug,info,warn,error,dpanic,panic,fatal"`
+	LogLevel      string            `yaml:"logLevel" description:"Log level in production mode (if log.level is not set)" default:"info" choices:"debug,info,warn,error,dpanic,panic,fatal"`
 	Production    bool              `yaml:"production" description:"Production mode"`
 }
@@ -0,0 +1,25 @@
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	"github.com/bhmj/goblocks/static"
+)
+
+// StaticDefinition returns a handler definition serving static files under the path prefix.
+// If fsys is nil, files are served from cfg.Dir.
+func StaticDefinition(endpoint, prefix string, cfg static.Config, fsys fs.FS) (HandlerDefinition, error) {
+	handler, err := static.New(prefix, cfg, fsys)
+	if err != nil {
+		return HandlerDefinition{}, fmt.Errorf("static handler %s: %w", endpoint, err)
+	}
+	return HandlerDefinition{
+		Endpoint: endpoint,
+		Method:   http.MethodGet,
+		Path:     prefix,
+		Func:     handler.Serve,
+		Options:  HandlerOptions{PathPrefix: true},
+	}, nil
+}
@@ -2,16 +2,24 @@ package app
 
 import (
 	"context"
+	"html/template"
+	"io/fs"
 
+	"github.com/bhmj/goblocks/apiauth"
 	"github.com/bhmj/goblocks/appstatus"
 	"github.com/bhmj/goblocks/httpserver"
 	"github.com/bhmj/goblocks/log"
 	"github.com/bhmj/goblocks/metrics"
+	"github.com/bhmj/goblocks/session"
+	"github.com/bhmj/goblocks/templates"
 )
 
 // Application is the main application interface
 type Application interface {
 	RegisterService(name string, cfg any, factory ServiceFactory) error // service name must match the unquoted yaml key format (e.g. [a-zA-Z_]+)
+	SetTemplates(fsys fs.FS, funcs template.FuncMap)                    // optional embedded templates (overrides templates.dir) and template functions
+	SetSessionStore(store session.Store)                                // optional session store (e.g. Postgres), overrides session.store
+	SetLogger(logger log.MetaLogger)                                    // optional logger (e.g. logtest.New), overrides log
 	Run(config any)
 }
 
@@ -25,7 +33,17 @@ type HandlerDefinition struct {
 }
 
 type HandlerOptions struct {
-	SIDRequired bool // if true, the handler will query session data using SID cookie and pass it to the handler via context
+	SIDRequired bool                       // if true, session data is loaded (see session.enabled) and passed to the handler via context
+	PathPrefix  bool                       // if true, Path is a prefix matching all nested paths (see StaticDefinition)
+	Compression httpserver.CompressionMode // overrides the global response compression setting (http.compression.enabled)
+	Listeners   []string                   // if set, the endpoint is served only on the listeners with these names (see http.listeners)
+	Auth        []string                   // auth providers chain (httpserver.AuthJWT, ...), overrides http.auth.default
+	NoAuth      bool                       // public endpoint
+	CSRFExempt  bool                       // skip CSRF protection of the SIDRequired endpoint (http.csrf)
+	Headers     map[string]string          // security header overrides (http.securityHeaders), empty value removes the header
+	Roles       []string                   // principal must have any of these roles (403 otherwise)
+	Scopes      []string                   // principal must have all of these scopes (403 otherwise)
+	Policy      apiauth.Policy             // custom authorization (e.g. resource ownership) evaluated before the handler
 }
 
 // Service is an interface that application services should implement
@@ -40,6 +58,8 @@ type Options struct {
 	Logger          log.MetaLogger
 	MetricsRegistry *metrics.Registry
 	ServiceReporter appstatus.ServiceStatusReporter
+	Templates       *templates.Renderer // nil if templates are not configured
+	Sessions        *session.Manager    // nil if built-in sessions are disabled
 	Production      bool
 	ConfigPath      string
 }
@@ -8,7 +8,9 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/bhmj/goblocks/correlation"
 	"github.com/bhmj/goblocks/log"
+	"github.com/bhmj/goblocks/tracing"
 )
 
 // Transport defines a transport with build-in connection counter
@@ -59,6 +61,15 @@ func NewTransport(logger log.MetaLogger, prev *http.Transport, callback func(int
 	return tran
 }
 
+// RoundTrip propagates request ID and trace context of the request context (see correlation package)
+// and records a client span if tracing is enabled
+func (tran *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, end := tracing.StartClientSpan(req)
+	resp, err := tran.Transport.RoundTrip(correlation.InjectRequest(req))
+	end(resp, err)
+	return resp, err //nolint:wrapcheck
+}
+
 func (tran *Transport) getPreviousDialer() func(ctx context.Context, network, addr string) (net.Conn, error) {
 	if tran.DialContext != nil {
 		return tran.DialContext
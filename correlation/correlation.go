@@ -0,0 +1,124 @@
+// Package correlation carries request ID and W3C trace context (traceparent/tracestate) through
+// request context into logs and outbound calls.
+package correlation
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/bhmj/goblocks/log"
+	"github.com/google/uuid"
+)
+
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceparent = "Traceparent"
+	HeaderTracestate  = "Tracestate"
+
+	maxRequestIDLength = 128
+)
+
+type contextKey string
+
+const (
+	contextRequestID contextKey = "requestID"
+	contextTrace     contextKey = "trace"
+)
+
+func init() { //nolint:gochecknoinits
+	log.RegisterContextFields(logFields)
+}
+
+// logFields returns request ID, trace and span of the context named as in httpserver request logs
+func logFields(ctx context.Context) []log.Field {
+	var fields []log.Field
+	if rid := RequestID(ctx); rid != "" {
+		fields = append(fields, log.String("rid", rid))
+	}
+	if trace, ok := Trace(ctx); ok {
+		fields = append(fields, log.String("trace", trace.TraceIDString()), log.String("span", trace.SpanIDString()))
+	}
+	return fields
+}
+
+// NewRequestID generates a request ID
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
+// ValidRequestID reports whether an incoming request ID is acceptable: 1..128 characters
+// of letters, digits and -_.:/+= (no spaces or control characters which could forge log lines).
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '/', c == '+', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestID returns context carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextRequestID, id)
+}
+
+// RequestID returns the request ID from context or empty string
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(contextRequestID).(string)
+	return id
+}
+
+// WithTrace returns context carrying the trace context
+func WithTrace(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, contextTrace, tc)
+}
+
+// Trace returns the trace context from context
+func Trace(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(contextTrace).(TraceContext)
+	return tc, ok
+}
+
+// Inject adds request ID and trace context headers of ctx to outbound request headers
+func Inject(ctx context.Context, header http.Header) {
+	if id := RequestID(ctx); id != "" && header.Get(HeaderRequestID) == "" {
+		header.Set(HeaderRequestID, id)
+	}
+	if tc, ok := Trace(ctx); ok && header.Get(HeaderTraceparent) == "" {
+		header.Set(HeaderTraceparent, tc.Traceparent())
+		if tc.State != "" {
+			header.Set(HeaderTracestate, tc.State)
+		}
+	}
+}
+
+// Transport propagates request ID and trace context of request context to outbound requests
+type Transport struct {
+	Base http.RoundTripper // http.DefaultTransport if nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(InjectRequest(req)) //nolint:wrapcheck
+}
+
+// InjectRequest returns a copy of the request with propagation headers (or the request itself if there is nothing to add)
+func InjectRequest(req *http.Request) *http.Request {
+	ctx := req.Context()
+	_, hasTrace := Trace(ctx)
+	if RequestID(ctx) == "" && !hasTrace {
+		return req
+	}
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+	return req
+}
@@ -0,0 +1,81 @@
+package correlation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceparent(t *testing.T) {
+	a := assert.New(t)
+
+	tc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	a.NoError(err)
+	a.Equal("4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceIDString())
+	a.Equal("00f067aa0ba902b7", tc.SpanIDString())
+	a.True(tc.Sampled())
+	a.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", tc.Traceparent())
+
+	_, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future")
+	a.NoError(err, "future versions may have more fields")
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-x",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+	} {
+		_, err = ParseTraceparent(invalid)
+		a.ErrorIs(err, errInvalidTraceparent, invalid)
+	}
+
+	child := FromHeaders("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "vendor=x")
+	a.Equal(tc.TraceID, child.TraceID)
+	a.Equal(tc.SpanID, child.ParentID)
+	a.NotEqual(tc.SpanID, child.SpanID)
+	a.Equal("vendor=x", child.State)
+
+	fresh := FromHeaders("garbage", "vendor=x")
+	a.NotEqual(tc.TraceID, fresh.TraceID)
+	a.Empty(fresh.State)
+}
+
+func TestRequestID(t *testing.T) {
+	a := assert.New(t)
+
+	a.True(ValidRequestID(NewRequestID()))
+	a.True(ValidRequestID("req-1:abc/def"))
+	a.False(ValidRequestID(""))
+	a.False(ValidRequestID("id\nforged log line"))
+	a.False(ValidRequestID(strings.Repeat("x", maxRequestIDLength+1)))
+}
+
+func TestPropagation(t *testing.T) {
+	a := assert.New(t)
+
+	var received http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer srv.Close()
+
+	tc := NewTrace()
+	ctx := WithTrace(WithRequestID(context.Background(), "rid-1"), tc)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	client := &http.Client{Transport: &Transport{}}
+	resp, err := client.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+
+	a.Equal("rid-1", received.Get(HeaderRequestID))
+	a.Equal(tc.Traceparent(), received.Get(HeaderTraceparent))
+	a.Empty(req.Header.Get(HeaderRequestID), "original request is not modified")
+}
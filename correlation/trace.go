@@ -0,0 +1,113 @@
+package correlation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const (
+	traceparentVersion = "00"
+	maxTracestate      = 512
+	flagSampled        = 0x01
+)
+
+var errInvalidTraceparent = errors.New("invalid traceparent")
+
+// TraceContext is W3C trace context (https://www.w3.org/TR/trace-context/)
+type TraceContext struct {
+	TraceID  [16]byte
+	SpanID   [8]byte // span of this service
+	ParentID [8]byte // span of the caller (zero if the trace started here)
+	Flags    byte
+	State    string // tracestate, passed through
+}
+
+// NewTrace starts a new sampled trace
+func NewTrace() TraceContext {
+	var tc TraceContext
+	_, _ = rand.Read(tc.TraceID[:])
+	_, _ = rand.Read(tc.SpanID[:])
+	tc.Flags = flagSampled
+	return tc
+}
+
+// ParseTraceparent parses traceparent header value
+func ParseTraceparent(value string) (TraceContext, error) {
+	var tc TraceContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == traceparentVersion && len(parts) != 4) {
+		return tc, errInvalidTraceparent
+	}
+	if _, err := hex.DecodeString(parts[0]); err != nil {
+		return tc, errInvalidTraceparent
+	}
+	var flags [1]byte
+	if !decodeHex(tc.TraceID[:], parts[1]) || !decodeHex(tc.SpanID[:], parts[2]) || !decodeHex(flags[:], parts[3]) {
+		return tc, errInvalidTraceparent
+	}
+	if isZero(tc.TraceID[:]) || isZero(tc.SpanID[:]) {
+		return tc, errInvalidTraceparent
+	}
+	tc.Flags = flags[0]
+	return tc, nil
+}
+
+// FromHeaders continues the trace of incoming request headers or starts a new one.
+// The returned context has a new span ID with the caller's span as parent.
+func FromHeaders(traceparent, tracestate string) TraceContext {
+	parent, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return NewTrace()
+	}
+	tc := parent.Child()
+	if len(tracestate) <= maxTracestate {
+		tc.State = tracestate
+	}
+	return tc
+}
+
+// Child returns the context of a new span in the same trace
+func (tc TraceContext) Child() TraceContext {
+	child := tc
+	child.ParentID = tc.SpanID
+	_, _ = rand.Read(child.SpanID[:])
+	return child
+}
+
+// Traceparent returns traceparent header value identifying this span
+func (tc TraceContext) Traceparent() string {
+	return traceparentVersion + "-" + tc.TraceIDString() + "-" + tc.SpanIDString() + "-" + hex.EncodeToString([]byte{tc.Flags})
+}
+
+// Sampled reports whether the caller records the trace
+func (tc TraceContext) Sampled() bool {
+	return tc.Flags&flagSampled != 0
+}
+
+func (tc TraceContext) TraceIDString() string {
+	return hex.EncodeToString(tc.TraceID[:])
+}
+
+func (tc TraceContext) SpanIDString() string {
+	return hex.EncodeToString(tc.SpanID[:])
+}
+
+// decodeHex decodes lowercase hex of exactly len(dst) bytes
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+func isZero(b []byte) bool {
+	for _, x := range b {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
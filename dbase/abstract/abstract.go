@@ -1,5 +1,7 @@
 package abstract
 
+import "context"
+
 type DB interface {
 	BeginTransaction() (DB, error)
 	Rollback() error
@@ -11,3 +13,16 @@ type DB interface {
 	Exec(query string, args ...any) error
 	Close()
 }
+
+// ContextBinder is implemented by DBs able to bind to a context (e.g. request) for cancellation and tracing
+type ContextBinder interface {
+	WithContext(ctx context.Context) DB
+}
+
+// WithContext returns the DB bound to ctx, or the DB itself if it does not implement ContextBinder
+func WithContext(db DB, ctx context.Context) DB { //nolint:revive
+	if binder, ok := db.(ContextBinder); ok {
+		return binder.WithContext(ctx)
+	}
+	return db
+}
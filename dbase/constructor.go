@@ -21,6 +21,8 @@ const SkipMigration int = 1
 func New(ctx context.Context, logger log.MetaLogger, cfg Config, options ...int) abstract.DB {
 	var err error
 
+	logger = log.Named(logger, "dbase")
+
 	var db abstract.DB
 
 	if cfg.Type != "postgres" {
@@ -5,10 +5,12 @@ import (
 	"errors"
 
 	"github.com/bhmj/goblocks/dbase/abstract"
+	"github.com/bhmj/goblocks/tracing"
 	"github.com/georgysavva/scany/pgxscan"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool" // PostgreSQL driver
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
 )
 
 var (
@@ -47,6 +49,16 @@ func New(ctx context.Context, conn string) (abstract.DB, error) {
 	}, nil
 }
 
+// WithContext returns a copy of the DB using ctx for queries (trace spans become children of the ctx span)
+func (p *Psql) WithContext(ctx context.Context) abstract.DB {
+	return &Psql{ctx: ctx, pool: p.pool, conn: p.conn, tx: p.tx}
+}
+
+// span starts a DB trace span
+func (p *Psql) span(query string) (context.Context, func(error)) {
+	return tracing.StartDBSpan(p.ctx, semconv.DBSystemNamePostgreSQL, query)
+}
+
 func (p *Psql) BeginTransaction() (abstract.DB, error) {
 	var tx pgx.Tx
 	var err error
@@ -85,37 +97,46 @@ func (p *Psql) Connect() error {
 	return p.pool.Ping(p.ctx) //nolint:wrapcheck
 }
 
-func (p *Psql) Query(dst any, query string, args ...any) error {
+func (p *Psql) Query(dst any, query string, args ...any) (err error) {
+	ctx, end := p.span(query)
+	defer func() { end(err) }()
 	if len(args) == 0 {
-		return pgxscan.Select(p.ctx, p.conn, dst, query) //nolint:wrapcheck
+		return pgxscan.Select(ctx, p.conn, dst, query) //nolint:wrapcheck
 	}
-	return pgxscan.Select(p.ctx, p.conn, dst, query, args...) //nolint:wrapcheck
+	return pgxscan.Select(ctx, p.conn, dst, query, args...) //nolint:wrapcheck
 }
 
 func (p *Psql) QueryRow(dst any, query string, args ...any) (bool, error) {
+	ctx, end := p.span(query)
 	var err error
 	if len(args) == 0 {
-		err = pgxscan.Get(p.ctx, p.conn, dst, query)
+		err = pgxscan.Get(ctx, p.conn, dst, query)
 	} else {
-		err = pgxscan.Get(p.ctx, p.conn, dst, query, args...)
+		err = pgxscan.Get(ctx, p.conn, dst, query, args...)
 	}
 	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		end(nil)
 		return false, nil
 	}
+	end(err)
 	return err == nil, err //nolint:wrapcheck
 }
 
-func (p *Psql) QueryValue(dst any, query string, args ...any) error {
-	row := p.conn.QueryRow(p.ctx, query, args...)
+func (p *Psql) QueryValue(dst any, query string, args ...any) (err error) {
+	ctx, end := p.span(query)
+	defer func() { end(err) }()
+	row := p.conn.QueryRow(ctx, query, args...)
 	return row.Scan(dst) //nolint:wrapcheck
 }
 
-func (p *Psql) Exec(query string, args ...any) error {
+func (p *Psql) Exec(query string, args ...any) (err error) {
+	ctx, end := p.span(query)
+	defer func() { end(err) }()
 	if len(args) == 0 {
-		_, err := p.conn.Exec(p.ctx, query)
+		_, err = p.conn.Exec(ctx, query)
 		return err //nolint:wrapcheck
 	}
-	_, err := p.conn.Exec(p.ctx, query, args...)
+	_, err = p.conn.Exec(ctx, query, args...)
 	return err //nolint:wrapcheck
 }
 
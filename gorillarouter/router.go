@@ -2,6 +2,7 @@ package gorillarouter
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
@@ -20,6 +21,20 @@ func (gr *GorillaRouter) HandleFunc(method, pattern string, handler func(http.Re
 	gr.router.HandleFunc(pattern, handler).Methods(method)
 }
 
+// HandlePrefix registers the handler for all paths under the prefix directory: /static matches /static/app.js
+// but not /static-admin. The bare prefix is handled as well (e.g. to redirect to the directory).
+func (gr *GorillaRouter) HandlePrefix(method, prefix string, handler func(http.ResponseWriter, *http.Request)) {
+	methods := []string{method}
+	if method == http.MethodGet {
+		methods = append(methods, http.MethodHead)
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	gr.router.PathPrefix(prefix + "/").HandlerFunc(handler).Methods(methods...)
+	if prefix != "" {
+		gr.router.Path(prefix).HandlerFunc(handler).Methods(methods...)
+	}
+}
+
 func New() *GorillaRouter {
 	return &GorillaRouter{router: mux.NewRouter()}
 }
@@ -1,12 +1,31 @@
 package httpreply
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 )
 
+// TemplateRenderer renders named HTML templates (see the templates package)
+type TemplateRenderer interface {
+	Render(w io.Writer, name string, data any) error
+}
+
+var (
+	renderer TemplateRenderer //nolint:gochecknoglobals
+
+	errNoRenderer = errors.New("template renderer is not set")
+)
+
+// SetRenderer sets the renderer used by HTML replies
+func SetRenderer(r TemplateRenderer) {
+	renderer = r
+}
+
 // Replier defines some common and useful functions
 
 func Reply(w http.ResponseWriter, code int, contentType string, content []byte) (int, error) {
@@ -33,7 +52,16 @@ func NoContent(w http.ResponseWriter) (int, error) {
 	return Reply(w, http.StatusNoContent, "", nil)
 }
 
+// ErrorBody is implemented by errors replied as a structured JSON object
+type ErrorBody interface {
+	ErrorBody() any
+}
+
 func Error(w http.ResponseWriter, err error, code int) (int, error) {
+	var structured ErrorBody
+	if errors.As(err, &structured) {
+		return ObjectCode(w, structured.ErrorBody(), code)
+	}
 	return Reply(w, code, "application/json", []byte(`{"error":"`+fmt.Sprintf("%s", err)+`"}`))
 }
 
@@ -58,3 +86,20 @@ func ObjectCode(w http.ResponseWriter, obj any, code int) (int, error) {
 func String(w http.ResponseWriter, str string) (int, error) {
 	return Reply(w, http.StatusOK, "text/plain", []byte(str))
 }
+
+// HTML renders the named template. Rendering errors are returned as handler errors
+// so nothing is written to the client until the page is complete.
+func HTML(w http.ResponseWriter, name string, data any) (int, error) {
+	return HTMLCode(w, name, data, http.StatusOK)
+}
+
+func HTMLCode(w http.ResponseWriter, name string, data any, code int) (int, error) {
+	if renderer == nil {
+		return http.StatusInternalServerError, errNoRenderer
+	}
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, name, data); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("render %s: %w", name, err)
+	}
+	return Reply(w, code, "text/html; charset=utf-8", buf.Bytes())
+}
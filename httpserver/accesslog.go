@@ -0,0 +1,226 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bhmj/goblocks/log"
+)
+
+// Access log formats
+const (
+	AccessLogJSON     = "json"
+	AccessLogCommon   = "common"
+	AccessLogCombined = "combined"
+
+	clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+)
+
+var errUnknownAccessLogFormat = errors.New("unknown access log format")
+
+// AccessLogConfig defines the access log written once per request
+type AccessLogConfig struct {
+	Enabled    bool    `yaml:"enabled" description:"Write access log (start/finish lines are logged at debug level then)"`
+	Format     string  `yaml:"format" description:"Access log format" default:"json" choices:"json,common,combined"`
+	Output     string  `yaml:"output" description:"Destination: application log if empty (json only), stdout, stderr or file path"`
+	SampleRate float64 `yaml:"sampleRate" description:"Share of successful (status < 400) requests to log, errors are always logged" default:"1"`
+}
+
+type accessContextKey struct{}
+
+// accessEntry is an access log record filled while the request is processed
+type accessEntry struct {
+	Time      time.Time `json:"time"`
+	Remote    string    `json:"remote"`
+	Method    string    `json:"method"`
+	URI       string    `json:"uri"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	Latency   float64   `json:"latency"` // seconds
+	Route     string    `json:"route"`
+	Service   string    `json:"service"`
+	Endpoint  string    `json:"endpoint"`
+	Principal string    `json:"principal,omitempty"`
+	RequestID string    `json:"rid"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	Referer   string    `json:"referer,omitempty"`
+}
+
+type accessLog struct {
+	cfg    AccessLogConfig
+	logger log.MetaLogger // used if out is nil
+	out    io.Writer
+	file   *os.File // opened log file
+	mu     sync.Mutex
+}
+
+func newAccessLog(cfg AccessLogConfig, logger log.MetaLogger) (*accessLog, error) {
+	a := &accessLog{cfg: cfg, logger: logger}
+	if a.cfg.Format == "" {
+		a.cfg.Format = AccessLogJSON
+	}
+	switch a.cfg.Format {
+	case AccessLogJSON, AccessLogCommon, AccessLogCombined:
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownAccessLogFormat, cfg.Format)
+	}
+	switch cfg.Output {
+	case "":
+		if a.cfg.Format != AccessLogJSON {
+			a.out = os.Stdout
+		}
+	case "stdout":
+		a.out = os.Stdout
+	case "stderr":
+		a.out = os.Stderr
+	default:
+		f, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644) //nolint:mnd,gosec
+		if err != nil {
+			return nil, fmt.Errorf("open access log: %w", err)
+		}
+		a.out, a.file = f, f
+	}
+	return a, nil
+}
+
+// sampled reports whether the request is to be logged
+func (a *accessLog) sampled(status int) bool {
+	return status >= http.StatusBadRequest || a.cfg.SampleRate >= 1 || rand.Float64() < a.cfg.SampleRate //nolint:gosec
+}
+
+func (a *accessLog) write(e *accessEntry) {
+	if !a.sampled(e.Status) {
+		return
+	}
+	if a.out == nil {
+		a.logger.Info("access",
+			log.String("remote", e.Remote),
+			log.String("method", e.Method),
+			log.String("uri", e.URI),
+			log.String("proto", e.Proto),
+			log.Int("status", e.Status),
+			log.Int64("bytes", e.Bytes),
+			log.Duration("latency", time.Duration(e.Latency*float64(time.Second))),
+			log.String("route", e.Route),
+			log.String("service", e.Service),
+			log.String("endpoint", e.Endpoint),
+			log.String("principal", e.Principal),
+			log.String("rid", e.RequestID),
+			log.String("userAgent", e.UserAgent),
+			log.String("referer", e.Referer),
+		)
+		return
+	}
+	var line []byte
+	switch a.cfg.Format {
+	case AccessLogJSON:
+		line, _ = json.Marshal(e)
+	default:
+		line = e.clf(a.cfg.Format == AccessLogCombined)
+	}
+	line = append(line, '\n')
+	a.mu.Lock()
+	_, _ = a.out.Write(line)
+	a.mu.Unlock()
+}
+
+func (a *accessLog) close() {
+	if a.file != nil {
+		a.file.Close()
+	}
+}
+
+// clf formats the entry in Common (or Combined) Log Format
+func (e *accessEntry) clf(combined bool) []byte {
+	user, size := "-", "-"
+	if e.Principal != "" {
+		user = e.Principal
+	}
+	if e.Bytes > 0 {
+		size = strconv.FormatInt(e.Bytes, 10)
+	}
+	line := fmt.Sprintf("%s - %s [%s] %q %d %s", e.Remote, user, e.Time.Format(clfTimeFormat), e.Method+" "+e.URI+" "+e.Proto, e.Status, size)
+	if combined {
+		line += fmt.Sprintf(" %q %q", e.Referer, e.UserAgent)
+	}
+	return []byte(line)
+}
+
+// setAccessPrincipal records the authenticated principal in the access log entry of the request
+func setAccessPrincipal(ctx context.Context, name string) {
+	if e, ok := ctx.Value(accessContextKey{}).(*accessEntry); ok {
+		e.Principal = name
+	}
+}
+
+// responseRecorder captures reply status and size
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err //nolint:wrapcheck
+}
+
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := r.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack() //nolint:wrapcheck
+	}
+	return nil, nil, errHijackNotSupported
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Status returns the reply status (200 if nothing was written yet)
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// bodyCounter counts request body bytes read
+type bodyCounter struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (b *bodyCounter) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes += int64(n)
+	return n, err //nolint:wrapcheck
+}
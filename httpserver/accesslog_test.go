@@ -0,0 +1,72 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bhmj/goblocks/apiauth"
+	"github.com/bhmj/goblocks/apiauth/token"
+	"github.com/bhmj/goblocks/httpreply"
+	"github.com/bhmj/goblocks/log"
+	"github.com/bhmj/goblocks/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	a := assert.New(t)
+
+	serviceMetrics := newMetrics(prometheus.NewRegistry(), metrics.Config{})
+	handler := func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if r.URL.Query().Get("fail") != "" {
+			return http.StatusNotFound, errors.New("no such order")
+		}
+		return httpreply.String(w, "order 42")
+	}
+	auth := authHandler(handler, apiauth.Chain{token.New("secret")})
+
+	serve := func(cfg AccessLogConfig, uri string) string {
+		var buf bytes.Buffer
+		access, err := newAccessLog(cfg, log.NewNop())
+		a.NoError(err)
+		access.out = &buf
+		req := httptest.NewRequest(http.MethodGet, uri, nil)
+		req.Header.Set("Api-Token", "secret")
+		req.Header.Set("User-Agent", "test/1.0")
+		req.Header.Set("X-Request-ID", "rid-1")
+		w := httptest.NewRecorder()
+		instrumentationMiddleware(auth, log.NewNop(), serviceMetrics, endpointInfo{
+			service: "svc", endpoint: "order", route: "/orders/{id}", accessLog: access,
+		})(w, req)
+		return buf.String()
+	}
+
+	// JSON
+	var entry accessEntry
+	a.NoError(json.Unmarshal([]byte(serve(AccessLogConfig{Format: AccessLogJSON, SampleRate: 1}, "/orders/42")), &entry))
+	a.Equal(http.StatusOK, entry.Status)
+	a.Equal(int64(len("order 42")), entry.Bytes)
+	a.Equal("/orders/{id}", entry.Route)
+	a.Equal("svc", entry.Service)
+	a.Equal("order", entry.Endpoint)
+	a.Equal("rid-1", entry.RequestID)
+	a.Equal("test/1.0", entry.UserAgent)
+	a.NotEmpty(entry.Principal)
+
+	// Combined Log Format, error reply
+	line := serve(AccessLogConfig{Format: AccessLogCombined, SampleRate: 1}, "/orders/43?fail=1")
+	a.Contains(line, `"GET /orders/43?fail=1 HTTP/1.1" 404 `)
+	a.True(strings.HasSuffix(line, "\"\" \"test/1.0\"\n"), line)
+
+	// sampling skips successful requests but never errors
+	a.Empty(serve(AccessLogConfig{Format: AccessLogCommon, SampleRate: 0}, "/orders/42"))
+	a.NotEmpty(serve(AccessLogConfig{Format: AccessLogCommon, SampleRate: 0}, "/orders/42?fail=1"))
+
+	_, err := newAccessLog(AccessLogConfig{Format: "xml"}, log.NewNop())
+	a.Error(err)
+}
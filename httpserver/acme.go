@@ -0,0 +1,64 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ACMEConfig defines automatic certificate management (Let's Encrypt or any other ACME CA).
+// When enabled, the certificates of all TLS listeners are obtained and renewed automatically;
+// TLS-ALPN-01 challenge is served on the TLS listeners, HTTP-01 on HTTPAddress (if set).
+type ACMEConfig struct {
+	Enabled      bool          `yaml:"enabled" description:"Obtain TLS certificates using ACME"`
+	Domains      []string      `yaml:"domains" description:"Domain names allowed to request certificates for"`
+	Email        string        `yaml:"email" description:"Contact email of the ACME account"`
+	CacheDir     string        `yaml:"cacheDir" description:"Directory to store account key and certificates in" default:"acme-cache"`
+	DirectoryURL string        `yaml:"directoryURL" description:"ACME directory URL (Let's Encrypt production if empty)"` //nolint:tagliatelle
+	CAFile       string        `yaml:"caFile" description:"Root CA of the ACME server (for private or testing CAs like Pebble)"`
+	HTTPAddress  string        `yaml:"httpAddress" description:"Address of HTTP-01 challenge listener, e.g. :80 (also redirects to HTTPS); disabled if empty"`
+	RenewBefore  time.Duration `yaml:"renewBefore" description:"Renew certificates this long before expiry" default:"720h"`
+}
+
+// newACMEManager creates autocert manager for the configuration.
+func newACMEManager(cfg ACMEConfig) (*autocert.Manager, error) {
+	client := &acme.Client{DirectoryURL: cfg.DirectoryURL}
+	if cfg.CAFile != "" {
+		pool, err := loadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("acme CA: %w", err)
+		}
+		client.HTTPClient = &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		}}
+	}
+	return &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       autocert.DirCache(cfg.CacheDir),
+		HostPolicy:  autocert.HostWhitelist(cfg.Domains...),
+		RenewBefore: cfg.RenewBefore,
+		Email:       cfg.Email,
+		Client:      client,
+	}, nil
+}
+
+// acmeTLSConfig returns TLS config serving ACME certificates and TLS-ALPN-01 challenges.
+func acmeTLSConfig(l *ListenerConfig, params TLSParams, m *autocert.Manager) (*tls.Config, error) {
+	tlsConf, err := baseTLSConfig(l, params)
+	if err != nil {
+		return nil, err
+	}
+	tlsConf.GetCertificate = m.GetCertificate
+	tlsConf.NextProtos = append(tlsConf.NextProtos, acme.ALPNProto)
+	if l.TLSUseClientCert && l.TLSClientCAFile != "" {
+		if tlsConf.ClientCAs, err = loadCertPool(l.TLSClientCAFile); err != nil {
+			return nil, err
+		}
+	}
+	return tlsConf, nil
+}
@@ -0,0 +1,150 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bhmj/goblocks/apiauth"
+	"github.com/bhmj/goblocks/apiauth/basic"
+	"github.com/bhmj/goblocks/apiauth/cert"
+	"github.com/bhmj/goblocks/apiauth/hmac"
+	"github.com/bhmj/goblocks/apiauth/jwt"
+	"github.com/bhmj/goblocks/apiauth/token"
+	"github.com/bhmj/goblocks/log"
+)
+
+// Authentication provider names
+const (
+	AuthToken = "token"
+	AuthBasic = "basic"
+	AuthJWT   = "jwt"
+	AuthHMAC  = "hmac"
+	AuthCert  = "cert"
+)
+
+var errUnknownAuthProvider = errors.New("unknown auth provider")
+
+// AuthConfig defines authentication providers. Endpoints use the Default chain unless
+// they name their own providers (EndpointOptions.Auth) or are public (EndpointOptions.NoAuth).
+type AuthConfig struct {
+	Default     []string      `yaml:"default" description:"Providers applied to endpoints in order (all configured providers if empty)"`
+	Tokens      []token.Named `yaml:"tokens" description:"Named static tokens passed in Api-Token header"`
+	Basic       basic.Config  `yaml:"basic" description:"HTTP Basic authentication"`
+	JWT         jwt.Config    `yaml:"jwt" description:"JWT bearer tokens"`
+	HMAC        hmac.Config   `yaml:"hmac" description:"HMAC request signing"`
+	ClientCert  bool          `yaml:"clientCert" description:"Authenticate by verified client certificate"`
+	CertAllowed []string      `yaml:"certAllowed" description:"Client certificate CNs or SANs accepted by cert provider (any verified if empty)"`
+}
+
+// authenticator holds named auth providers
+type authenticator struct {
+	providers map[string]apiauth.Auth
+	defaults  apiauth.Chain
+}
+
+func newAuthenticator(cfg Config) (*authenticator, error) {
+	a := &authenticator{providers: make(map[string]apiauth.Auth)}
+	var order []string
+	add := func(name string, provider apiauth.Auth) {
+		a.providers[name] = provider
+		order = append(order, name)
+	}
+
+	tokens := cfg.Auth.Tokens
+	if cfg.Token != "" {
+		tokens = append(tokens, token.Named{Name: "default", Token: cfg.Token})
+	}
+	if len(tokens) > 0 {
+		add(AuthToken, token.NewNamed(tokens...))
+	}
+	if len(cfg.Auth.Basic.Users) > 0 || cfg.Auth.Basic.File != "" {
+		provider, err := basic.New(cfg.Auth.Basic)
+		if err != nil {
+			return nil, fmt.Errorf("basic auth: %w", err)
+		}
+		add(AuthBasic, provider)
+	}
+	jwtConf := cfg.Auth.JWT
+	if jwtConf.Secret != "" || jwtConf.PublicKeyFile != "" || jwtConf.JWKSFile != "" || jwtConf.JWKSURL != "" {
+		provider, err := jwt.New(jwtConf)
+		if err != nil {
+			return nil, fmt.Errorf("jwt auth: %w", err)
+		}
+		add(AuthJWT, provider)
+	}
+	if len(cfg.Auth.HMAC.Keys) > 0 {
+		add(AuthHMAC, hmac.New(cfg.Auth.HMAC, nil))
+	}
+	if cfg.Auth.ClientCert {
+		add(AuthCert, cert.New(cfg.Auth.CertAllowed...))
+	}
+
+	if len(cfg.Auth.Default) > 0 {
+		order = cfg.Auth.Default
+	}
+	var err error
+	a.defaults, err = a.chain(order)
+	return a, err
+}
+
+// chain returns providers chain by names
+func (a *authenticator) chain(names []string) (apiauth.Chain, error) {
+	var chain apiauth.Chain
+	for _, name := range names {
+		provider, found := a.providers[name]
+		if !found {
+			return nil, fmt.Errorf("%w: %s", errUnknownAuthProvider, name)
+		}
+		chain = append(chain, provider)
+	}
+	return chain, nil
+}
+
+// endpointChain returns the providers for the endpoint
+func (a *authenticator) endpointChain(opts EndpointOptions) (apiauth.Chain, error) {
+	if opts.NoAuth {
+		return nil, nil
+	}
+	if len(opts.Auth) == 0 {
+		return a.defaults, nil
+	}
+	return a.chain(opts.Auth)
+}
+
+// authHandler authenticates the request and puts the principal into request context
+func authHandler(next HandlerWithResult, chain apiauth.Chain) HandlerWithResult {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		principal, err := chain.Authenticate(r)
+		if err != nil {
+			if challenge := chain.Challenge(); challenge != "" {
+				w.Header().Set("WWW-Authenticate", challenge)
+			}
+			return http.StatusUnauthorized, fmt.Errorf("unauthorized: %w", err)
+		}
+		if logger, ok := r.Context().Value(log.ContextMetaLogger).(log.MetaLogger); ok {
+			logger.Add(log.String("principal", principal.Name), log.String("auth", principal.Provider))
+		}
+		setAccessPrincipal(r.Context(), principal.Name)
+		return next(w, r.WithContext(apiauth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// authorizeHandler checks required roles and scopes and the custom policy of the endpoint
+func authorizeHandler(next HandlerWithResult, req apiauth.Requirement, policy apiauth.Policy) HandlerWithResult {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		principal := apiauth.FromContext(r.Context())
+		if err := req.Check(principal); err != nil {
+			return http.StatusForbidden, err
+		}
+		if policy != nil {
+			if err := policy.Authorize(r, principal); err != nil {
+				if errors.Is(err, apiauth.ErrForbidden) {
+					return http.StatusForbidden, err
+				}
+				return http.StatusInternalServerError, fmt.Errorf("authorization policy: %w", err)
+			}
+		}
+		return next(w, r)
+	}
+}
@@ -0,0 +1,176 @@
+package httpserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bhmj/goblocks/apiauth"
+	"github.com/bhmj/goblocks/apiauth/hmac"
+	"github.com/bhmj/goblocks/apiauth/jwt"
+	"github.com/bhmj/goblocks/apiauth/token"
+	"github.com/bhmj/goblocks/httpreply"
+	"github.com/bhmj/goblocks/log"
+	"github.com/bhmj/goblocks/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointAuth(t *testing.T) {
+	a := assert.New(t)
+
+	cfg := Config{Token: "legacy", Auth: AuthConfig{
+		Tokens: []token.Named{{Name: "ci", Token: "t1"}},
+		JWT:    jwt.Config{Secret: "s3cret"},
+	}}
+	auth, err := newAuthenticator(cfg)
+	a.NoError(err)
+	a.Len(auth.defaults, 2)
+
+	handler := func(w http.ResponseWriter, r *http.Request) (int, error) {
+		p := apiauth.FromContext(r.Context())
+		if p == nil {
+			_, _ = io.WriteString(w, "anonymous")
+		} else {
+			_, _ = io.WriteString(w, p.Provider+":"+p.Name)
+		}
+		return http.StatusOK, nil
+	}
+	serve := func(opts EndpointOptions, header, value string) *httptest.ResponseRecorder {
+		h := HandlerWithResult(handler)
+		chain, err := auth.endpointChain(opts)
+		a.NoError(err)
+		if len(chain) > 0 {
+			h = authHandler(h, chain)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		if code, err := h(w, req); err != nil {
+			w.WriteHeader(code)
+		}
+		return w
+	}
+
+	w := serve(EndpointOptions{}, "Api-Token", "legacy")
+	a.Equal("token:default", w.Body.String())
+	w = serve(EndpointOptions{}, "", "")
+	a.Equal(http.StatusUnauthorized, w.Code)
+	a.Equal("Bearer", w.Header().Get("WWW-Authenticate"))
+
+	w = serve(EndpointOptions{Auth: []string{AuthJWT}}, "Api-Token", "t1")
+	a.Equal(http.StatusUnauthorized, w.Code, "token provider is not in the endpoint chain")
+
+	w = serve(EndpointOptions{NoAuth: true}, "", "")
+	a.Equal("anonymous", w.Body.String())
+
+	_, err = auth.endpointChain(EndpointOptions{Auth: []string{"oauth"}})
+	a.ErrorIs(err, errUnknownAuthProvider)
+
+	_, err = newAuthenticator(Config{Auth: AuthConfig{Default: []string{AuthBasic}}})
+	a.ErrorIs(err, errUnknownAuthProvider)
+}
+
+func TestEndpointAuthorization(t *testing.T) {
+	a := assert.New(t)
+
+	auth, err := newAuthenticator(Config{Auth: AuthConfig{Tokens: []token.Named{
+		{Name: "reader", Token: "r", Scopes: []string{"orders:read"}},
+		{Name: "admin", Token: "a", Roles: []string{"admin"}, Scopes: []string{"orders:read", "orders:write"}},
+	}}})
+	a.NoError(err)
+	serviceMetrics := newMetrics(prometheus.NewRegistry(), metrics.Config{})
+
+	ownerOnly := apiauth.PolicyFunc(func(r *http.Request, p *apiauth.Principal) error {
+		if r.URL.Query().Get("owner") != p.Name {
+			return apiauth.Forbidden("not an owner")
+		}
+		return nil
+	})
+	serve := func(opts EndpointOptions, tok, query string) *httptest.ResponseRecorder {
+		h := HandlerWithResult(func(w http.ResponseWriter, _ *http.Request) (int, error) {
+			return httpreply.String(w, "ok")
+		})
+		h = authorizeHandler(h, apiauth.Requirement{Roles: opts.Roles, Scopes: opts.Scopes}, opts.Policy)
+		chain, err := auth.endpointChain(opts)
+		a.NoError(err)
+		h = authHandler(h, chain)
+		req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		req.Header.Set("Api-Token", tok)
+		w := httptest.NewRecorder()
+		instrumentationMiddleware(h, log.NewNop(), serviceMetrics, endpointInfo{service: "svc", endpoint: "orders"})(w, req)
+		return w
+	}
+
+	w := serve(EndpointOptions{Scopes: []string{"orders:read"}}, "r", "")
+	a.Equal(http.StatusOK, w.Code)
+
+	w = serve(EndpointOptions{Scopes: []string{"orders:read", "orders:write"}}, "r", "")
+	a.Equal(http.StatusForbidden, w.Code)
+	a.JSONEq(`{"error":"forbidden","reason":"missing scope","requiredScopes":["orders:write"]}`, w.Body.String())
+
+	w = serve(EndpointOptions{Roles: []string{"admin", "ops"}}, "a", "")
+	a.Equal(http.StatusOK, w.Code)
+	w = serve(EndpointOptions{Roles: []string{"admin", "ops"}}, "r", "")
+	a.Equal(http.StatusForbidden, w.Code)
+	a.JSONEq(`{"error":"forbidden","reason":"missing role","requiredRoles":["admin","ops"]}`, w.Body.String())
+
+	w = serve(EndpointOptions{Policy: ownerOnly}, "r", "owner=reader")
+	a.Equal(http.StatusOK, w.Code)
+	w = serve(EndpointOptions{Policy: ownerOnly}, "r", "owner=admin")
+	a.Equal(http.StatusForbidden, w.Code)
+	a.JSONEq(`{"error":"forbidden","reason":"not an owner"}`, w.Body.String())
+}
+
+func TestEndpointAuthFormBody(t *testing.T) {
+	a := assert.New(t)
+
+	auth, err := newAuthenticator(Config{Auth: AuthConfig{HMAC: hmac.Config{Keys: []hmac.Key{{ID: "client1", Secret: "k1"}}}}})
+	a.NoError(err)
+	serviceMetrics := newMetrics(prometheus.NewRegistry(), metrics.Config{})
+
+	for _, contentType := range []string{"application/json", "application/x-www-form-urlencoded"} {
+		h := HandlerWithResult(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			return httpreply.String(w, r.PostFormValue("b"))
+		})
+		chain, err := auth.endpointChain(EndpointOptions{Auth: []string{AuthHMAC}})
+		a.NoError(err)
+		h = authHandler(h, chain)
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("a=1&b=2"))
+		req.Header.Set("Content-Type", contentType)
+		a.NoError(hmac.Sign(req, "client1", "k1"))
+		w := httptest.NewRecorder()
+		instrumentationMiddleware(h, log.NewNop(), serviceMetrics, endpointInfo{service: "svc", endpoint: "orders"})(w, req)
+		a.Equal(http.StatusOK, w.Code, contentType)
+	}
+}
+
+// muxRouter is a minimal Router over http.ServeMux
+type muxRouter struct{ *http.ServeMux }
+
+func (m muxRouter) HandleFunc(method, pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.ServeMux.HandleFunc(method+" "+pattern, handler)
+}
+
+func TestEndpointAuthConfigError(t *testing.T) {
+	a := assert.New(t)
+
+	auth, err := newAuthenticator(Config{Auth: AuthConfig{Tokens: []token.Named{{Name: "ci", Token: "t1"}}}})
+	a.NoError(err)
+	s := &httpserver{
+		logger:  log.NewNop(),
+		metrics: newMetrics(prometheus.NewRegistry(), metrics.Config{}),
+		router:  muxRouter{http.NewServeMux()},
+		auth:    auth,
+	}
+	handler := func(w http.ResponseWriter, _ *http.Request) (int, error) { return httpreply.String(w, "ok") }
+	a.NoError(s.HandleFunc("svc", "orders", http.MethodGet, "/orders", handler, EndpointOptions{Auth: []string{AuthToken}}))
+	err = s.HandleFunc("svc", "admin", http.MethodGet, "/admin", handler, EndpointOptions{Auth: []string{"tokne"}})
+	a.ErrorIs(err, errUnknownAuthProvider, "typo fails registration")
+	err = s.HandleFunc("svc", "static", http.MethodGet, "/static", handler, EndpointOptions{PathPrefix: true})
+	a.ErrorIs(err, errPrefixNotSupported, "muxRouter has no HandlePrefix")
+}
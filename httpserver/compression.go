@@ -0,0 +1,302 @@
+package httpserver
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionMode enables or disables response compression for an endpoint
+type CompressionMode int
+
+const (
+	CompressDefault CompressionMode = iota // follow Config.Compression.Enabled
+	CompressOn
+	CompressOff
+)
+
+const (
+	encodingBrotli = "br"
+	encodingZstd   = "zstd"
+	encodingGzip   = "gzip"
+
+	defaultCompressionMinSize = 1024
+)
+
+var errHijackNotSupported = errors.New("response writer does not support hijacking")
+
+// CompressionConfig defines response compression parameters
+type CompressionConfig struct {
+	Enabled      bool     `yaml:"enabled" description:"Compress responses of all endpoints (can be overridden per endpoint)"`
+	MinSize      int      `yaml:"minSize" description:"Minimum response size to compress" default:"1024"`
+	Encodings    []string `yaml:"encodings" description:"Allowed encodings in order of preference (br, zstd, gzip by default)"`
+	ContentTypes []string `yaml:"contentTypes" description:"Compressible content types (prefix match)"`
+}
+
+var defaultCompressibleTypes = []string{ //nolint:gochecknoglobals
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/xhtml+xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/wasm",
+	"image/svg+xml",
+	"font/ttf",
+	"font/otf",
+}
+
+type compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// compression holds negotiated encodings and encoder pools
+type compression struct {
+	cfg          CompressionConfig
+	encodings    []string
+	contentTypes []string
+	pools        map[string]*sync.Pool
+}
+
+func newCompression(cfg CompressionConfig) *compression {
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = defaultCompressionMinSize
+	}
+	c := &compression{
+		cfg:          cfg,
+		contentTypes: cfg.ContentTypes,
+		pools: map[string]*sync.Pool{
+			encodingBrotli: {New: func() any { return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression) }},
+			encodingZstd: {New: func() any {
+				w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+				return w
+			}},
+			encodingGzip: {New: func() any { return gzip.NewWriter(io.Discard) }},
+		},
+	}
+	for _, enc := range cfg.Encodings {
+		if enc = strings.ToLower(enc); knownEncoding(enc) && !slices.Contains(c.encodings, enc) {
+			c.encodings = append(c.encodings, enc)
+		}
+	}
+	if len(c.encodings) == 0 {
+		c.encodings = []string{encodingBrotli, encodingZstd, encodingGzip}
+	}
+	if len(c.contentTypes) == 0 {
+		c.contentTypes = defaultCompressibleTypes
+	}
+	return c
+}
+
+func knownEncoding(enc string) bool {
+	return enc == encodingBrotli || enc == encodingZstd || enc == encodingGzip
+}
+
+// negotiate selects the preferred server encoding among those accepted by the client.
+func (c *compression) negotiate(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		token, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(token))] = q
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range c.encodings {
+		q, ok := accepted[enc]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+func (c *compression) compressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range c.contentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func compressionMiddleware(next http.Handler, c *compression) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := c.negotiate(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, c: c, encoding: encoding, code: http.StatusOK}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	}
+}
+
+// compressWriter buffers the response until MinSize is reached and then decides whether to compress it
+type compressWriter struct {
+	http.ResponseWriter
+	c           *compression
+	encoding    string
+	code        int
+	wroteHeader bool // WriteHeader called by handler
+	decided     bool // compression decision made, headers sent
+	encoder     compressor
+	buf         bytes.Buffer
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.code = code
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusPartialContent {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.encoder != nil {
+			return cw.encoder.Write(p) //nolint:wrapcheck
+		}
+		return cw.ResponseWriter.Write(p) //nolint:wrapcheck
+	}
+
+	cw.buf.Write(p)
+	if cw.buf.Len() >= cw.c.cfg.MinSize {
+		cw.decide(cw.shouldCompress())
+		if err := cw.flushBuffer(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (cw *compressWriter) shouldCompress() bool {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false // already compressed
+	}
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < cw.c.cfg.MinSize {
+			return false
+		}
+	}
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(cw.buf.Bytes())
+		h.Set("Content-Type", ct)
+	}
+	if strings.HasPrefix(ct, "text/event-stream") {
+		return false // streaming
+	}
+	return cw.c.compressible(ct)
+}
+
+// decide sends the headers. The encoder is attached if compress is true.
+func (cw *compressWriter) decide(compress bool) {
+	if cw.decided {
+		return
+	}
+	cw.decided = true
+	if compress {
+		h := cw.Header()
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag) // compressed representation is not byte-identical
+		}
+		encoder, _ := cw.c.pools[cw.encoding].Get().(compressor)
+		encoder.Reset(cw.ResponseWriter)
+		cw.encoder = encoder
+	}
+	cw.ResponseWriter.WriteHeader(cw.code)
+}
+
+func (cw *compressWriter) flushBuffer() error {
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+	return err //nolint:wrapcheck
+}
+
+// Flush sends buffered data to the client. Responses flushed before reaching MinSize are considered streaming and left uncompressed.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.wroteHeader = true
+		cw.decide(false)
+		_ = cw.flushBuffer()
+	}
+	if gz, ok := cw.encoder.(interface{ Flush() error }); ok {
+		_ = gz.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close completes the response, returning the encoder to the pool.
+func (cw *compressWriter) Close() {
+	if !cw.decided {
+		if !cw.wroteHeader {
+			return // nothing written
+		}
+		cw.decide(cw.buf.Len() >= cw.c.cfg.MinSize && cw.shouldCompress())
+	}
+	_ = cw.flushBuffer()
+	if cw.encoder != nil {
+		_ = cw.encoder.Close()
+		cw.encoder.Reset(io.Discard)
+		cw.c.pools[cw.encoding].Put(cw.encoder)
+		cw.encoder = nil
+	}
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack() //nolint:wrapcheck
+	}
+	return nil, nil, errHijackNotSupported
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
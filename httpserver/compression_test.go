@@ -0,0 +1,78 @@
+package httpserver
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+func compressedHandler(body, contentType string) http.Handler {
+	c := newCompression(CompressionConfig{MinSize: 100})
+	return compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_, _ = io.WriteString(w, body)
+	}), c)
+}
+
+func request(h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCompression(t *testing.T) {
+	a := assert.New(t)
+	body := strings.Repeat("compress me ", 100)
+
+	rec := request(compressedHandler(body, "text/plain"), "gzip, deflate")
+	a.Equal("gzip", rec.Header().Get("Content-Encoding"))
+	a.Equal("Accept-Encoding", rec.Header().Get("Vary"))
+	zr, err := gzip.NewReader(rec.Body)
+	a.NoError(err)
+	decoded, _ := io.ReadAll(zr)
+	a.Equal(body, string(decoded))
+
+	rec = request(compressedHandler(body, "application/json"), "gzip;q=0.5, br")
+	a.Equal("br", rec.Header().Get("Content-Encoding"))
+	decoded, _ = io.ReadAll(brotli.NewReader(rec.Body))
+	a.Equal(body, string(decoded))
+}
+
+func TestCompressionSkipped(t *testing.T) {
+	a := assert.New(t)
+	body := strings.Repeat("x", 1000)
+
+	rec := request(compressedHandler("short", "text/plain"), "gzip")
+	a.Empty(rec.Header().Get("Content-Encoding"), "below minimum size")
+	a.Equal("short", rec.Body.String())
+
+	rec = request(compressedHandler(body, "image/png"), "gzip")
+	a.Empty(rec.Header().Get("Content-Encoding"), "content type not allowed")
+	a.Equal(body, rec.Body.String())
+
+	rec = request(compressedHandler(body, "text/plain"), "identity")
+	a.Empty(rec.Header().Get("Content-Encoding"), "encoding not accepted")
+	a.Equal(body, rec.Body.String())
+}
+
+func TestCompressionEncodings(t *testing.T) {
+	a := assert.New(t)
+
+	c := newCompression(CompressionConfig{Encodings: []string{"deflate", "Gzip", "gzip"}})
+	a.Equal([]string{"gzip"}, c.encodings)
+	a.Equal(defaultCompressionMinSize, c.cfg.MinSize, "config built in code gets the default")
+	a.Equal("gzip", c.negotiate("deflate, gzip"))
+
+	cfg := Config{Compression: CompressionConfig{Encodings: []string{"BR", "zstd"}}}
+	a.NoError(cfg.Validate())
+	cfg.Compression.Encodings = []string{"deflate", "gzip"}
+	a.ErrorContains(cfg.Validate(), `unknown encoding "deflate"`)
+}
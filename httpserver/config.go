@@ -1,14 +1,16 @@
 package httpserver
 
 import (
+	"crypto/tls"
 	"fmt"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/bhmj/goblocks/metrics"
 	"golang.org/x/time/rate"
 )
 
+// UnknownCNBehavior defines handling of client certificates not in the allowlist
 type UnknownCNBehavior string
 
 const (
@@ -19,22 +21,40 @@ const (
 
 // Config defines server parameters
 type Config struct {
-	Port             int            `yaml:"port" description:"Port number API listens on" default:"8080"`
-	StatsPort        int            `yaml:"statsPort" description:"Port number stats server listens on" default:"8081"`
-	UseTLS           bool           `yaml:"useTLS" description:"Use TLS for API calls"` //nolint:tagliatelle
-	TLSCert          string         `yaml:"tlsCert" description:"API TLS cert location"`
-	TLSKey           string         `yaml:"tlsKey" description:"API TLS key location"`
-	TLSCA            string         `yaml:"tlsCA" description:"Optional CA certificate"` //nolint:tagliatelle
-	TLSUseClientCert bool           `yaml:"tlsUseClientCert" description:"Require and verify client certificate"`
-	TLSClientCA      string         `yaml:"tlsClientCA" description:"Certificate Authority file for checking the authenticity of client"` //nolint:tagliatelle
-	CORS             bool           `yaml:"cors" description:"Allow CORS"`
-	Domain           string         `yaml:"domain" description:"Domain for CORS Access-Control-Allow-Origin header"`
-	Token            string         `yaml:"token" description:"Secret auth token"`
-	RateLimit        rate.Limit     `yaml:"rateLimit" description:"Rate limit (RPS)" default:"10000"`
-	OpenConnLimit    int            `yaml:"openConnLimit" description:"Open incoming connection limit" default:"1000"`
-	ReadTimeout      time.Duration  `yaml:"readTimeout" description:"Server read timeout (closes idle keep-alive connection)" default:"5m"`
-	ShutdownTimeout  time.Duration  `yaml:"shutdownTimeout" description:"Server shutdown timeout" default:"2s"`
-	Metrics          metrics.Config `yaml:"metrics" description:"Server metrics configuration"`
+	Port              int                   `yaml:"port" description:"Port number API listens on" default:"8080"`
+	StatsPort         int                   `yaml:"statsPort" description:"Port number stats server listens on" default:"8081"`
+	StatsToken        string                `yaml:"statsToken" description:"Token (Api-Token header) for stat server admin endpoints like /loglevel (disabled if empty)"`
+	UseTLS            bool                  `yaml:"useTLS" description:"Use TLS for API calls"` //nolint:tagliatelle
+	TLSCert           string                `yaml:"tlsCert" description:"API TLS cert location"`
+	TLSKey            string                `yaml:"tlsKey" description:"API TLS key location"`
+	TLSCA             string                `yaml:"tlsCA" description:"Optional CA certificate"` //nolint:tagliatelle
+	TLSCertificates   []CertificateConfig   `yaml:"tlsCertificates" description:"Additional certificates selected by SNI"`
+	TLSCertDir        string                `yaml:"tlsCertDir" description:"Directory of <name>.crt/<name>.key certificates selected by SNI"`
+	TLS               TLSParams             `yaml:"tls" description:"TLS protocol parameters"`
+	TLSUseClientCert  bool                  `yaml:"tlsUseClientCert" description:"Require and verify client certificate"`
+	TLSClientCA       string                `yaml:"tlsClientCA" description:"Certificate Authority file for checking the authenticity of client"` //nolint:tagliatelle
+	TLSAllowedClients []string              `yaml:"tlsAllowedClients" description:"Allowed client certificate CNs or SANs (wildcards permitted)"`
+	TLSUnknownCN      UnknownCNBehavior     `yaml:"tlsUnknownCN" description:"Behavior for clients not in the allowlist" default:"block" choices:"allow,warn,block"` //nolint:tagliatelle
+	TLSCRLFiles       []string              `yaml:"tlsCRL" description:"Certificate revocation lists (PEM or DER) to check client certificates against"`             //nolint:tagliatelle
+	TLSOCSPDir        string                `yaml:"tlsOCSPDir" description:"Directory of OCSP responses (<serial hex>.der) to check client certificates against"`    //nolint:tagliatelle
+	H2C               bool                  `yaml:"h2c" description:"Accept HTTP/2 over cleartext on non-TLS listeners (for running behind TLS-terminating proxy)"`
+	HTTP3             bool                  `yaml:"http3" description:"Run HTTP/3 (QUIC) listener on the same UDP port of every TLS listener"`
+	CORS              bool                  `yaml:"cors" description:"Allow CORS"`
+	Domain            string                `yaml:"domain" description:"Domain for CORS Access-Control-Allow-Origin header"`
+	Token             string                `yaml:"token" description:"Secret auth token (shortcut for a single auth.tokens entry)"`
+	Auth              AuthConfig            `yaml:"auth" description:"Authentication providers"`
+	CSRF              CSRFConfig            `yaml:"csrf" description:"CSRF protection of session endpoints"`
+	SecurityHeaders   SecurityHeadersConfig `yaml:"securityHeaders" description:"Security response headers (HSTS, CSP, ...)"`
+	AccessLog         AccessLogConfig       `yaml:"accessLog" description:"Access log (one line per request)"`
+	RateLimit         rate.Limit            `yaml:"rateLimit" description:"Rate limit (RPS)" default:"10000"`
+	OpenConnLimit     int                   `yaml:"openConnLimit" description:"Open incoming connection limit" default:"1000"`
+	ReadTimeout       time.Duration         `yaml:"readTimeout" description:"Server read timeout (closes idle keep-alive connection)" default:"5m"`
+	ShutdownTimeout   time.Duration         `yaml:"shutdownTimeout" description:"Server shutdown timeout" default:"2s"`
+	TLSReloadInterval time.Duration         `yaml:"tlsReloadInterval" description:"Check TLS certificate files for changes this often" default:"1m"`
+	ACME              ACMEConfig            `yaml:"acme" description:"Automatic TLS certificates (ACME)"`
+	Listeners         []ListenerConfig      `yaml:"listeners" description:"Listen addresses; if empty, a single TCP listener on Port with the TLS settings above is used"`
+	Compression       CompressionConfig     `yaml:"compression" description:"Response compression"`
+	Metrics           metrics.Config        `yaml:"metrics" description:"Server metrics configuration"`
 }
 
 // Validate checks the configuration for consistency.
@@ -42,40 +62,77 @@ func (t *Config) Validate() error {
 	if t.CORS && t.Domain == "" {
 		return fmt.Errorf("domain must be set when CORS is enabled")
 	}
-	return nil
-}
-
-// CertFile returns filename of TLS certificate containing
-// the server certificate alone or (for CA-based key) both
-// CA certificate and server certificate.
-func (t *Config) CertFile() (string, error) {
-	ca, err := os.ReadFile(t.TLSCA)
-	if os.IsNotExist(err) {
-		return t.TLSCert, nil
+	names := make(map[string]bool)
+	hasTLS := false
+	for _, l := range t.ListenerConfigs() {
+		if names[l.Name] {
+			return fmt.Errorf("duplicate listener name %q", l.Name)
+		}
+		names[l.Name] = true
+		switch l.Network {
+		case NetworkTCP, NetworkUnix, NetworkSystemd:
+		default:
+			return fmt.Errorf("listener %s: unknown network %q", l.Name, l.Network)
+		}
+		switch l.TLSUnknownCN {
+		case "", UCNAllow, UCNWarn, UCNBlock:
+		default:
+			return fmt.Errorf("listener %s: unknown tlsUnknownCN %q", l.Name, l.TLSUnknownCN)
+		}
+		hasTLS = hasTLS || l.TLSEnabled
+	}
+	if err := t.TLS.apply(&tls.Config{}); err != nil { //nolint:gosec
+		return fmt.Errorf("tls: %w", err)
 	}
-	if err != nil {
-		return "", fmt.Errorf("read CA certificate: %w", err)
+	if t.ACME.Enabled && (!hasTLS || len(t.ACME.Domains) == 0) {
+		return fmt.Errorf("acme requires TLS listener and at least one domain")
 	}
-	cert, err := os.ReadFile(t.TLSCert)
-	if err != nil {
-		return "", fmt.Errorf("read server certificate: %w", err)
+	switch t.CSRF.Mode {
+	case "", CSRFDoubleSubmit, CSRFSynchronizer:
+	default:
+		return fmt.Errorf("unknown csrf mode %q", t.CSRF.Mode)
 	}
-	f, err := os.CreateTemp("", "")
-	if err != nil {
-		return "", fmt.Errorf("create temporary file for CA and server certificate: %w", err)
+	for _, enc := range t.Compression.Encodings {
+		if !knownEncoding(strings.ToLower(enc)) {
+			return fmt.Errorf("compression: unknown encoding %q", enc)
+		}
 	}
-	defer f.Close()
-	_, err = f.Write(cert)
-	if err != nil {
-		return "", fmt.Errorf("append server certificate: %w", err)
+	if t.HTTP3 && !hasTLS {
+		return fmt.Errorf("http3 requires TLS")
 	}
-	_, err = f.WriteString("\n")
-	if err != nil {
-		return "", fmt.Errorf("append certificate separator: %w", err)
+	return nil
+}
+
+// ListenerConfigs returns effective listener list: either Listeners or a single listener built from Port and TLS settings.
+func (t *Config) ListenerConfigs() []ListenerConfig {
+	if len(t.Listeners) == 0 {
+		return []ListenerConfig{{
+			Name:              defaultListenerName,
+			Network:           NetworkTCP,
+			Address:           fmt.Sprintf(":%d", t.Port),
+			TLSEnabled:        t.UseTLS,
+			TLSCertFile:       t.TLSCert,
+			TLSKeyFile:        t.TLSKey,
+			TLSCAFile:         t.TLSCA,
+			TLSCertificates:   t.TLSCertificates,
+			TLSCertDir:        t.TLSCertDir,
+			TLSUseClientCert:  t.TLSUseClientCert,
+			TLSClientCAFile:   t.TLSClientCA,
+			TLSAllowedClients: t.TLSAllowedClients,
+			TLSUnknownCN:      t.TLSUnknownCN,
+			TLSCRLFiles:       t.TLSCRLFiles,
+			TLSOCSPDir:        t.TLSOCSPDir,
+		}}
 	}
-	_, err = f.Write(ca)
-	if err != nil {
-		return "", fmt.Errorf("append CA certificate: %w", err)
+	result := make([]ListenerConfig, len(t.Listeners))
+	for i, l := range t.Listeners {
+		if l.Network == "" {
+			l.Network = NetworkTCP
+		}
+		if l.Name == "" {
+			l.Name = l.Network + ":" + l.Address
+		}
+		result[i] = l
 	}
-	return f.Name(), f.Close()
+	return result
 }
@@ -0,0 +1,205 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bhmj/goblocks/apiauth"
+)
+
+// CSRF protection modes
+const (
+	CSRFDoubleSubmit = "double-submit" // random token in a cookie echoed in header or form field
+	CSRFSynchronizer = "synchronizer"  // token derived from the session ID
+)
+
+const csrfNonceSize = 32
+
+// CSRFConfig defines CSRF protection of session (SIDRequired) endpoints
+type CSRFConfig struct {
+	Enabled        bool     `yaml:"enabled" description:"Protect session (SIDRequired) endpoints against CSRF"`
+	Mode           string   `yaml:"mode" description:"Token mode" default:"double-submit" choices:"double-submit,synchronizer"`
+	Secret         string   `yaml:"secret" description:"Token signing key (random per process if empty, which breaks multi-instance setups)"`
+	CookieName     string   `yaml:"cookieName" description:"Double-submit token cookie name" default:"csrf_token"`
+	HeaderName     string   `yaml:"headerName" description:"Request and response header carrying the token" default:"X-CSRF-Token"`
+	FieldName      string   `yaml:"fieldName" description:"Form field carrying the token" default:"csrf_token"`
+	TrustedOrigins []string `yaml:"trustedOrigins" description:"Origins (scheme://host[:port]) allowed to send unsafe requests besides the server itself"`
+	InsecureCookie bool     `yaml:"insecureCookie" description:"Allow token cookie over plain HTTP (development only)"`
+}
+
+// sessionIdentifier is implemented by session data which provides the session ID (e.g. *session.Session)
+type sessionIdentifier interface {
+	SessionID() string
+}
+
+type csrf struct {
+	cfg     CSRFConfig
+	secret  []byte
+	trusted map[string]bool
+}
+
+func newCSRF(cfg CSRFConfig, corsDomain string) *csrf {
+	if cfg.Mode == "" {
+		cfg.Mode = CSRFDoubleSubmit
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "csrf_token"
+	}
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = "X-CSRF-Token"
+	}
+	if cfg.FieldName == "" {
+		cfg.FieldName = "csrf_token"
+	}
+	c := &csrf{cfg: cfg, secret: []byte(cfg.Secret), trusted: make(map[string]bool)}
+	if len(c.secret) == 0 {
+		c.secret = make([]byte, csrfNonceSize)
+		_, _ = rand.Read(c.secret)
+	}
+	for _, origin := range cfg.TrustedOrigins {
+		c.trusted[strings.TrimSuffix(origin, "/")] = true
+	}
+	if corsDomain != "" && corsDomain != "*" {
+		c.trusted[strings.TrimSuffix(corsDomain, "/")] = true
+	}
+	return c
+}
+
+// CSRFToken returns the CSRF token of the request to be embedded in forms or sent by JSON clients
+func CSRFToken(r *http.Request) string {
+	token, _ := r.Context().Value(ContextCSRFToken).(string)
+	return token
+}
+
+// csrfHandler exposes the token (context and response header) and verifies unsafe requests
+func csrfHandler(next HandlerWithResult, c *csrf) HandlerWithResult {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		sessionID := c.sessionID(r)
+		var token string
+		valid := false // the token is known to the client already
+		switch c.cfg.Mode {
+		case CSRFSynchronizer:
+			if sessionID != "" {
+				token = base64.RawURLEncoding.EncodeToString(c.sign("sync|" + sessionID))
+				valid = true
+			}
+		default:
+			if cookie, err := r.Cookie(c.cfg.CookieName); err == nil && c.validCookie(cookie.Value, sessionID) {
+				token, valid = cookie.Value, true
+			} else {
+				token = c.newCookieToken(sessionID)
+				http.SetCookie(w, &http.Cookie{
+					Name:     c.cfg.CookieName,
+					Value:    token,
+					Path:     "/",
+					Secure:   !c.cfg.InsecureCookie,
+					SameSite: http.SameSiteStrictMode,
+				})
+			}
+		}
+
+		if !safeMethod(r.Method) {
+			if err := c.checkOrigin(r); err != nil {
+				return http.StatusForbidden, err
+			}
+			if token == "" { // synchronizer without a session: no token can be verified
+				return http.StatusForbidden, apiauth.Forbidden("no session for CSRF token")
+			}
+			submitted := r.Header.Get(c.cfg.HeaderName)
+			if submitted == "" {
+				submitted = r.PostFormValue(c.cfg.FieldName)
+			}
+			if !valid || !hmac.Equal([]byte(submitted), []byte(token)) {
+				return http.StatusForbidden, apiauth.Forbidden("CSRF token mismatch")
+			}
+		}
+
+		if token != "" {
+			w.Header().Set(c.cfg.HeaderName, token)
+			r = r.WithContext(context.WithValue(r.Context(), ContextCSRFToken, token))
+		}
+		return next(w, r)
+	}
+}
+
+// checkOrigin verifies that an unsafe request comes from the server itself or a trusted origin.
+// Requests without Origin, Referer and Sec-Fetch-Site (non-browser clients) are checked by token only.
+func (c *csrf) checkOrigin(r *http.Request) error {
+	fetchSite := r.Header.Get("Sec-Fetch-Site")
+	if fetchSite == "same-origin" || fetchSite == "none" {
+		return nil
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		if u, err := url.Parse(r.Header.Get("Referer")); err == nil && u.Host != "" {
+			origin = u.Scheme + "://" + u.Host
+		}
+	}
+	if origin == "" {
+		if fetchSite != "" {
+			return apiauth.Forbidden("cross-site request")
+		}
+		return nil
+	}
+	if origin == requestOrigin(r) || c.trusted[origin] {
+		return nil
+	}
+	return apiauth.Forbidden("origin not allowed")
+}
+
+func (c *csrf) sessionID(r *http.Request) string {
+	if id, ok := r.Context().Value(ContextSessionData).(sessionIdentifier); ok {
+		return id.SessionID()
+	}
+	if r.Context().Value(ContextSessionData) != nil {
+		if cookie, err := r.Cookie("SID"); err == nil {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// newCookieToken returns nonce.signature where the signature binds the nonce to the session
+func (c *csrf) newCookieToken(sessionID string) string {
+	nonce := make([]byte, csrfNonceSize)
+	_, _ = rand.Read(nonce)
+	n := base64.RawURLEncoding.EncodeToString(nonce)
+	return n + "." + base64.RawURLEncoding.EncodeToString(c.sign(n+"|"+sessionID))
+}
+
+func (c *csrf) validCookie(value, sessionID string) bool {
+	nonce, signature, found := strings.Cut(value, ".")
+	if !found {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	return err == nil && hmac.Equal(sig, c.sign(nonce+"|"+sessionID))
+}
+
+func (c *csrf) sign(data string) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bhmj/goblocks/httpreply"
+	"github.com/bhmj/goblocks/log"
+	"github.com/bhmj/goblocks/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSession string
+
+func (s testSession) SessionID() string { return string(s) }
+
+func TestCSRF(t *testing.T) {
+	a := assert.New(t)
+
+	serviceMetrics := newMetrics(prometheus.NewRegistry(), metrics.Config{})
+	loader := SessionDataGetter(func(sid string) (any, error) {
+		return testSession(sid), nil
+	})
+	handler := func(w http.ResponseWriter, r *http.Request) (int, error) {
+		return httpreply.String(w, CSRFToken(r))
+	}
+	serve := func(c *csrf, req *http.Request) *httptest.ResponseRecorder {
+		req.AddCookie(&http.Cookie{Name: "SID", Value: "s1"})
+		w := httptest.NewRecorder()
+		instrumentationMiddleware(csrfHandler(handler, c), log.NewNop(), serviceMetrics, endpointInfo{service: "svc", endpoint: "form", session: loader})(w, req)
+		return w
+	}
+
+	// double-submit
+	c := newCSRF(CSRFConfig{TrustedOrigins: []string{"https://app.example.com"}}, "")
+	w := serve(c, httptest.NewRequest(http.MethodGet, "http://api.example.com/form", nil))
+	a.Equal(http.StatusOK, w.Code)
+	token := w.Header().Get("X-Csrf-Token")
+	a.NotEmpty(token)
+	a.Equal(token, w.Body.String(), "token is available to templates")
+	cookie := w.Result().Cookies()[0]
+	a.Equal("csrf_token", cookie.Name)
+	a.Equal(http.SameSiteStrictMode, cookie.SameSite)
+
+	post := func(header, field, origin string) *http.Request {
+		form := url.Values{}
+		if field != "" {
+			form.Set("csrf_token", field)
+		}
+		req := httptest.NewRequest(http.MethodPost, "http://api.example.com/form", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		if header != "" {
+			req.Header.Set("X-CSRF-Token", header)
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		return req
+	}
+	a.Equal(http.StatusOK, serve(c, post(token, "", "")).Code)
+	a.Equal(http.StatusOK, serve(c, post("", token, "http://api.example.com")).Code)
+	a.Equal(http.StatusOK, serve(c, post(token, "", "https://app.example.com")).Code)
+	a.Equal(http.StatusForbidden, serve(c, post("", "", "")).Code)
+	a.Equal(http.StatusForbidden, serve(c, post("forged", "", "")).Code)
+	w = serve(c, post(token, "", "https://evil.example.org"))
+	a.Equal(http.StatusForbidden, w.Code)
+	a.JSONEq(`{"error":"forbidden","reason":"origin not allowed"}`, w.Body.String())
+
+	req := post(token, "", "")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	a.Equal(http.StatusForbidden, serve(c, req).Code)
+	req = post(token, "", "")
+	req.Header.Set("Referer", "http://api.example.com/form")
+	a.Equal(http.StatusOK, serve(c, req).Code)
+
+	// cookie signed with another key
+	other := newCSRF(CSRFConfig{}, "")
+	req = post(other.newCookieToken("s1"), "", "")
+	a.Equal(http.StatusForbidden, serve(c, req).Code)
+
+	// synchronizer
+	c = newCSRF(CSRFConfig{Mode: CSRFSynchronizer, Secret: "k"}, "")
+	w = serve(c, httptest.NewRequest(http.MethodGet, "/form", nil))
+	token = w.Header().Get("X-Csrf-Token")
+	a.NotEmpty(token)
+	a.Empty(w.Result().Cookies())
+	req = httptest.NewRequest(http.MethodPost, "/form", nil)
+	req.Header.Set("X-CSRF-Token", token)
+	a.Equal(http.StatusOK, serve(c, req).Code)
+	a.Equal(http.StatusForbidden, serve(c, httptest.NewRequest(http.MethodPost, "/form", nil)).Code)
+
+	// synchronizer without a session
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/form", nil)
+	req.Header.Set("X-CSRF-Token", "")
+	code, err := csrfHandler(handler, c)(w, req)
+	a.Equal(http.StatusForbidden, code)
+	a.Error(err)
+	code, _ = csrfHandler(handler, c)(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/form", nil))
+	a.Equal(http.StatusOK, code, "safe methods pass")
+}
@@ -2,6 +2,7 @@ package httpserver
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
@@ -9,10 +10,11 @@ import (
 	"strings"
 
 	"github.com/bhmj/goblocks/apiauth"
-	"github.com/bhmj/goblocks/apiauth/token"
 	"github.com/bhmj/goblocks/log"
 	"github.com/bhmj/goblocks/metrics"
 	sentryhttp "github.com/getsentry/sentry-go/http"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/crypto/acme/autocert"
 	"golang.org/x/time/rate"
 )
 
@@ -26,26 +28,81 @@ type Router interface {
 	HandleFunc(method, pattern string, handler func(http.ResponseWriter, *http.Request))
 }
 
+// PrefixRouter is an optional Router extension required by endpoints with PathPrefix set.
+// HandlePrefix registers the handler for the prefix and all nested paths.
+type PrefixRouter interface {
+	HandlePrefix(method, prefix string, handler func(http.ResponseWriter, *http.Request))
+}
+
+var errPrefixNotSupported = errors.New("router does not support prefix routes")
+
 const rateLimitBurstRatio = float64(1.2) // allow this % bursts of incoming requests
 
 // Server implements basic Kube-dispatched HTTP server
 type Server interface {
 	Run(ctx context.Context) error
-	HandleFunc(service, endpoint, method, path string, handler HandlerWithResult, sessionGetter SessionDataGetter)
+	HandleFunc(service, endpoint, method, path string, handler HandlerWithResult, opts EndpointOptions) error
+}
+
+// SessionLoader loads the session of the request. It may refresh the session cookie.
+type SessionLoader interface {
+	LoadSession(w http.ResponseWriter, r *http.Request) (any, error)
 }
 
 // SessionDataGetter is a function which returns session data extracted from the storage using SID cookie.
 type SessionDataGetter func(SID string) (any, error)
 
+// LoadSession implements SessionLoader
+func (g SessionDataGetter) LoadSession(_ http.ResponseWriter, r *http.Request) (any, error) {
+	cookie, err := r.Cookie("SID")
+	if err != nil {
+		return nil, fmt.Errorf("SID cookie: %w", err)
+	}
+	return g(cookie.Value)
+}
+
+// EndpointOptions defines per-endpoint handling options
+type EndpointOptions struct {
+	Session         SessionLoader     // if set, session data is loaded and passed via context (ContextSessionData)
+	SessionStrict   bool              // reply 401 if there is no valid session
+	SecurityHeaders map[string]string // security header overrides (empty value removes the header)
+	CSRFExempt      bool              // skip CSRF protection of the session endpoint
+	PathPrefix      bool              // path is a prefix matching all nested paths (e.g. static files)
+	Compression     CompressionMode   // response compression override
+	Listeners       []string          // if set, the endpoint is served only on the listeners with these names
+	Auth            []string          // auth providers chain (AuthToken, AuthJWT, ...), overrides the default one
+	NoAuth          bool              // public endpoint
+	Roles           []string          // principal must have any of these roles
+	Scopes          []string          // principal must have all of these scopes
+	Policy          apiauth.Policy    // custom authorization hook
+}
+
 type httpserver struct {
 	name    string
 	cfg     Config
 	router  Router
-	server  *http.Server
 	logger  log.MetaLogger
 	metrics *serviceMetrics
 
-	listener net.Listener
+	compression *compression
+	auth        *authenticator
+	csrf        *csrf      // nil if CSRF protection is off
+	accessLog   *accessLog // nil if access log is off
+	listeners   []*listener
+	tlsMetrics  *tlsMetrics
+	acmeServer  *http.Server // HTTP-01 challenge server (if enabled)
+}
+
+// listener is a configured listen address with its own http.Server
+type listener struct {
+	cfg        ListenerConfig
+	ln         net.Listener
+	tlsConfig  *tls.Config        // nil if TLS is off
+	certs      *CertificateGetter // file based certificates (nil for ACME or no TLS)
+	revocation *revocation        // revocation lists of an ACME listener (file based ones are reloaded with certs)
+	server     *http.Server
+	h3server   *http3.Server  // HTTP/3 server (if enabled)
+	h3conn     net.PacketConn // HTTP/3 UDP listener
 }
 
 // NewServer returns an HTTP server
@@ -61,10 +118,6 @@ func NewServer(
 
 	connWatcher := NewConnectionWatcher(metricsRegistry.Get(), logger)
 	rateLimiter := rate.NewLimiter(cfg.RateLimit, int(float64(cfg.RateLimit)*rateLimitBurstRatio))
-	var authProvider apiauth.Auth
-	if cfg.Token != "" {
-		authProvider = token.New(cfg.Token)
-	}
 
 	// middlewares sequence (in order of execution during request handling):
 	//
@@ -73,53 +126,116 @@ func NewServer(
 	// CORS middleware (if enabled) ->
 	// connection limiting ->
 	// rate limiting ->
-	// authentication ->
-	// sentry handler ->
-	// authentication ->
 	// sentry handler ->
 	// panic logging (logs panic and repanics for sentry) ->
 	//
 	// -> ROUTER (determine the necessity of further processing)
 	//
-	// instrumentation = request ID + logging + metrics + errorer
+	// security headers (if enabled) ->
+	// response compression (if enabled) ->
+	// instrumentation = request ID + logging + access log + metrics + errorer ->
+	// CSRF protection (session endpoints) ->
+	// authentication (per endpoint chain of providers) ->
+	// authorization (roles, scopes, policy) ->
 	//
 	// -> SERVICE HANDLER
 
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
+	auth, err := newAuthenticator(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("auth: %w", err)
+	}
 
 	safetyWrappers := func(router Router) http.Handler {
 		var handler http.Handler
 		handler = panicLoggerMiddleware(router, logger)
 		handler = sentryHandler.Handle(handler)
-		handler = authMiddleware(handler, authProvider)
-		handler = rateLimiterMiddleware(handler, rateLimiter)
-		handler = connLimiterMiddleware(handler, connWatcher, cfg.OpenConnLimit)
+		handler = rateLimiterMiddleware(handler, rateLimiter, metrics)
+		handler = connLimiterMiddleware(handler, connWatcher, cfg.OpenConnLimit, metrics)
 		if cfg.CORS {
-			handler = corsMiddleware(handler, cfg.Domain)
+			handler = corsMiddleware(handler, cfg.Domain, cfg.CSRF)
 		}
 		return handler
 	}
 
+	handler := safetyWrappers(router)
+
 	srv := &httpserver{
-		name:    "http",
-		logger:  logger,
-		metrics: metrics,
-		cfg:     cfg,
-		router:  router,
-		server: &http.Server{
+		name:        "http",
+		logger:      logger,
+		metrics:     metrics,
+		cfg:         cfg,
+		router:      router,
+		compression: newCompression(cfg.Compression),
+		auth:        auth,
+		tlsMetrics:  newTLSMetrics(metricsRegistry.Get()),
+	}
+	if cfg.CSRF.Enabled {
+		srv.csrf = newCSRF(cfg.CSRF, cfg.Domain)
+	}
+	if cfg.AccessLog.Enabled {
+		if srv.accessLog, err = newAccessLog(cfg.AccessLog, logger); err != nil {
+			return nil, fmt.Errorf("access log: %w", err)
+		}
+	}
+
+	var acm *autocert.Manager
+	if cfg.ACME.Enabled {
+		var err error
+		if acm, err = newACMEManager(cfg.ACME); err != nil {
+			return nil, fmt.Errorf("acme: %w", err)
+		}
+		if cfg.ACME.HTTPAddress != "" {
+			srv.acmeServer = &http.Server{
+				Addr:              cfg.ACME.HTTPAddress,
+				Handler:           acm.HTTPHandler(nil),
+				ReadHeaderTimeout: cfg.ReadTimeout,
+			}
+		}
+	}
+
+	var protocols *http.Protocols
+	if cfg.H2C {
+		protocols = new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+	}
+
+	for _, lnConfig := range cfg.ListenerConfigs() {
+		l, err := newListener(lnConfig, cfg.TLS, acm)
+		if err != nil {
+			srv.closeListeners()
+			logger.Error(err.Error(), log.String("listener", lnConfig.Name))
+			return nil, fmt.Errorf("init listener %s: %w", lnConfig.Name, err)
+		}
+		srv.listeners = append(srv.listeners, l)
+
+		name := lnConfig.Name
+		lnHandler := handler
+		if l.tlsConfig != nil {
+			lnHandler = clientCertMiddleware(handler, lnConfig, logger)
+		}
+		l.server = &http.Server{
 			ReadTimeout: cfg.ReadTimeout,
 			ConnState:   connWatcher.OnStateChange,
-			Handler:     safetyWrappers(router),
-		},
-	}
+			Handler:     lnHandler,
+			Protocols:   protocols,
+			BaseContext: func(net.Listener) context.Context {
+				return context.WithValue(context.Background(), ContextListener, name)
+			},
+		}
 
-	var err error
-	srv.listener, err = InitListener(cfg)
-	if err != nil {
-		logger.Error(err.Error())
-		return nil, fmt.Errorf("init listener: %w", err)
+		if cfg.HTTP3 && l.tlsConfig != nil && lnConfig.Network == NetworkTCP {
+			l.h3server, l.h3conn, err = newHTTP3Server(cfg, lnConfig.Address, name, lnHandler, l.tlsConfig)
+			if err != nil {
+				srv.closeListeners()
+				return nil, fmt.Errorf("init http3 listener %s: %w", lnConfig.Name, err)
+			}
+			l.server.Handler = altSvcMiddleware(lnHandler, l.h3server)
+		}
 	}
 
 	return srv, nil
@@ -127,41 +243,137 @@ func NewServer(
 
 // Run the server
 func (s *httpserver) Run(ctx context.Context) error {
-	s.server.Addr = fmt.Sprintf(":%d", s.cfg.Port)
-	s.logger.Info("starting server",
-		log.String("name", s.name),
-		log.Bool("tls", s.cfg.UseTLS),
-		log.Bool("client_auth", s.cfg.TLSUseClientCert),
-		log.Int("port", s.cfg.Port),
-	)
-
-	errCh := make(chan error, 1)
-	go func() {
-		errCh <- s.server.Serve(s.listener)
-	}()
+	errCh := make(chan error, 2*len(s.listeners)+1) //nolint:mnd
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+	if s.acmeServer != nil {
+		s.logger.Info("starting ACME challenge server", log.String("name", s.name), log.String("address", s.acmeServer.Addr))
+		go func() {
+			errCh <- s.acmeServer.ListenAndServe()
+		}()
+	}
+	for _, l := range s.listeners {
+		s.logger.Info("starting server",
+			log.String("name", s.name),
+			log.String("listener", l.cfg.Name),
+			log.String("network", l.cfg.Network),
+			log.String("address", l.cfg.Address),
+			log.Bool("tls", l.cfg.TLSEnabled),
+			log.Bool("client_auth", l.cfg.TLSUseClientCert),
+			log.Bool("h2c", s.cfg.H2C && !l.cfg.TLSEnabled),
+			log.Bool("http3", l.h3server != nil),
+		)
+		go func() {
+			errCh <- l.server.Serve(l.ln)
+		}()
+		if l.certs != nil && s.cfg.TLSReloadInterval > 0 {
+			go watchCertificate(watchCtx, l, s.cfg.TLSReloadInterval, s.tlsMetrics, s.logger)
+		}
+		if l.revocation != nil && s.cfg.TLSReloadInterval > 0 {
+			go watchRevocation(watchCtx, l, s.cfg.TLSReloadInterval, s.tlsMetrics, s.logger)
+		}
+		if l.h3server != nil {
+			go func() {
+				err := l.h3server.Serve(l.h3conn)
+				if err != nil && !errors.Is(err, http.ErrServerClosed) {
+					errCh <- fmt.Errorf("http3: %w", err)
+				}
+			}()
+		}
+	}
 
 	select {
 	case err := <-errCh:
+		s.shutdown()
 		if errors.Is(err, http.ErrServerClosed) {
 			s.logger.Info("server closed", log.String("name", s.name))
 			return nil
 		}
 		return fmt.Errorf("failed to start server: %w", err)
 	case <-ctx.Done():
-		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
-		defer cancel()
-		err := s.server.Shutdown(ctx) //nolint:contextcheck
-		if err != nil {
-			s.logger.Error("failed to shutdown server", log.String("name", s.name), log.Error(err))
-		}
+		s.shutdown()
 		return nil
 	}
 }
 
-func (s *httpserver) HandleFunc(service, endpoint, method, path string, handler HandlerWithResult, sessionGetter SessionDataGetter) {
-	s.router.HandleFunc(
-		method,
-		"/"+strings.TrimPrefix(path, "/"),
-		instrumentationMiddleware(handler, s.logger, s.metrics, service, endpoint, sessionGetter),
-	)
+// shutdown gracefully stops all listeners
+func (s *httpserver) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+	if s.acmeServer != nil {
+		if err := s.acmeServer.Shutdown(ctx); err != nil {
+			s.logger.Error("failed to shutdown ACME challenge server", log.String("name", s.name), log.Error(err))
+		}
+	}
+	for _, l := range s.listeners {
+		if l.h3server != nil {
+			if err := l.h3server.Shutdown(ctx); err != nil {
+				s.logger.Error("failed to shutdown http3 server", log.String("name", s.name), log.String("listener", l.cfg.Name), log.Error(err))
+			}
+		}
+		if err := l.server.Shutdown(ctx); err != nil {
+			s.logger.Error("failed to shutdown server", log.String("name", s.name), log.String("listener", l.cfg.Name), log.Error(err))
+		}
+	}
+	if s.accessLog != nil {
+		s.accessLog.close()
+	}
+}
+
+// closeListeners releases listeners on initialization failure
+func (s *httpserver) closeListeners() {
+	for _, l := range s.listeners {
+		if l.ln != nil {
+			l.ln.Close()
+		}
+		if l.h3conn != nil {
+			l.h3conn.Close()
+		}
+	}
+}
+
+// HandleFunc registers the endpoint handler wrapped with the middleware chain. Configuration errors
+// (e.g. unknown auth provider) are returned so that the service fails at startup.
+func (s *httpserver) HandleFunc(service, endpoint, method, path string, handler HandlerWithResult, opts EndpointOptions) error {
+	path = "/" + strings.TrimPrefix(path, "/")
+	prefixRouter, ok := s.router.(PrefixRouter)
+	if opts.PathPrefix && !ok {
+		return fmt.Errorf("endpoint %s/%s: %w", service, endpoint, errPrefixNotSupported)
+	}
+	chain, err := s.auth.endpointChain(opts)
+	if err != nil {
+		return fmt.Errorf("endpoint %s/%s: %w", service, endpoint, err)
+	}
+	if requirement := (apiauth.Requirement{Roles: opts.Roles, Scopes: opts.Scopes}); !requirement.Empty() || opts.Policy != nil {
+		handler = authorizeHandler(handler, requirement, opts.Policy)
+	}
+	if len(chain) > 0 {
+		handler = authHandler(handler, chain)
+	}
+	if s.csrf != nil && opts.Session != nil && !opts.CSRFExempt {
+		handler = csrfHandler(handler, s.csrf)
+	}
+	handlerFunc := instrumentationMiddleware(handler, s.logger, s.metrics, endpointInfo{
+		service:       service,
+		endpoint:      endpoint,
+		route:         path,
+		session:       opts.Session,
+		sessionStrict: opts.SessionStrict,
+		accessLog:     s.accessLog,
+	})
+	if opts.Compression == CompressOn || (opts.Compression == CompressDefault && s.cfg.Compression.Enabled) {
+		handlerFunc = compressionMiddleware(handlerFunc, s.compression)
+	}
+	if s.cfg.SecurityHeaders.Enabled {
+		handlerFunc = securityHeadersMiddleware(handlerFunc, s.cfg.SecurityHeaders, opts.SecurityHeaders)
+	}
+	if len(opts.Listeners) > 0 {
+		handlerFunc = listenerFilterMiddleware(handlerFunc, opts.Listeners)
+	}
+	if opts.PathPrefix {
+		prefixRouter.HandlePrefix(method, path, handlerFunc)
+		return nil
+	}
+	s.router.HandleFunc(method, path, handlerFunc)
+	return nil
 }
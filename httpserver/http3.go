@@ -0,0 +1,38 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3Server creates HTTP/3 (QUIC) server listening on the UDP port of the same address as the TLS listener.
+func newHTTP3Server(cfg Config, addr, listenerName string, handler http.Handler, tlsConfig *tls.Config) (*http3.Server, net.PacketConn, error) {
+	conn, err := net.ListenPacket("udp", addr) //nolint:noctx
+	if err != nil {
+		return nil, nil, fmt.Errorf("udp listener: %w", err)
+	}
+	server := &http3.Server{
+		Addr:        addr,
+		Handler:     handler,
+		TLSConfig:   http3.ConfigureTLSConfig(tlsConfig),
+		IdleTimeout: cfg.ReadTimeout,
+		ConnContext: func(ctx context.Context, _ *quic.Conn) context.Context {
+			return context.WithValue(ctx, ContextListener, listenerName)
+		},
+	}
+	return server, conn, nil
+}
+
+// altSvcMiddleware advertises HTTP/3 availability to HTTP/1.1 and HTTP/2 clients
+func altSvcMiddleware(next http.Handler, server *http3.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = server.SetQUICHeaders(w.Header())
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,151 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bhmj/goblocks/gorillarouter"
+	"github.com/bhmj/goblocks/httpreply"
+	"github.com/bhmj/goblocks/log"
+	"github.com/bhmj/goblocks/metrics"
+	sentryhttp "github.com/getsentry/sentry-go/http"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+)
+
+// startServer runs the server with a single /ping endpoint replying with the protocol version and the listener name
+func startServer(t *testing.T, cfg Config) (*httpserver, *metrics.Registry) {
+	t.Helper()
+	cfg.RateLimit = 1000
+	cfg.OpenConnLimit = 100
+	cfg.ShutdownTimeout = time.Second
+	registry, err := metrics.NewRegistry(metrics.Config{Namespace: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(cfg, metrics.Config{}, gorillarouter.New(), log.NewNop(), registry, sentryhttp.New(sentryhttp.Options{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.(*httpserver) //nolint:forcetypeassert
+	ping := func(w http.ResponseWriter, r *http.Request) (int, error) {
+		listener, _ := r.Context().Value(ContextListener).(string)
+		return httpreply.String(w, fmt.Sprintf("%d %s", r.ProtoMajor, listener))
+	}
+	if err := srv.HandleFunc("svc", "ping", http.MethodGet, "/ping", ping, EndpointOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = srv.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return srv, registry
+}
+
+func get(client *http.Client, url string) (*http.Response, string, error) {
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp, string(body), err
+}
+
+func TestH2C(t *testing.T) {
+	a := assert.New(t)
+
+	srv, _ := startServer(t, Config{H2C: true, Listeners: []ListenerConfig{{Name: "api", Address: "127.0.0.1:0"}}})
+	transport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+	resp, body, err := get(client, "http://"+srv.listeners[0].ln.Addr().String()+"/ping")
+	if !a.NoError(err) {
+		return
+	}
+	a.Equal(http.StatusOK, resp.StatusCode)
+	a.Equal("2 api", body, "HTTP/2 over cleartext")
+}
+
+func TestHTTP3(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeSelfSigned(t, certFile, keyFile, "localhost", time.Now().Add(time.Hour))
+	certPEM, _ := os.ReadFile(certFile)
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+	tlsConfig := &tls.Config{RootCAs: roots, ServerName: "localhost", MinVersion: tls.VersionTLS12}
+
+	// HTTP/3 runs on the UDP port of the TLS listener, so the port is fixed in advance
+	freePort := func() int {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		return ln.Addr().(*net.TCPAddr).Port //nolint:forcetypeassert
+	}
+	tlsListener := func() []ListenerConfig {
+		addr := "127.0.0.1:" + strconv.Itoa(freePort())
+		return []ListenerConfig{{Name: "api", Address: addr, TLSEnabled: true, TLSCertFile: certFile, TLSKeyFile: keyFile}}
+	}
+	tcpTransport := &http.Transport{TLSClientConfig: tlsConfig, ForceAttemptHTTP2: true}
+	defer tcpTransport.CloseIdleConnections()
+	client := &http.Client{Transport: tcpTransport}
+
+	srv, _ := startServer(t, Config{Listeners: tlsListener()})
+	resp, _, err := get(client, "https://"+srv.listeners[0].cfg.Address+"/ping")
+	if !a.NoError(err) {
+		return
+	}
+	a.Empty(resp.Header.Get("Alt-Svc"), "HTTP/3 is off")
+
+	srv, registry := startServer(t, Config{HTTP3: true, Listeners: tlsListener()})
+	addr := srv.listeners[0].cfg.Address
+	_, port, _ := net.SplitHostPort(addr)
+	resp, body, err := get(client, "https://"+addr+"/ping")
+	if !a.NoError(err) {
+		return
+	}
+	a.Equal("2 api", body)
+	a.Contains(resp.Header.Get("Alt-Svc"), `h3=":`+port+`"`)
+
+	transport := &http3.Transport{TLSClientConfig: tlsConfig}
+	defer transport.Close()
+	resp, body, err = get(&http.Client{Transport: transport}, "https://"+addr+"/ping")
+	if !a.NoError(err) {
+		return
+	}
+	a.Equal("3 api", body, "QUIC listener serves the same router with the listener context")
+	a.NotEmpty(resp.Header.Get("X-Request-ID"), "QUIC requests pass the middleware chain")
+
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	a.Contains(rec.Body.String(), `test_httpserver_requests_total{code="2xx",endpoint="ping",method="GET",service="svc"} 2`, "both requests are counted")
+}
@@ -0,0 +1,187 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	NetworkTCP     = "tcp"
+	NetworkUnix    = "unix"
+	NetworkSystemd = "systemd" // socket activation (LISTEN_FDS)
+
+	defaultListenerName   = "api"
+	defaultSocketFileMode = 0o660
+	unixDialTimeout       = time.Second
+	systemdFirstFD        = 3 // SD_LISTEN_FDS_START
+)
+
+var (
+	errNoSystemdSockets = errors.New("no sockets passed by systemd (LISTEN_FDS)")
+	errSystemdSocket    = errors.New("systemd socket not found")
+	errSocketInUse      = errors.New("socket file exists and is not a socket")
+	errSocketActive     = errors.New("socket is in use by another process")
+)
+
+// ListenerConfig defines a listen address of the API server
+type ListenerConfig struct {
+	Name              string              `yaml:"name" description:"Listener name (used in logs and to restrict endpoints to certain listeners)"`
+	Network           string              `yaml:"network" description:"Listener type: tcp, unix or systemd" default:"tcp" choices:"tcp,unix,systemd"`
+	Address           string              `yaml:"address" description:"[host]:port for tcp, socket path for unix, socket name (LISTEN_FDNAMES) or index for systemd"`
+	FileMode          string              `yaml:"fileMode" description:"Unix socket file mode (octal)" default:"0660"`
+	TLSEnabled        bool                `yaml:"useTLS" description:"Use TLS"` //nolint:tagliatelle
+	TLSCertFile       string              `yaml:"tlsCert" description:"TLS cert location"`
+	TLSKeyFile        string              `yaml:"tlsKey" description:"TLS key location"`
+	TLSCAFile         string              `yaml:"tlsCA" description:"Optional CA certificate"` //nolint:tagliatelle
+	TLSCertificates   []CertificateConfig `yaml:"tlsCertificates" description:"Additional certificates selected by SNI"`
+	TLSCertDir        string              `yaml:"tlsCertDir" description:"Directory of <name>.crt/<name>.key certificates selected by SNI"`
+	TLSUseClientCert  bool                `yaml:"tlsUseClientCert" description:"Require and verify client certificate"`
+	TLSClientCAFile   string              `yaml:"tlsClientCA" description:"Certificate Authority file for checking the authenticity of client"` //nolint:tagliatelle
+	TLSAllowedClients []string            `yaml:"tlsAllowedClients" description:"Allowed client certificate CNs or SANs (wildcards permitted)"`
+	TLSUnknownCN      UnknownCNBehavior   `yaml:"tlsUnknownCN" description:"Behavior for clients not in the allowlist: allow, warn or block (default)"`         //nolint:tagliatelle
+	TLSCRLFiles       []string            `yaml:"tlsCRL" description:"Certificate revocation lists (PEM or DER) to check client certificates against"`          //nolint:tagliatelle
+	TLSOCSPDir        string              `yaml:"tlsOCSPDir" description:"Directory of OCSP responses (<serial hex>.der) to check client certificates against"` //nolint:tagliatelle
+}
+
+// InitListener preloads certificates and returns a configured net.Listener
+// for the first of the configured listeners.
+func InitListener(cfg Config) (net.Listener, error) {
+	l, err := newListener(cfg.ListenerConfigs()[0], cfg.TLS, nil)
+	if err != nil {
+		return nil, err
+	}
+	return l.ln, nil
+}
+
+// newListener creates and configures net.Listener. TLS certificates are obtained via ACME if acm is set.
+func newListener(lnConfig ListenerConfig, params TLSParams, acm *autocert.Manager) (*listener, error) {
+	l := &listener{cfg: lnConfig}
+	var err error
+	switch lnConfig.Network {
+	case NetworkUnix:
+		l.ln, err = listenUnix(lnConfig.Address, lnConfig.FileMode)
+	case NetworkSystemd:
+		l.ln, err = listenSystemd(lnConfig.Address)
+	default:
+		l.ln, err = net.Listen("tcp", lnConfig.Address) //nolint:noctx
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listener: %w", err)
+	}
+
+	if !lnConfig.TLSEnabled {
+		return l, nil
+	}
+
+	rv := newRevocation(lnConfig.TLSCRLFiles, lnConfig.TLSOCSPDir)
+	if acm != nil {
+		l.tlsConfig, err = acmeTLSConfig(&l.cfg, params, acm)
+		if err == nil && rv != nil {
+			err = rv.load()
+			l.revocation = rv
+		}
+	} else {
+		l.certs = NewCertificateGetter(&l.cfg)
+		l.tlsConfig, err = prepareTLSConfig(&l.cfg, params, l.certs)
+		rv = l.certs.revocation
+	}
+	if err != nil {
+		l.ln.Close()
+		return nil, fmt.Errorf("prepare TLS config: %w", err)
+	}
+	if rv != nil {
+		l.tlsConfig.VerifyConnection = rv.verifyConnection
+	}
+	l.ln = tls.NewListener(l.ln, l.tlsConfig)
+
+	return l, nil
+}
+
+// listenUnix creates unix domain socket, removing the stale socket file left from the previous run.
+// A socket accepting connections belongs to a live instance and is never removed.
+func listenUnix(path, fileMode string) (net.Listener, error) {
+	mode := fs.FileMode(defaultSocketFileMode)
+	if fileMode != "" {
+		m, err := strconv.ParseUint(fileMode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid socket file mode %q: %w", fileMode, err)
+		}
+		mode = fs.FileMode(m)
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("%w: %s", errSocketInUse, path)
+		}
+		conn, err := net.DialTimeout("unix", path, unixDialTimeout)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: %s", errSocketActive, path)
+		}
+		if !errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, fmt.Errorf("check existing socket: %w", err)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path) //nolint:noctx
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("chmod socket: %w", err)
+	}
+	return ln, nil
+}
+
+// listenSystemd returns a listener passed by systemd socket activation. The socket is selected
+// by its name (FileDescriptorName= in the socket unit) or by index.
+func listenSystemd(address string) (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, errNoSystemdSockets
+	}
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds <= 0 {
+		return nil, errNoSystemdSockets
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	index := -1
+	for i := range nfds {
+		if i < len(names) && names[i] == address {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		if n, err := strconv.Atoi(address); err == nil && n >= 0 && n < nfds {
+			index = n
+		} else if address == "" {
+			index = 0
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("%w: %s", errSystemdSocket, address)
+	}
+
+	f := os.NewFile(uintptr(systemdFirstFD+index), "systemd:"+address)
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("systemd socket %s: %w", address, err)
+	}
+	return ln, nil
+}
@@ -0,0 +1,77 @@
+package httpserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerConfigs(t *testing.T) {
+	a := assert.New(t)
+
+	cfg := Config{Port: 8080, UseTLS: true, TLSCert: "cert.pem"}
+	listeners := cfg.ListenerConfigs()
+	a.Len(listeners, 1)
+	a.Equal(ListenerConfig{Name: "api", Network: NetworkTCP, Address: ":8080", TLSEnabled: true, TLSCertFile: "cert.pem"}, listeners[0])
+
+	cfg.Listeners = []ListenerConfig{{Address: "127.0.0.1:9000"}, {Name: "admin", Network: NetworkUnix, Address: "/tmp/admin.sock"}}
+	listeners = cfg.ListenerConfigs()
+	a.Equal("tcp:127.0.0.1:9000", listeners[0].Name)
+	a.Equal(NetworkTCP, listeners[0].Network)
+	a.Equal("admin", listeners[1].Name)
+
+	cfg.Listeners = append(cfg.Listeners, ListenerConfig{Name: "admin", Address: ":9001"})
+	a.Error(cfg.Validate(), "duplicate name")
+}
+
+func TestUnixListener(t *testing.T) {
+	a := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "api.sock")
+	l, err := newListener(ListenerConfig{Network: NetworkUnix, Address: path, FileMode: "0600"}, TLSParams{}, nil)
+	a.NoError(err)
+	info, err := os.Stat(path)
+	a.NoError(err)
+	a.Equal(os.FileMode(0o600), info.Mode().Perm())
+
+	server := &http.Server{
+		Handler: listenerFilterMiddleware(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = io.WriteString(w, "admin only")
+		}, []string{"admin"}),
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), ContextListener, "admin")
+		},
+	}
+	go server.Serve(l.ln) //nolint:errcheck
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://unix/")
+	a.NoError(err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	a.Equal("admin only", string(body))
+
+	_, err = newListener(ListenerConfig{Network: NetworkUnix, Address: path}, TLSParams{}, nil)
+	a.ErrorIs(err, errSocketActive, "live socket is not taken over")
+
+	// stale socket left by a crashed process
+	stale := filepath.Join(t.TempDir(), "stale.sock")
+	ln, err := net.Listen("unix", stale)
+	a.NoError(err)
+	ln.(*net.UnixListener).SetUnlinkOnClose(false) //nolint:forcetypeassert
+	ln.Close()
+	l, err = newListener(ListenerConfig{Network: NetworkUnix, Address: stale}, TLSParams{}, nil)
+	a.NoError(err)
+	l.ln.Close()
+}
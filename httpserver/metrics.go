@@ -1,6 +1,8 @@
 package httpserver
 
 import (
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/bhmj/goblocks/metrics"
@@ -8,14 +10,43 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// Rejection reasons
+const (
+	RejectRateLimit    = "rate_limit"
+	RejectConnLimit    = "conn_limit"
+	RejectUnauthorized = "unauthorized"
+	RejectForbidden    = "forbidden"
+
+	nativeBucketFactor   = 1.1
+	nativeMaxBuckets     = 160
+	nativeMinResetPeriod = time.Hour
+)
+
 type serviceMetrics struct {
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	inFlight     *prometheus.GaugeVec
+	requestSize  *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+	rejected     *prometheus.CounterVec
+	// old names (metrics.Config.LegacyNames)
 	errorsCounter *prometheus.CounterVec
 	latency       *prometheus.HistogramVec
 }
 
+// requestScore is a single request measurement
+type requestScore struct {
+	service, endpoint, method string
+	status                    int
+	begin                     time.Time
+	requestSize, responseSize int64
+	err                       error
+	traceID                   string // exemplar of request count and duration (OpenMetrics)
+}
+
 func newMetrics(metricsRegistry prometheus.Registerer, conf metrics.Config) *serviceMetrics {
 	metrics := &serviceMetrics{}
-	factory := promauto.With(metricsRegistry)
+	factory := promauto.With(prometheus.WrapRegistererWithPrefix("httpserver_", metricsRegistry))
 
 	defaultBuckets := []float64{
 		0.002, 0.004, 0.006, 0.008, 0.010, 0.020, 0.050, 0.100, 0.200, 0.300, 0.500, 0.700, 0.900, 1.100, 1.300, 1.500,
@@ -26,31 +57,137 @@ func newMetrics(metricsRegistry prometheus.Registerer, conf metrics.Config) *ser
 	} else {
 		buckets = defaultBuckets
 	}
+	sizeBuckets := conf.SizeBuckets
+	if len(sizeBuckets) == 0 {
+		sizeBuckets = prometheus.ExponentialBuckets(64, 4, 9) //nolint:mnd // 64B .. 4MB
+	}
+	histogram := func(name, help string, buckets []float64) prometheus.HistogramOpts {
+		opts := prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}
+		if conf.NativeHistograms {
+			opts.NativeHistogramBucketFactor = nativeBucketFactor
+			opts.NativeHistogramMaxBucketNumber = nativeMaxBuckets
+			opts.NativeHistogramMinResetDuration = nativeMinResetPeriod
+		}
+		return opts
+	}
 
-	metrics.errorsCounter = factory.NewCounterVec(prometheus.CounterOpts{ //nolint:promlinter
-		Name: "error_count",
-		Help: "error count per method",
-	}, []string{"service", "endpoint"})
-	metrics.latency = factory.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "request_latency",
-		Help:    "total duration of request in seconds",
-		Buckets: buckets,
+	metrics.requests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "requests_total",
+		Help: "Handled requests by status class",
+	}, []string{"service", "endpoint", "method", "code"})
+	metrics.duration = factory.NewHistogramVec(
+		histogram("request_duration_seconds", "Request handling duration in seconds", buckets),
+		[]string{"service", "endpoint", "method", "code"},
+	)
+	metrics.inFlight = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "requests_in_flight",
+		Help: "Requests being handled",
 	}, []string{"service", "endpoint"})
+	metrics.requestSize = factory.NewHistogramVec(
+		histogram("request_size_bytes", "Request body size in bytes", sizeBuckets),
+		[]string{"service", "endpoint"},
+	)
+	metrics.responseSize = factory.NewHistogramVec(
+		histogram("response_size_bytes", "Response body size in bytes (before compression)", sizeBuckets),
+		[]string{"service", "endpoint"},
+	)
+	metrics.rejected = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "rejected_requests_total",
+		Help: "Requests rejected by rate/connection limiters and authentication/authorization",
+	}, []string{"reason"})
+
+	if conf.LegacyNames {
+		legacy := promauto.With(metricsRegistry)
+		metrics.errorsCounter = legacy.NewCounterVec(prometheus.CounterOpts{ //nolint:promlinter
+			Name: "error_count",
+			Help: "error count per method",
+		}, []string{"service", "endpoint"})
+		metrics.latency = legacy.NewHistogramVec(
+			histogram("request_latency", "total duration of request in seconds", buckets), //nolint:promlinter
+			[]string{"service", "endpoint"},
+		)
+	}
 
 	return metrics
 }
 
-func (m *serviceMetrics) ScoreMethod(service, endpoint string, begin time.Time, err error) {
-	labels := prometheus.Labels{
-		"service":  service,
-		"endpoint": endpoint,
+// Begin counts the request in flight. The returned function is to be called when the request is done.
+func (m *serviceMetrics) Begin(service, endpoint string) func() {
+	gauge := m.inFlight.WithLabelValues(service, endpoint)
+	gauge.Inc()
+	return gauge.Dec
+}
+
+func (m *serviceMetrics) ScoreMethod(s requestScore) {
+	elapsed := time.Since(s.begin).Seconds()
+	code := statusClass(s.status)
+	requests := m.requests.WithLabelValues(s.service, s.endpoint, s.method, code)
+	duration := m.duration.WithLabelValues(s.service, s.endpoint, s.method, code)
+	if s.traceID != "" {
+		exemplar := prometheus.Labels{"trace_id": s.traceID}
+		requests.(prometheus.ExemplarAdder).AddWithExemplar(1, exemplar)              //nolint:forcetypeassert
+		duration.(prometheus.ExemplarObserver).ObserveWithExemplar(elapsed, exemplar) //nolint:forcetypeassert
+	} else {
+		requests.Inc()
+		duration.Observe(elapsed)
+	}
+	m.requestSize.WithLabelValues(s.service, s.endpoint).Observe(float64(s.requestSize))
+	m.responseSize.WithLabelValues(s.service, s.endpoint).Observe(float64(s.responseSize))
+	switch s.status {
+	case http.StatusUnauthorized:
+		m.Reject(RejectUnauthorized)
+	case http.StatusForbidden:
+		m.Reject(RejectForbidden)
+	}
+	if m.latency != nil {
+		labels := prometheus.Labels{
+			"service":  s.service,
+			"endpoint": s.endpoint,
+		}
+		if isError(s.status, s.err) {
+			m.errorsCounter.With(labels).Add(1)
+		}
+		m.latency.With(labels).Observe(elapsed)
 	}
-	if isError(err) {
-		m.errorsCounter.With(labels).Add(1)
+}
+
+// Reject counts a rejected request
+func (m *serviceMetrics) Reject(reason string) {
+	m.rejected.WithLabelValues(reason).Inc()
+}
+
+func isError(status int, err error) bool {
+	return err != nil || status >= http.StatusInternalServerError
+}
+
+// statusClass returns status code class label: "2xx", "4xx", ...
+func statusClass(status int) string {
+	if status < 100 || status > 599 { //nolint:mnd
+		return "unknown"
 	}
-	m.latency.With(labels).Observe(time.Since(begin).Seconds())
+	return strconv.Itoa(status/100) + "xx" //nolint:mnd
 }
 
-func isError(err error) bool {
-	return err != nil
+type tlsMetrics struct {
+	expiry         *prometheus.GaugeVec
+	reloads        *prometheus.CounterVec
+	reloadFailures *prometheus.CounterVec
+}
+
+func newTLSMetrics(metricsRegistry prometheus.Registerer) *tlsMetrics {
+	factory := promauto.With(prometheus.WrapRegistererWithPrefix("httpserver_", metricsRegistry))
+	return &tlsMetrics{
+		expiry: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tls_certificate_expiry_timestamp_seconds",
+			Help: "Expiration time of the listener certificate (unix time)",
+		}, []string{"listener"}),
+		reloads: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "tls_certificate_reloads_total",
+			Help: "Successful certificate reloads",
+		}, []string{"listener"}),
+		reloadFailures: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "tls_certificate_reload_failures_total",
+			Help: "Failed certificate reloads (the previous certificate stays in use)",
+		}, []string{"listener"}),
+	}
 }
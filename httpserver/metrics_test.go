@@ -0,0 +1,75 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bhmj/goblocks/httpreply"
+	"github.com/bhmj/goblocks/log"
+	"github.com/bhmj/goblocks/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceMetrics(t *testing.T) {
+	a := assert.New(t)
+
+	registry := prometheus.NewRegistry()
+	serviceMetrics := newMetrics(registry, metrics.Config{LegacyNames: true})
+	handler := func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if r.URL.Query().Get("crash") != "" {
+			w.WriteHeader(http.StatusInternalServerError) // no error returned
+			return 0, nil
+		}
+		if r.URL.Query().Get("fail") != "" {
+			return http.StatusForbidden, errors.New("not allowed")
+		}
+		return httpreply.String(w, "hello")
+	}
+	serve := func(method, uri, body string) {
+		req := httptest.NewRequest(method, uri, strings.NewReader(body))
+		instrumentationMiddleware(handler, log.NewNop(), serviceMetrics, endpointInfo{service: "svc", endpoint: "ep"})(httptest.NewRecorder(), req)
+	}
+	serve(http.MethodGet, "/", "")
+	serve(http.MethodPost, "/", "payload")
+	serve(http.MethodGet, "/?crash=1", "")
+	serve(http.MethodGet, "/?fail=1", "")
+
+	families, err := registry.Gather()
+	a.NoError(err)
+	find := func(name string, labels map[string]string) *dto.Metric {
+		for _, family := range families {
+			if family.GetName() != name {
+				continue
+			}
+		next:
+			for _, m := range family.GetMetric() {
+				for _, pair := range m.GetLabel() {
+					if value, ok := labels[pair.GetName()]; ok && value != pair.GetValue() {
+						continue next
+					}
+				}
+				return m
+			}
+		}
+		return nil
+	}
+
+	a.InDelta(1, find("httpserver_requests_total", map[string]string{"method": "GET", "code": "2xx"}).GetCounter().GetValue(), 0)
+	a.InDelta(1, find("httpserver_requests_total", map[string]string{"method": "POST", "code": "2xx"}).GetCounter().GetValue(), 0)
+	a.InDelta(1, find("httpserver_requests_total", map[string]string{"code": "5xx"}).GetCounter().GetValue(), 0)
+	a.InDelta(1, find("httpserver_requests_total", map[string]string{"code": "4xx"}).GetCounter().GetValue(), 0)
+	a.InDelta(0, find("httpserver_requests_in_flight", nil).GetGauge().GetValue(), 0)
+	a.InDelta(len("payload"), find("httpserver_request_size_bytes", nil).GetHistogram().GetSampleSum(), 0)
+	a.InDelta(1, find("httpserver_rejected_requests_total", map[string]string{"reason": RejectForbidden}).GetCounter().GetValue(), 0)
+	// 500 without returned error is an error too
+	a.InDelta(2, find("error_count", nil).GetCounter().GetValue(), 0)
+	a.Equal(uint64(4), find("request_latency", nil).GetHistogram().GetSampleCount())
+
+	a.Equal("2xx", statusClass(204))
+	a.Equal("unknown", statusClass(0))
+}
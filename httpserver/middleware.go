@@ -1,35 +1,81 @@
 package httpserver
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/bhmj/goblocks/apiauth"
+	"github.com/bhmj/goblocks/apiauth/cert"
+	"github.com/bhmj/goblocks/correlation"
 	"github.com/bhmj/goblocks/httpreply"
 	"github.com/bhmj/goblocks/log"
-	"github.com/google/uuid"
+	"github.com/bhmj/goblocks/tracing"
 	"golang.org/x/time/rate"
 )
 
+var errNoSession = errors.New("unauthorized: no valid session")
+
+// RequestID returns the request ID (incoming X-Request-ID or generated one)
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(ContextRequestID).(string)
+	return id
+}
+
 // HandlerWithResult is an HTTP handler that returns status code and error
 type HandlerWithResult func(w http.ResponseWriter, r *http.Request) (int, error)
 
 type ContextKey string
 
 const (
-	ContextRequestID   ContextKey = "requestID"
-	ContextSessionData ContextKey = "sessionData"
+	ContextRequestID      ContextKey = "requestID"
+	ContextSessionData    ContextKey = "sessionData"
+	ContextCSRFToken      ContextKey = "csrfToken"
+	ContextCSPNonce       ContextKey = "cspNonce"       // Content-Security-Policy nonce (see SecurityHeadersConfig.CSP)
+	ContextListener       ContextKey = "listener"       // name of the listener which accepted the request
+	ContextClientIdentity ContextKey = "clientIdentity" // *cert.Identity of the verified client certificate
 )
 
 // before router middlewares
 
-func connLimiterMiddleware(next http.Handler, cw *ConnectionWatcher, openConnLimit int) http.HandlerFunc {
+// clientCertMiddleware exposes verified client certificate identity and applies the CN/SAN allowlist
+func clientCertMiddleware(next http.Handler, l ListenerConfig, logger log.MetaLogger) http.HandlerFunc {
+	behavior := l.TLSUnknownCN
+	if behavior == "" {
+		behavior = UCNBlock
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := cert.FromRequest(r)
+		if id != nil {
+			r = r.WithContext(context.WithValue(r.Context(), ContextClientIdentity, id))
+		}
+		if len(l.TLSAllowedClients) > 0 && behavior != UCNAllow && (id == nil || !id.Matches(l.TLSAllowedClients)) {
+			fields := []log.Field{log.String("listener", l.Name), log.String("remote", r.RemoteAddr)}
+			if id != nil {
+				fields = append(fields, log.String("cn", id.CommonName), log.String("fingerprint", id.Fingerprint))
+			}
+			if behavior == UCNBlock {
+				logger.Warn("client certificate rejected", fields...)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			logger.Warn("unknown client certificate", fields...)
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+func connLimiterMiddleware(next http.Handler, cw *ConnectionWatcher, openConnLimit int, metrics *serviceMetrics) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		n := cw.Count()
 		if n >= int64(openConnLimit) {
+			metrics.Reject(RejectConnLimit)
 			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
 			return
 		}
@@ -37,9 +83,10 @@ func connLimiterMiddleware(next http.Handler, cw *ConnectionWatcher, openConnLim
 	}
 }
 
-func rateLimiterMiddleware(next http.Handler, limiter *rate.Limiter) http.HandlerFunc {
+func rateLimiterMiddleware(next http.Handler, limiter *rate.Limiter, metrics *serviceMetrics) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		if !limiter.Allow() {
+			metrics.Reject(RejectRateLimit)
 			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
 			return
 		}
@@ -47,18 +94,6 @@ func rateLimiterMiddleware(next http.Handler, limiter *rate.Limiter) http.Handle
 	}
 }
 
-func authMiddleware(next http.Handler, auth apiauth.Auth) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if auth != nil {
-			if err := auth.Authorized(r); err != nil {
-				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
-				return
-			}
-		}
-		next.ServeHTTP(w, r)
-	}
-}
-
 func panicLoggerMiddleware(next http.Handler, logger log.MetaLogger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -75,11 +110,23 @@ func panicLoggerMiddleware(next http.Handler, logger log.MetaLogger) http.Handle
 	}
 }
 
-func corsMiddleware(next http.Handler, domain string) http.HandlerFunc {
+func corsMiddleware(next http.Handler, domain string, csrf CSRFConfig) http.HandlerFunc {
+	allowHeaders, exposeHeaders := "Content-Type", ""
+	if csrf.Enabled {
+		headerName := csrf.HeaderName
+		if headerName == "" {
+			headerName = "X-CSRF-Token"
+		}
+		allowHeaders += ", " + headerName
+		exposeHeaders = headerName
+	}
 	return func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", domain)
 		w.Header().Set("Access-Control-Allow-Methods", "POST, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+		if exposeHeaders != "" {
+			w.Header().Set("Access-Control-Expose-Headers", exposeHeaders)
+		}
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		if req.Method == http.MethodOptions {
@@ -93,15 +140,42 @@ func corsMiddleware(next http.Handler, domain string) http.HandlerFunc {
 
 // after router middlewares
 
+func listenerFilterMiddleware(next http.HandlerFunc, listeners []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, _ := r.Context().Value(ContextListener).(string)
+		if !slices.Contains(listeners, name) {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// endpointInfo describes the endpoint served by instrumentationMiddleware
+type endpointInfo struct {
+	service, endpoint string
+	route             string // path template
+	session           SessionLoader
+	sessionStrict     bool
+	accessLog         *accessLog // nil if access log is off
+}
+
 func instrumentationMiddleware(
 	handler HandlerWithResult,
 	logger log.MetaLogger,
 	metrics *serviceMetrics,
-	service, endpoint string,
-	sessionGetter SessionDataGetter,
+	ep endpointInfo,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		_ = r.ParseForm()
+		defer metrics.Begin(ep.service, ep.endpoint)()
+		startTime := time.Now()
+		recorder := &responseRecorder{ResponseWriter: w}
+		w = recorder
+		body := &bodyCounter{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+		parseForm(r)
 
 		// get real remote address
 		remoteAddr := r.RemoteAddr
@@ -114,46 +188,132 @@ func instrumentationMiddleware(
 		}
 		r.RemoteAddr = strings.Split(remoteAddr, ":")[0]
 
-		// request ID
-		reqID := uuid.New().String()
+		var (
+			code int
+			err  error
+		)
+
+		// request ID and trace context: honour valid incoming ones
+		reqID := r.Header.Get(correlation.HeaderRequestID)
+		if !correlation.ValidRequestID(reqID) {
+			reqID = correlation.NewRequestID()
+		}
+		w.Header().Set(correlation.HeaderRequestID, reqID)
+		trace := correlation.FromHeaders(r.Header.Get(correlation.HeaderTraceparent), r.Header.Get(correlation.HeaderTracestate))
+		if tracing.Enabled() {
+			spanCtx, span := tracing.StartServerSpan(r, ep.service+"/"+ep.endpoint, ep.route)
+			defer func() { tracing.EndServerSpan(span, recorder.Status(), err) }()
+			r = r.WithContext(spanCtx)
+			trace = tracing.Correlation(span.SpanContext())
+		}
 		// logging
 		fields := []log.Field{
 			log.String("method", r.Method),
 			log.String("uri", r.RequestURI),
 			log.String("remote", r.RemoteAddr),
 			log.String("rid", reqID),
+			log.String("trace", trace.TraceIDString()),
+			log.String("span", trace.SpanIDString()),
 		}
 		contextLogger := logger.With(fields...)
 		defer contextLogger.Flush()
 
-		ctx := context.WithValue(r.Context(), log.ContextMetaLogger, contextLogger)
+		ctx := log.WithContext(r.Context(), contextLogger)
 		ctx = context.WithValue(ctx, ContextRequestID, reqID) // used in panic middleware
+		ctx = correlation.WithRequestID(ctx, reqID)
+		ctx = correlation.WithTrace(ctx, trace)
 
-		if sessionGetter != nil { //nolint:nestif
-			// query session data
-			cookie, err := r.Cookie("SID")
-			if err == nil {
-				sessionData, err := sessionGetter(cookie.Value)
-				if err != nil {
-					contextLogger.Error("failed to get session data", log.Error(err))
-				} else {
-					ctx = context.WithValue(ctx, ContextSessionData, sessionData)
-				}
-			} else if errors.Is(err, http.ErrNoCookie) {
-				contextLogger.Error("missing SID cookie")
+		logStage := contextLogger.Info
+		var access *accessEntry
+		if ep.accessLog != nil {
+			logStage = contextLogger.Debug
+			access = &accessEntry{
+				Time:      startTime,
+				Remote:    r.RemoteAddr,
+				Method:    r.Method,
+				URI:       r.RequestURI,
+				Proto:     r.Proto,
+				Route:     ep.route,
+				Service:   ep.service,
+				Endpoint:  ep.endpoint,
+				RequestID: reqID,
+				UserAgent: r.UserAgent(),
+				Referer:   r.Referer(),
 			}
+			ctx = context.WithValue(ctx, accessContextKey{}, access)
 		}
 
-		contextLogger.Info("start")
-		// metrics
-		startTime := time.Now()
-		code, err := handler(w, r.WithContext(ctx))
-		defer metrics.ScoreMethod(service, endpoint, startTime, err)
-		contextLogger.Info("finish", log.Duration("took", time.Since(startTime)))
+		var sessionErr error
+		if ep.session != nil {
+			var sessionData any
+			sessionData, sessionErr = ep.session.LoadSession(w, r)
+			if sessionErr == nil {
+				ctx = context.WithValue(ctx, ContextSessionData, sessionData)
+			} else if errors.Is(sessionErr, http.ErrNoCookie) {
+				contextLogger.Debug("no session", log.Error(sessionErr)) // anonymous visitor
+			} else if !ep.sessionStrict {
+				contextLogger.Error("failed to get session data", log.Error(sessionErr))
+			}
+		}
+
+		logStage("start")
+		if ep.sessionStrict && sessionErr != nil {
+			code, err = http.StatusUnauthorized, fmt.Errorf("%w: %w", errNoSession, sessionErr)
+		} else {
+			code, err = handler(w, r.WithContext(ctx))
+		}
+		logStage("finish", log.Duration("took", time.Since(startTime)))
 		// errorer
 		if err != nil {
 			contextLogger.Error("runtime", log.String("rid", reqID), log.Error(err), log.MainMessage())
 			_, _ = httpreply.Error(w, err, code)
 		}
+		// metrics
+		requestSize := r.ContentLength
+		if requestSize < 0 {
+			requestSize = body.bytes
+		}
+		metrics.ScoreMethod(requestScore{
+			service:      ep.service,
+			endpoint:     ep.endpoint,
+			method:       r.Method,
+			status:       recorder.Status(),
+			begin:        startTime,
+			requestSize:  requestSize,
+			responseSize: recorder.bytes,
+			err:          err,
+			traceID:      trace.TraceIDString(),
+		})
+		if access != nil {
+			access.Status = recorder.Status()
+			access.Bytes = recorder.bytes
+			access.Latency = time.Since(startTime).Seconds()
+			ep.accessLog.write(access)
+		}
+	}
+}
+
+// maxFormSize is the urlencoded body limit of http.Request.ParseForm
+const maxFormSize = 10 << 20
+
+// parseForm parses the request form keeping an urlencoded body readable for handlers and body signature checks
+func parseForm(r *http.Request) {
+	if r.Body == nil || (r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodPatch) {
+		_ = r.ParseForm()
+		return
+	}
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/x-www-form-urlencoded" {
+		_ = r.ParseForm()
+		return
+	}
+	orig := r.Body
+	buf, err := io.ReadAll(io.LimitReader(orig, maxFormSize+1))
+	r.Body = io.NopCloser(bytes.NewReader(buf))
+	if err == nil {
+		_ = r.ParseForm()
 	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), orig), orig}
 }
@@ -0,0 +1,67 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bhmj/goblocks/correlation"
+	"github.com/bhmj/goblocks/httpreply"
+	"github.com/bhmj/goblocks/log"
+	"github.com/bhmj/goblocks/log/logtest"
+	"github.com/bhmj/goblocks/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	a := assert.New(t)
+
+	serviceMetrics := newMetrics(prometheus.NewRegistry(), metrics.Config{})
+	var trace correlation.TraceContext
+	handler := func(w http.ResponseWriter, r *http.Request) (int, error) {
+		trace, _ = correlation.Trace(r.Context())
+		a.Equal(RequestID(r), correlation.RequestID(r.Context()))
+		return httpreply.String(w, RequestID(r))
+	}
+	serve := func(requestID, traceparent string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", requestID)
+		req.Header.Set("Traceparent", traceparent)
+		w := httptest.NewRecorder()
+		instrumentationMiddleware(handler, log.NewNop(), serviceMetrics, endpointInfo{service: "svc", endpoint: "ep"})(w, req)
+		return w
+	}
+
+	w := serve("upstream-42", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	a.Equal("upstream-42", w.Body.String())
+	a.Equal("upstream-42", w.Header().Get("X-Request-ID"))
+	a.Equal("4bf92f3577b34da6a3ce929d0e0e4736", trace.TraceIDString())
+
+	w = serve("bad id\r\n", "")
+	a.NotEqual("bad id\r\n", w.Body.String())
+	a.True(correlation.ValidRequestID(w.Body.String()))
+	a.Equal(w.Body.String(), w.Header().Get("X-Request-ID"))
+}
+
+func TestSessionLog(t *testing.T) {
+	serviceMetrics := newMetrics(prometheus.NewRegistry(), metrics.Config{})
+	loader := SessionDataGetter(func(string) (any, error) { return nil, errors.New("session expired") })
+	handler := func(w http.ResponseWriter, _ *http.Request) (int, error) {
+		return httpreply.String(w, "ok")
+	}
+	logger := logtest.New()
+	serve := func(req *http.Request) {
+		instrumentationMiddleware(handler, logger, serviceMetrics, endpointInfo{service: "svc", endpoint: "ep", session: loader})(httptest.NewRecorder(), req)
+	}
+
+	serve(httptest.NewRequest(http.MethodGet, "/", nil))
+	logger.AssertNotLogged(t, "error", "failed to get session data")
+	logger.AssertLogged(t, "debug", "no session")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "SID", Value: "s1"})
+	serve(req)
+	logger.AssertLogged(t, "error", "failed to get session data")
+}
@@ -0,0 +1,206 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bhmj/goblocks/apiauth/cert"
+	"github.com/bhmj/goblocks/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := x509.ParseCertificate(der)
+	return &testCA{cert: c, key: key}
+}
+
+// issue returns PEM encoded certificate and key signed by the CA
+func (ca *testCA) issue(t *testing.T, serial int64, cn string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func (ca *testCA) crl(t *testing.T, nextUpdate time.Time, serials ...int64) []byte {
+	t.Helper()
+	var entries []x509.RevocationListEntry
+	for _, serial := range serials {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                nextUpdate.Add(-2 * time.Hour),
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: entries,
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+func TestClientCertificates(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		fname := filepath.Join(dir, name)
+		a.NoError(os.WriteFile(fname, data, 0o600))
+		return fname
+	}
+	ca := newTestCA(t)
+	caFile := write("ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}))
+	serverCert, serverKey := ca.issue(t, 10, "localhost", x509.ExtKeyUsageServerAuth)
+
+	lnConfig := ListenerConfig{
+		Name:              "mtls",
+		Address:           "127.0.0.1:0",
+		TLSEnabled:        true,
+		TLSCertFile:       write("server.crt", serverCert),
+		TLSKeyFile:        write("server.key", serverKey),
+		TLSUseClientCert:  true,
+		TLSClientCAFile:   caFile,
+		TLSAllowedClients: []string{"*.allowed.test"},
+		TLSCRLFiles:       []string{write("ca.crl", ca.crl(t, time.Now().Add(time.Hour), 30))},
+	}
+	l, err := newListener(lnConfig, TLSParams{}, nil)
+	if !a.NoError(err) {
+		return
+	}
+	server := &http.Server{
+		Handler: clientCertMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, _ := r.Context().Value(ContextClientIdentity).(*cert.Identity)
+			_, _ = io.WriteString(w, id.CommonName)
+		}), lnConfig, log.NewNop()),
+	}
+	go server.Serve(l.ln) //nolint:errcheck
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	request := func(serial int64, cn string) (int, string, error) {
+		certPEM, keyPEM := ca.issue(t, serial, cn, x509.ExtKeyUsageClientAuth)
+		clientCert, _ := tls.X509KeyPair(certPEM, keyPEM)
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			ServerName:   "localhost",
+			Certificates: []tls.Certificate{clientCert},
+			MinVersion:   tls.VersionTLS12,
+		}}}
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://"+l.ln.Addr().String()+"/", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, "", err
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body), nil
+	}
+
+	code, body, err := request(20, "svc.allowed.test")
+	a.NoError(err)
+	a.Equal(http.StatusOK, code)
+	a.Equal("svc.allowed.test", body)
+
+	code, _, err = request(21, "svc.unknown.test")
+	a.NoError(err)
+	a.Equal(http.StatusForbidden, code)
+
+	_, _, err = request(30, "revoked.allowed.test")
+	a.Error(err, "revoked certificate must be rejected during handshake")
+}
+
+func TestExpiredCRL(t *testing.T) {
+	a := assert.New(t)
+
+	ca := newTestCA(t)
+	certPEM, _ := ca.issue(t, 20, "svc.allowed.test", x509.ExtKeyUsageClientAuth)
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	a.NoError(err)
+	chain := []*x509.Certificate{cert, ca.cert}
+
+	fname := filepath.Join(t.TempDir(), "ca.crl")
+	a.NoError(os.WriteFile(fname, ca.crl(t, time.Now().Add(-time.Minute)), 0o600))
+	rv := newRevocation([]string{fname}, "")
+	a.NoError(rv.load())
+	a.ErrorIs(rv.check(chain), errRevocationExpired)
+
+	a.NoError(os.WriteFile(fname, ca.crl(t, time.Now().Add(time.Hour)), 0o600))
+	a.NoError(rv.load()) // refreshed
+	a.NoError(rv.check(chain))
+}
+
+func TestWatchRevocation(t *testing.T) {
+	a := assert.New(t)
+
+	ca := newTestCA(t)
+	certPEM, _ := ca.issue(t, 20, "svc.allowed.test", x509.ExtKeyUsageClientAuth)
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	a.NoError(err)
+	chain := []*x509.Certificate{cert, ca.cert}
+
+	fname := filepath.Join(t.TempDir(), "ca.crl")
+	a.NoError(os.WriteFile(fname, ca.crl(t, time.Now().Add(time.Hour)), 0o600))
+	rv := newRevocation([]string{fname}, "")
+	a.NoError(rv.load())
+	a.NoError(rv.check(chain))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := &listener{cfg: ListenerConfig{Name: "acme"}, revocation: rv}
+	go watchRevocation(ctx, l, 10*time.Millisecond, newTLSMetrics(prometheus.NewRegistry()), log.NewNop())
+
+	a.NoError(os.WriteFile(fname, ca.crl(t, time.Now().Add(time.Hour), 20), 0o600))
+	a.Eventually(func() bool { return errors.Is(rv.check(chain), errCertificateRevoked) }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,145 @@
+package httpserver
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+var (
+	errCertificateRevoked = errors.New("certificate revoked")
+	errRevocationExpired  = errors.New("revocation data expired")
+)
+
+// revocation checks client certificates against local CRL files and OCSP responses.
+// OCSP responses are looked up in ocspDir by certificate serial number (lowercase hex, .der or .ocsp extension).
+// Certificates covered by a CRL or OCSP response past its NextUpdate are rejected until the data is refreshed.
+type revocation struct {
+	crlFiles []string
+	ocspDir  string
+	crls     atomic.Pointer[[]*x509.RevocationList]
+	stamp    string // source files fingerprint of the loaded data
+}
+
+func newRevocation(crlFiles []string, ocspDir string) *revocation {
+	if len(crlFiles) == 0 && ocspDir == "" {
+		return nil
+	}
+	return &revocation{crlFiles: crlFiles, ocspDir: ocspDir}
+}
+
+// load reads CRL files (PEM or DER)
+func (rv *revocation) load() error {
+	stamp := rv.fingerprint()
+	var crls []*x509.RevocationList
+	for _, fname := range rv.crlFiles {
+		data, err := os.ReadFile(fname)
+		if err != nil {
+			return fmt.Errorf("read CRL: %w", err)
+		}
+		for len(data) > 0 {
+			der := data
+			if block, rest := pem.Decode(data); block != nil {
+				der, data = block.Bytes, rest
+			} else {
+				data = nil
+			}
+			crl, err := x509.ParseRevocationList(der)
+			if err != nil {
+				return fmt.Errorf("parse CRL %s: %w", fname, err)
+			}
+			crls = append(crls, crl)
+		}
+	}
+	rv.crls.Store(&crls)
+	rv.stamp = stamp
+	return nil
+}
+
+// files returns the source files (for change detection)
+func (rv *revocation) files() []string {
+	files := append([]string{rv.ocspDir}, rv.crlFiles...)
+	if rv.ocspDir != "" {
+		if entries, err := os.ReadDir(rv.ocspDir); err == nil {
+			for _, entry := range entries {
+				files = append(files, filepath.Join(rv.ocspDir, entry.Name()))
+			}
+		}
+	}
+	return files
+}
+
+func (rv *revocation) fingerprint() string {
+	return filesFingerprint(rv.files())
+}
+
+// verifyConnection rejects the handshake if any certificate of the verified chain is revoked
+func (rv *revocation) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.VerifiedChains) == 0 {
+		return nil
+	}
+	return rv.check(cs.VerifiedChains[0])
+}
+
+func (rv *revocation) check(chain []*x509.Certificate) error {
+	var crls []*x509.RevocationList
+	if p := rv.crls.Load(); p != nil {
+		crls = *p
+	}
+	now := time.Now()
+	for i := 0; i < len(chain)-1; i++ {
+		cert, issuer := chain[i], chain[i+1]
+		for _, crl := range crls {
+			if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) || crl.CheckSignatureFrom(issuer) != nil {
+				continue
+			}
+			if !crl.NextUpdate.IsZero() && now.After(crl.NextUpdate) {
+				return fmt.Errorf("%w: CRL of %s (next update %s)", errRevocationExpired, issuer.Subject, crl.NextUpdate.Format(time.RFC3339))
+			}
+			for _, entry := range crl.RevokedCertificateEntries {
+				if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+					return fmt.Errorf("%w: serial %x (CRL)", errCertificateRevoked, cert.SerialNumber)
+				}
+			}
+		}
+		if err := rv.checkOCSP(cert, issuer, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOCSP looks for a stored OCSP response; a missing response is not an error
+func (rv *revocation) checkOCSP(cert, issuer *x509.Certificate, now time.Time) error {
+	if rv.ocspDir == "" {
+		return nil
+	}
+	serial := cert.SerialNumber.Text(16) //nolint:mnd
+	for _, ext := range []string{".der", ".ocsp"} {
+		data, err := os.ReadFile(filepath.Join(rv.ocspDir, serial+ext))
+		if err != nil {
+			continue
+		}
+		resp, err := ocsp.ParseResponseForCert(data, cert, issuer)
+		if err != nil {
+			return fmt.Errorf("OCSP response for %s: %w", serial, err)
+		}
+		if resp.Status == ocsp.Revoked {
+			return fmt.Errorf("%w: serial %s (OCSP)", errCertificateRevoked, serial)
+		}
+		if !resp.NextUpdate.IsZero() && now.After(resp.NextUpdate) {
+			return fmt.Errorf("%w: OCSP response for %s (next update %s)", errRevocationExpired, serial, resp.NextUpdate.Format(time.RFC3339))
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	headerHSTS          = "Strict-Transport-Security"
+	headerCSP           = "Content-Security-Policy"
+	headerCSPReportOnly = "Content-Security-Policy-Report-Only"
+
+	cspNoncePlaceholder = "{nonce}"
+	cspNonceSize        = 16
+)
+
+// SecurityHeadersConfig defines security response headers ("off" disables a header with a default value).
+// Content-Security-Policy may contain the {nonce} placeholder replaced by a per-request 'nonce-...' source (see CSPNonce).
+type SecurityHeadersConfig struct {
+	Enabled               bool          `yaml:"enabled" description:"Add security headers to endpoint responses"`
+	HSTSMaxAge            time.Duration `yaml:"hstsMaxAge" description:"Strict-Transport-Security max-age (sent over TLS only, 0 disables)" default:"8760h"`
+	HSTSIncludeSubdomains bool          `yaml:"hstsIncludeSubdomains" description:"Add includeSubDomains to Strict-Transport-Security"`
+	HSTSPreload           bool          `yaml:"hstsPreload" description:"Add preload to Strict-Transport-Security"`
+	CSP                   string        `yaml:"csp" description:"Content-Security-Policy ({nonce} is replaced by per-request nonce)" default:"default-src 'self'; script-src 'self' {nonce}; style-src 'self' {nonce}; object-src 'none'; base-uri 'self'"`
+	CSPReportOnly         bool          `yaml:"cspReportOnly" description:"Send Content-Security-Policy-Report-Only instead of enforcing the policy"`
+	ReferrerPolicy        string        `yaml:"referrerPolicy" description:"Referrer-Policy" default:"strict-origin-when-cross-origin"`
+	PermissionsPolicy     string        `yaml:"permissionsPolicy" description:"Permissions-Policy" default:"camera=(), microphone=(), geolocation=()"`
+	FrameOptions          string        `yaml:"frameOptions" description:"X-Frame-Options (also added as CSP frame-ancestors)" default:"DENY" choices:"DENY,SAMEORIGIN,off"`
+	COOP                  string        `yaml:"coop" description:"Cross-Origin-Opener-Policy" default:"same-origin"`
+	COEP                  string        `yaml:"coep" description:"Cross-Origin-Embedder-Policy (e.g. require-corp)"`
+}
+
+// CSPNonce returns the Content-Security-Policy nonce of the request to be used in <script nonce="...">
+func CSPNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(ContextCSPNonce).(string)
+	return nonce
+}
+
+// headers returns the configured header set
+func (c SecurityHeadersConfig) headers() map[string]string {
+	h := map[string]string{
+		"X-Content-Type-Options": "nosniff",
+	}
+	if c.HSTSMaxAge > 0 {
+		hsts := fmt.Sprintf("max-age=%d", int64(c.HSTSMaxAge.Seconds()))
+		if c.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if c.HSTSPreload {
+			hsts += "; preload"
+		}
+		h[headerHSTS] = hsts
+	}
+	csp, frameOptions := headerValue(c.CSP), headerValue(c.FrameOptions)
+	if csp != "" && frameOptions != "" && !strings.Contains(csp, "frame-ancestors") {
+		ancestors := "'none'"
+		if strings.EqualFold(frameOptions, "SAMEORIGIN") {
+			ancestors = "'self'"
+		}
+		csp = strings.TrimSuffix(strings.TrimSpace(csp), ";") + "; frame-ancestors " + ancestors
+	}
+	set := func(name, value string) {
+		if value = headerValue(value); value != "" {
+			h[name] = value
+		}
+	}
+	set(headerCSP, csp)
+	set("Referrer-Policy", c.ReferrerPolicy)
+	set("Permissions-Policy", c.PermissionsPolicy)
+	set("X-Frame-Options", strings.ToUpper(frameOptions))
+	set("Cross-Origin-Opener-Policy", c.COOP)
+	set("Cross-Origin-Embedder-Policy", c.COEP)
+	return h
+}
+
+func headerValue(value string) string {
+	if strings.EqualFold(value, "off") {
+		return ""
+	}
+	return value
+}
+
+// securityHeadersMiddleware adds security headers. Endpoint overrides replace headers by name, empty or "off" value removes the header.
+func securityHeadersMiddleware(next http.HandlerFunc, cfg SecurityHeadersConfig, overrides map[string]string) http.HandlerFunc {
+	headers := cfg.headers()
+	for name, value := range overrides {
+		name = http.CanonicalHeaderKey(name)
+		if headerValue(value) == "" {
+			delete(headers, name)
+		} else {
+			headers[name] = value
+		}
+	}
+	if csp, found := headers[headerCSP]; found && cfg.CSPReportOnly {
+		delete(headers, headerCSP)
+		headers[headerCSPReportOnly] = csp
+	}
+	useNonce := false
+	for _, value := range headers {
+		useNonce = useNonce || strings.Contains(value, cspNoncePlaceholder)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var nonce string
+		if useNonce {
+			b := make([]byte, cspNonceSize)
+			_, _ = rand.Read(b)
+			nonce = base64.StdEncoding.EncodeToString(b)
+			r = r.WithContext(context.WithValue(r.Context(), ContextCSPNonce, nonce))
+		}
+		tls := r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+		for name, value := range headers {
+			if name == headerHSTS && !tls {
+				continue
+			}
+			if useNonce {
+				value = strings.ReplaceAll(value, cspNoncePlaceholder, "'nonce-"+nonce+"'")
+			}
+			w.Header().Set(name, value)
+		}
+		next(w, r)
+	}
+}
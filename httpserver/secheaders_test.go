@@ -0,0 +1,67 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	a := assert.New(t)
+
+	cfg := SecurityHeadersConfig{
+		Enabled:           true,
+		HSTSMaxAge:        24 * time.Hour,
+		HSTSPreload:       true,
+		CSP:               "default-src 'self'; script-src 'self' {nonce}",
+		ReferrerPolicy:    "no-referrer",
+		PermissionsPolicy: "off",
+		FrameOptions:      "SAMEORIGIN",
+		COOP:              "same-origin",
+	}
+	var nonce string
+	handler := func(_ http.ResponseWriter, r *http.Request) {
+		nonce = CSPNonce(r)
+	}
+	serve := func(cfg SecurityHeadersConfig, overrides map[string]string, useTLS bool) http.Header {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if useTLS {
+			req.TLS = &tls.ConnectionState{}
+		}
+		w := httptest.NewRecorder()
+		securityHeadersMiddleware(handler, cfg, overrides)(w, req)
+		return w.Header()
+	}
+
+	h := serve(cfg, nil, true)
+	a.Equal("max-age=86400; preload", h.Get("Strict-Transport-Security"))
+	a.NotEmpty(nonce)
+	a.Equal("default-src 'self'; script-src 'self' 'nonce-"+nonce+"'; frame-ancestors 'self'", h.Get("Content-Security-Policy"))
+	a.Equal("nosniff", h.Get("X-Content-Type-Options"))
+	a.Equal("no-referrer", h.Get("Referrer-Policy"))
+	a.Equal("SAMEORIGIN", h.Get("X-Frame-Options"))
+	a.Equal("same-origin", h.Get("Cross-Origin-Opener-Policy"))
+	a.Empty(h.Values("Permissions-Policy"))
+	a.Empty(h.Values("Cross-Origin-Embedder-Policy"))
+
+	first := nonce
+	h = serve(cfg, nil, false)
+	a.NotEqual(first, nonce, "nonce is generated per request")
+	a.Empty(h.Values("Strict-Transport-Security"), "no HSTS over plain HTTP")
+
+	// per-endpoint overrides
+	h = serve(cfg, map[string]string{"x-frame-options": "", "Content-Security-Policy": "frame-ancestors https://partner.example.com"}, true)
+	a.Empty(h.Values("X-Frame-Options"))
+	a.Equal("frame-ancestors https://partner.example.com", h.Get("Content-Security-Policy"))
+
+	// report-only
+	cfg.CSPReportOnly = true
+	h = serve(cfg, nil, true)
+	a.Empty(h.Values("Content-Security-Policy"))
+	a.True(strings.HasPrefix(h.Get("Content-Security-Policy-Report-Only"), "default-src 'self'"))
+}
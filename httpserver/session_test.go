@@ -0,0 +1,46 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bhmj/goblocks/httpreply"
+	"github.com/bhmj/goblocks/log"
+	"github.com/bhmj/goblocks/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionStrict(t *testing.T) {
+	a := assert.New(t)
+
+	serviceMetrics := newMetrics(prometheus.NewRegistry(), metrics.Config{})
+	getter := SessionDataGetter(func(sid string) (any, error) {
+		return "user of " + sid, nil
+	})
+	handler := func(w http.ResponseWriter, r *http.Request) (int, error) {
+		data, _ := r.Context().Value(ContextSessionData).(string)
+		return httpreply.String(w, data)
+	}
+	serve := func(strict bool, sid string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if sid != "" {
+			req.AddCookie(&http.Cookie{Name: "SID", Value: sid})
+		}
+		w := httptest.NewRecorder()
+		instrumentationMiddleware(handler, log.NewNop(), serviceMetrics, endpointInfo{service: "svc", endpoint: "profile", session: getter, sessionStrict: strict})(w, req)
+		return w
+	}
+
+	w := serve(true, "s1")
+	a.Equal(http.StatusOK, w.Code)
+	a.Equal("user of s1", w.Body.String())
+
+	w = serve(false, "")
+	a.Equal(http.StatusOK, w.Code)
+	a.Empty(w.Body.String())
+
+	w = serve(true, "")
+	a.Equal(http.StatusUnauthorized, w.Code)
+}
@@ -1,18 +1,21 @@
 package httpserver
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"net"
 	"os"
-)
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
-const (
-	minTLSVersion = tls.VersionTLS12
-	maxTLSVersion = tls.VersionTLS13
+	"github.com/bhmj/goblocks/log"
 )
 
 var (
@@ -20,146 +23,439 @@ var (
 	errFailedToParseCACertificate = errors.New("failed to parse CA certificate")
 	errDecodedPEMIsBlank          = errors.New("decoded PEM is blank")
 	errNilCertificate             = errors.New("nil certificate")
+	errNoCertificates             = errors.New("no certificates configured")
+	errUnknownTLSVersion          = errors.New("unknown TLS version")
+	errTLSVersionRange            = errors.New("max TLS version is below min version")
+	errUnknownCipherSuite         = errors.New("unknown cipher suite")
+	errUnknownCurve               = errors.New("unknown curve")
 )
 
-type ListenerConfig struct {
-	Address          string
-	TLSEnabled       bool
-	TLSCertFile      string
-	TLSKeyFile       string
-	TLSUseClientCert bool
-	TLSClientCAFile  string
+// TLSParams defines TLS protocol parameters common to all listeners
+type TLSParams struct {
+	MinVersion   string   `yaml:"minVersion" description:"Minimum TLS version" default:"1.2" choices:"1.0,1.1,1.2,1.3"`
+	MaxVersion   string   `yaml:"maxVersion" description:"Maximum TLS version" default:"1.3" choices:"1.0,1.1,1.2,1.3"`
+	CipherSuites []string `yaml:"cipherSuites" description:"TLS 1.0-1.2 cipher suites, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 (Go defaults if empty)"`
+	Curves       []string `yaml:"curves" description:"Key exchange preferences: X25519MLKEM768, X25519, P256, P384, P521 (Go defaults if empty)"`
 }
 
-// InitListener preloads certificates and returns a configured net.Listener
-func InitListener(cfg Config) (net.Listener, error) {
-	var certFile string
-	var err error
+// CertificateConfig is a certificate/key pair
+type CertificateConfig struct {
+	Cert string `yaml:"cert" description:"Certificate file"`
+	Key  string `yaml:"key" description:"Key file"`
+	CA   string `yaml:"ca" description:"Optional CA certificate appended to the chain"` //nolint:tagliatelle
+}
 
-	if cfg.UseTLS {
-		certFile, err = cfg.CertFile()
-		if err != nil {
-			return nil, fmt.Errorf("preload certificates: %w", err)
+var tlsVersions = map[string]uint16{ //nolint:gochecknoglobals
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{ //nolint:gochecknoglobals
+	"X25519MLKEM768": tls.X25519MLKEM768,
+	"X25519":         tls.X25519,
+	"P256":           tls.CurveP256,
+	"P384":           tls.CurveP384,
+	"P521":           tls.CurveP521,
+}
+
+// apply sets protocol parameters of TLS config
+func (p *TLSParams) apply(conf *tls.Config) error {
+	conf.MinVersion = tls.VersionTLS12
+	conf.MaxVersion = tls.VersionTLS13
+	if p.MinVersion != "" {
+		v, ok := tlsVersions[p.MinVersion]
+		if !ok {
+			return fmt.Errorf("%w: %s", errUnknownTLSVersion, p.MinVersion)
 		}
+		conf.MinVersion = v
+	}
+	if p.MaxVersion != "" {
+		v, ok := tlsVersions[p.MaxVersion]
+		if !ok {
+			return fmt.Errorf("%w: %s", errUnknownTLSVersion, p.MaxVersion)
+		}
+		conf.MaxVersion = v
+	}
+	if conf.MaxVersion < conf.MinVersion {
+		return fmt.Errorf("%w: %s < %s", errTLSVersionRange, p.MaxVersion, p.MinVersion)
 	}
 
-	lnConfig := &ListenerConfig{
-		Address:          fmt.Sprintf(":%d", cfg.Port),
-		TLSEnabled:       cfg.UseTLS,
-		TLSCertFile:      certFile,
-		TLSKeyFile:       cfg.TLSKey,
-		TLSUseClientCert: cfg.TLSUseClientCert,
-		TLSClientCAFile:  cfg.TLSClientCA,
+	conf.CipherSuites = nil
+	for _, name := range p.CipherSuites {
+		id, ok := cipherSuiteByName(name)
+		if !ok {
+			return fmt.Errorf("%w: %s", errUnknownCipherSuite, name)
+		}
+		conf.CipherSuites = append(conf.CipherSuites, id)
 	}
 
-	return newListener(lnConfig)
+	conf.CurvePreferences = nil
+	for _, name := range p.Curves {
+		id, ok := tlsCurves[name]
+		if !ok {
+			return fmt.Errorf("%w: %s", errUnknownCurve, name)
+		}
+		conf.CurvePreferences = append(conf.CurvePreferences, id)
+	}
+	return nil
 }
 
-// newListener creates and configures net.Listener
-func newListener(lnConfig *ListenerConfig) (net.Listener, error) {
-	ln, err := net.Listen("tcp", lnConfig.Address) //nolint:noctx
-	if err != nil {
-		return nil, fmt.Errorf("listener: %w", err)
+func cipherSuiteByName(name string) (uint16, bool) {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == name {
+			return suite.ID, true
+		}
 	}
+	return 0, false
+}
 
-	if !lnConfig.TLSEnabled {
-		return ln, nil
+// baseTLSConfig returns TLS config with protocol parameters and client authentication set.
+func baseTLSConfig(l *ListenerConfig, params TLSParams) (*tls.Config, error) {
+	tlsConf := &tls.Config{
+		NextProtos: []string{"h2", "http/1.1"},
+		ClientAuth: tls.RequestClientCert,
 	}
-
-	tlsConfig, err := prepareTLSConfig(lnConfig)
-	if err != nil {
-		return nil, fmt.Errorf("prepare TLS config: %w", err)
+	if err := params.apply(tlsConf); err != nil {
+		return nil, err
 	}
-
-	return tls.NewListener(ln, tlsConfig), nil
+	if l.TLSUseClientCert {
+		tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConf, nil
 }
 
-func prepareTLSConfig(l *ListenerConfig) (*tls.Config, error) {
-	cg := NewCertificateGetter(l.TLSCertFile, l.TLSKeyFile)
+func prepareTLSConfig(l *ListenerConfig, params TLSParams, cg *CertificateGetter) (*tls.Config, error) {
 	if err := cg.Load(); err != nil {
 		return nil, fmt.Errorf("load certificate: %w", err)
 	}
 
-	tlsConf := &tls.Config{
-		MinVersion:     minTLSVersion,
-		MaxVersion:     maxTLSVersion,
-		GetCertificate: cg.GetCertificate,
-		NextProtos:     []string{"h2", "http/1.1"},
-		ClientAuth:     tls.RequestClientCert,
+	tlsConf, err := baseTLSConfig(l, params)
+	if err != nil {
+		return nil, err
 	}
+	tlsConf.GetCertificate = cg.GetCertificate
 
-	if l.TLSUseClientCert {
-		err := setupClientCA(tlsConf, l)
-		if err != nil {
-			return nil, err
+	if l.TLSUseClientCert && l.TLSClientCAFile != "" {
+		// client CAs are reloaded along with the certificate
+		tlsConf.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			conf := tlsConf.Clone()
+			conf.GetConfigForClient = nil
+			conf.ClientCAs = cg.ClientCAs()
+			return conf, nil
 		}
 	}
 
 	return tlsConf, nil
 }
 
-func setupClientCA(tlsConf *tls.Config, l *ListenerConfig) error {
-	tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
-	if l.TLSClientCAFile != "" {
-		caPool := x509.NewCertPool()
-		data, err := os.ReadFile(l.TLSClientCAFile)
-		if err != nil {
-			return errFailedToReadCACertificate
-		}
-
-		if !caPool.AppendCertsFromPEM(data) {
-			return errFailedToParseCACertificate
-		}
-		tlsConf.ClientCAs = caPool
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	caPool := x509.NewCertPool()
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, errFailedToReadCACertificate
 	}
-	return nil
+	if !caPool.AppendCertsFromPEM(data) {
+		return nil, errFailedToParseCACertificate
+	}
+	return caPool, nil
 }
 
 // CertificateGetter allows to load certificates. The GetCertificate method
-// satisfies the tls.GetCertificate function signature.
+// satisfies the tls.GetCertificate function signature and selects the certificate
+// by SNI (exact or wildcard match), falling back to the default (first) one.
+// Watch reloads the certificates when any of the source files change.
 type CertificateGetter struct {
-	cert     *tls.Certificate
-	certFile string
-	keyFile  string
+	certs        atomic.Pointer[certificateSet]
+	clientCAs    atomic.Pointer[x509.CertPool]
+	pairs        []CertificateConfig // explicitly configured certificates, the first one is default
+	dir          string              // optional directory of <name>.crt/<name>.key pairs
+	clientCAFile string              // optional client CA bundle
+	revocation   *revocation         // optional client certificate revocation lists
+	stamp        string              // source files fingerprint
+}
+
+type certificateSet struct {
+	byName   map[string]*tls.Certificate // lowercase DNS name (may be a wildcard) -> certificate
+	all      []*tls.Certificate
+	fallback *tls.Certificate
 }
 
-func NewCertificateGetter(certFile, keyFile string) *CertificateGetter {
-	return &CertificateGetter{
-		certFile: certFile,
-		keyFile:  keyFile,
+// NewCertificateGetter creates certificate getter for the listener certificates.
+func NewCertificateGetter(l *ListenerConfig) *CertificateGetter {
+	cg := &CertificateGetter{
+		dir:          l.TLSCertDir,
+		clientCAFile: l.TLSClientCAFile,
+		revocation:   newRevocation(l.TLSCRLFiles, l.TLSOCSPDir),
 	}
+	if l.TLSCertFile != "" {
+		cg.pairs = append(cg.pairs, CertificateConfig{Cert: l.TLSCertFile, Key: l.TLSKeyFile, CA: l.TLSCAFile})
+	}
+	cg.pairs = append(cg.pairs, l.TLSCertificates...)
+	return cg
 }
 
+// Load reads certificate files and atomically replaces the current certificates.
+// On error the current certificates are kept.
 func (cg *CertificateGetter) Load() error {
-	certPEMBlock, err := os.ReadFile(cg.certFile)
+	stamp := cg.fingerprint()
+
+	pairs := cg.pairs
+	dirPairs, err := cg.dirPairs()
+	if err != nil {
+		return err
+	}
+	pairs = append(pairs[:len(pairs):len(pairs)], dirPairs...)
+	if len(pairs) == 0 {
+		return errNoCertificates
+	}
+
+	set := &certificateSet{byName: make(map[string]*tls.Certificate)}
+	for _, pair := range pairs {
+		cert, err := loadKeyPair(pair)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pair.Cert, err)
+		}
+		set.add(cert)
+	}
+
+	var clientCAs *x509.CertPool
+	if cg.clientCAFile != "" {
+		clientCAs, err = loadCertPool(cg.clientCAFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cg.revocation != nil {
+		if err := cg.revocation.load(); err != nil {
+			return err
+		}
+	}
+
+	cg.certs.Store(set)
+	cg.clientCAs.Store(clientCAs)
+	cg.stamp = stamp
+
+	return nil
+}
+
+func loadKeyPair(pair CertificateConfig) (*tls.Certificate, error) {
+	certPEMBlock, err := readCertChain(pair.Cert, pair.CA)
 	if err != nil {
-		return err //nolint:wrapcheck
+		return nil, err
 	}
-	keyPEMBlock, err := os.ReadFile(cg.keyFile)
+	keyPEMBlock, err := os.ReadFile(pair.Key)
 	if err != nil {
-		return err //nolint:wrapcheck
+		return nil, err //nolint:wrapcheck
 	}
 
 	// Check for encrypted pem block
 	keyBlock, _ := pem.Decode(keyPEMBlock)
 	if keyBlock == nil {
-		return errDecodedPEMIsBlank
+		return nil, errDecodedPEMIsBlank
 	}
 
 	cert, err := tls.X509KeyPair(certPEMBlock, keyPEMBlock)
 	if err != nil {
-		return err //nolint:wrapcheck
+		return nil, err //nolint:wrapcheck
 	}
+	return &cert, nil
+}
 
-	cg.cert = &cert
+func (set *certificateSet) add(cert *tls.Certificate) {
+	if set.fallback == nil {
+		set.fallback = cert
+	}
+	set.all = append(set.all, cert)
+	names := cert.Leaf.DNSNames
+	if len(names) == 0 && cert.Leaf.Subject.CommonName != "" {
+		names = []string{cert.Leaf.Subject.CommonName}
+	}
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if _, found := set.byName[name]; !found {
+			set.byName[name] = cert
+		}
+	}
+}
 
-	return nil
+// lookup finds certificate by exact name, then by wildcard name.
+func (set *certificateSet) lookup(serverName string) *tls.Certificate {
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if name != "" {
+		if cert, found := set.byName[name]; found {
+			return cert
+		}
+		if _, parent, found := strings.Cut(name, "."); found {
+			if cert, found := set.byName["*."+parent]; found {
+				return cert
+			}
+		}
+	}
+	return set.fallback
 }
 
-func (cg *CertificateGetter) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
-	if cg.cert == nil {
+// dirPairs lists <name>.crt (or <name>.pem) files having a matching <name>.key in the certificate directory.
+func (cg *CertificateGetter) dirPairs() ([]CertificateConfig, error) {
+	if cg.dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(cg.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read certificate directory: %w", err)
+	}
+	var pairs []CertificateConfig
+	for _, entry := range entries { // sorted by name
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".crt" && ext != ".pem") {
+			continue
+		}
+		base := filepath.Join(cg.dir, strings.TrimSuffix(entry.Name(), ext))
+		if _, err := os.Stat(base + ".key"); err != nil {
+			continue
+		}
+		pairs = append(pairs, CertificateConfig{Cert: base + ext, Key: base + ".key"})
+	}
+	return pairs, nil
+}
+
+// readCertChain returns the server certificate followed by the optional CA certificate.
+func readCertChain(certFile, caFile string) ([]byte, error) {
+	cert, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("read server certificate: %w", err)
+	}
+	if caFile == "" {
+		return cert, nil
+	}
+	ca, err := os.ReadFile(caFile)
+	if os.IsNotExist(err) {
+		return cert, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read CA certificate: %w", err)
+	}
+	return bytes.Join([][]byte{cert, ca}, []byte("\n")), nil
+}
+
+func (cg *CertificateGetter) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	set := cg.certs.Load()
+	if set == nil {
 		return nil, errNilCertificate
 	}
 
-	return cg.cert, nil
+	return set.lookup(hello.ServerName), nil
+}
+
+// ClientCAs returns the current client CA pool (nil if not configured)
+func (cg *CertificateGetter) ClientCAs() *x509.CertPool {
+	return cg.clientCAs.Load()
+}
+
+// NotAfter returns the earliest expiration time of the current certificates
+func (cg *CertificateGetter) NotAfter() time.Time {
+	set := cg.certs.Load()
+	if set == nil {
+		return time.Time{}
+	}
+	var notAfter time.Time
+	for _, cert := range set.all {
+		if notAfter.IsZero() || cert.Leaf.NotAfter.Before(notAfter) {
+			notAfter = cert.Leaf.NotAfter
+		}
+	}
+	return notAfter
+}
+
+// Watch checks the source files every interval and reloads the certificates on change.
+// onReload is called after every reload attempt with its result.
+func (cg *CertificateGetter) Watch(ctx context.Context, interval time.Duration, onReload func(error)) {
+	watchFiles(ctx, interval, cg.stamp, cg.fingerprint, cg.Load, onReload)
+}
+
+// watchFiles calls reload every interval if the fingerprint of the source files has changed since stamp.
+// A failed reload is not retried until the files change again.
+func watchFiles(ctx context.Context, interval time.Duration, stamp string, fingerprint func() string, reload func() error, onReload func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := fingerprint()
+			if current == stamp {
+				continue
+			}
+			stamp = current
+			onReload(reload())
+		}
+	}
+}
+
+// fingerprint is composed of sizes and modification times of the source files
+// (os.Stat follows symlinks, so Kubernetes secret volume updates are detected as well).
+func (cg *CertificateGetter) fingerprint() string {
+	files := []string{cg.clientCAFile}
+	for _, pair := range cg.pairs {
+		files = append(files, pair.Cert, pair.Key, pair.CA)
+	}
+	if cg.revocation != nil {
+		files = append(files, cg.revocation.files()...)
+	}
+	if cg.dir != "" {
+		files = append(files, cg.dir)
+		if entries, err := os.ReadDir(cg.dir); err == nil {
+			for _, entry := range entries {
+				files = append(files, filepath.Join(cg.dir, entry.Name()))
+			}
+		}
+	}
+	return filesFingerprint(files)
+}
+
+func filesFingerprint(files []string) string {
+	var sb strings.Builder
+	for _, fname := range files {
+		if fname == "" {
+			continue
+		}
+		sb.WriteString(fname + "=")
+		if info, err := os.Stat(fname); err == nil {
+			sb.WriteString(strconv.FormatInt(info.Size(), 10) + ":" + strconv.FormatInt(info.ModTime().UnixNano(), 10))
+		}
+		sb.WriteString(";")
+	}
+	return sb.String()
+}
+
+// watchCertificate reloads listener certificate on change, reporting results to log and metrics.
+func watchCertificate(ctx context.Context, l *listener, interval time.Duration, m *tlsMetrics, logger log.MetaLogger) {
+	m.expiry.WithLabelValues(l.cfg.Name).Set(float64(l.certs.NotAfter().Unix()))
+	l.certs.Watch(ctx, interval, func(err error) {
+		if err != nil {
+			m.reloadFailures.WithLabelValues(l.cfg.Name).Inc()
+			logger.Error("certificate reload failed", log.String("listener", l.cfg.Name), log.Error(err))
+			return
+		}
+		m.reloads.WithLabelValues(l.cfg.Name).Inc()
+		m.expiry.WithLabelValues(l.cfg.Name).Set(float64(l.certs.NotAfter().Unix()))
+		logger.Info("certificate reloaded", log.String("listener", l.cfg.Name), log.Time("not_after", l.certs.NotAfter()))
+	})
+}
+
+// watchRevocation reloads CRL files of an ACME listener on change (file based certificates reload them with the certificates).
+func watchRevocation(ctx context.Context, l *listener, interval time.Duration, m *tlsMetrics, logger log.MetaLogger) {
+	rv := l.revocation
+	watchFiles(ctx, interval, rv.stamp, rv.fingerprint, rv.load, func(err error) {
+		if err != nil {
+			m.reloadFailures.WithLabelValues(l.cfg.Name).Inc()
+			logger.Error("revocation lists reload failed", log.String("listener", l.cfg.Name), log.Error(err))
+			return
+		}
+		m.reloads.WithLabelValues(l.cfg.Name).Inc()
+		logger.Info("revocation lists reloaded", log.String("listener", l.cfg.Name))
+	})
 }
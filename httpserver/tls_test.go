@@ -0,0 +1,164 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSelfSigned writes a self-signed certificate and its key to the files
+func writeSelfSigned(t *testing.T, certFile, keyFile, cn string, notAfter time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertificateReload(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	expiry1 := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	writeSelfSigned(t, certFile, keyFile, "one.example.com", expiry1)
+
+	cg := NewCertificateGetter(&ListenerConfig{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSCAFile: filepath.Join(dir, "missing-ca.crt")})
+	a.NoError(cg.Load())
+	a.Equal(expiry1.UTC(), cg.NotAfter().UTC())
+
+	results := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cg.Watch(ctx, 10*time.Millisecond, func(err error) { results <- err })
+
+	// rotated certificate is picked up
+	expiry2 := expiry1.Add(24 * time.Hour)
+	writeSelfSigned(t, certFile, keyFile, "two.example.com", expiry2)
+	select {
+	case err := <-results:
+		a.NoError(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("certificate not reloaded")
+	}
+	a.Equal(expiry2.UTC(), cg.NotAfter().UTC())
+
+	// broken certificate is reported and the previous one is kept
+	a.NoError(os.WriteFile(certFile, []byte("garbage"), 0o600))
+	select {
+	case err := <-results:
+		a.Error(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload failure not reported")
+	}
+	cert, err := cg.GetCertificate(&tls.ClientHelloInfo{})
+	a.NoError(err)
+	a.NotNil(cert)
+	a.Equal(expiry2.UTC(), cg.NotAfter().UTC())
+}
+
+func TestACMEManager(t *testing.T) {
+	a := assert.New(t)
+
+	m, err := newACMEManager(ACMEConfig{Domains: []string{"example.com"}, CacheDir: t.TempDir(), DirectoryURL: "https://127.0.0.1:14000/dir"})
+	a.NoError(err)
+	a.NoError(m.HostPolicy(context.Background(), "example.com"))
+	a.Error(m.HostPolicy(context.Background(), "evil.com"))
+	a.Equal("https://127.0.0.1:14000/dir", m.Client.DirectoryURL)
+
+	tlsConf, err := acmeTLSConfig(&ListenerConfig{}, TLSParams{}, m)
+	a.NoError(err)
+	a.Contains(tlsConf.NextProtos, "acme-tls/1")
+
+	cfg := Config{UseTLS: true, ACME: ACMEConfig{Enabled: true}}
+	a.Error(cfg.Validate(), "domains required")
+}
+
+func TestSNICertificates(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	certDir := filepath.Join(dir, "certs")
+	a.NoError(os.Mkdir(certDir, 0o700))
+	expiry := time.Now().Add(24 * time.Hour)
+	writeSelfSigned(t, filepath.Join(dir, "default.crt"), filepath.Join(dir, "default.key"), "default.example.org", expiry)
+	writeSelfSigned(t, filepath.Join(dir, "api.crt"), filepath.Join(dir, "api.key"), "api.example.com", expiry)
+	writeSelfSigned(t, filepath.Join(certDir, "wildcard.crt"), filepath.Join(certDir, "wildcard.key"), "*.example.com", expiry)
+	a.NoError(os.WriteFile(filepath.Join(certDir, "orphan.crt"), []byte("no key"), 0o600))
+
+	cg := NewCertificateGetter(&ListenerConfig{
+		TLSCertFile:     filepath.Join(dir, "default.crt"),
+		TLSKeyFile:      filepath.Join(dir, "default.key"),
+		TLSCertificates: []CertificateConfig{{Cert: filepath.Join(dir, "api.crt"), Key: filepath.Join(dir, "api.key")}},
+		TLSCertDir:      certDir,
+	})
+	a.NoError(cg.Load())
+
+	for serverName, expected := range map[string]string{
+		"API.example.com.":    "api.example.com",
+		"www.example.com":     "*.example.com",
+		"a.b.example.com":     "default.example.org",
+		"":                    "default.example.org",
+		"unknown.example.net": "default.example.org",
+	} {
+		cert, err := cg.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		a.NoError(err)
+		a.Equal(expected, cert.Leaf.Subject.CommonName, serverName)
+	}
+
+	a.ErrorIs(NewCertificateGetter(&ListenerConfig{}).Load(), errNoCertificates)
+}
+
+func TestTLSParams(t *testing.T) {
+	a := assert.New(t)
+
+	conf := &tls.Config{} //nolint:gosec
+	params := TLSParams{
+		MinVersion:   "1.3",
+		CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+		Curves:       []string{"X25519", "P256"},
+	}
+	a.NoError(params.apply(conf))
+	a.Equal(uint16(tls.VersionTLS13), conf.MinVersion)
+	a.Equal(uint16(tls.VersionTLS13), conf.MaxVersion)
+	a.Equal([]uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, conf.CipherSuites)
+	a.Equal([]tls.CurveID{tls.X25519, tls.CurveP256}, conf.CurvePreferences)
+
+	a.ErrorIs((&TLSParams{MinVersion: "1.4"}).apply(conf), errUnknownTLSVersion)
+	a.ErrorIs((&TLSParams{CipherSuites: []string{"TLS_NOPE"}}).apply(conf), errUnknownCipherSuite)
+	a.ErrorIs((&TLSParams{Curves: []string{"P224"}}).apply(conf), errUnknownCurve)
+	a.ErrorIs((&TLSParams{MinVersion: "1.3", MaxVersion: "1.2"}).apply(conf), errTLSVersionRange)
+}
@@ -0,0 +1,274 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Sink types
+const (
+	SinkStderr  = "stderr"
+	SinkStdout  = "stdout"
+	SinkFile    = "file"
+	SinkSyslog  = "syslog"
+	SinkNetwork = "network"
+
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
+
+	defaultTimeFormat = "2006-01-02T15:04:05.000Z"
+	samplingTick      = time.Second
+	samplingFirst     = 100
+)
+
+var (
+	errUnknownLevel    = errors.New("unknown log level")
+	errUnknownSink     = errors.New("unknown log sink")
+	errUnknownEncoding = errors.New("unknown log encoding")
+	errNoSinkAddress   = errors.New("sink address is not set")
+	errNoSinkPath      = errors.New("sink path is not set")
+)
+
+// Config defines logger output
+type Config struct {
+	Level      string            `yaml:"level" description:"Minimum log level (info if empty)" choices:"debug,info,warn,error,dpanic,panic,fatal"`
+	Modules    map[string]string `yaml:"modules" description:"Levels of named sub-loggers, e.g. dbase: debug (see Named)"`
+	Oneline    bool              `yaml:"oneline" description:"Merge request log records into one line (see Flush)"`
+	Encoding   string            `yaml:"encoding" description:"Record encoding" default:"json" choices:"json,console"`
+	Color      bool              `yaml:"color" description:"Colored levels in console encoding"`
+	TimeFormat string            `yaml:"timeFormat" description:"Go time layout or unix, unixMillis, unixNano" default:"2006-01-02T15:04:05.000Z"`
+	LocalTime  bool              `yaml:"localTime" description:"Log local time instead of UTC"`
+	Keys       KeysConfig        `yaml:"keys" description:"Field key names"`
+	Sinks      []SinkConfig      `yaml:"sinks" description:"Log outputs (stderr if empty)"`
+	Redact     RedactConfig      `yaml:"redact" description:"Masking of sensitive values"`
+	Dedup      DedupConfig       `yaml:"dedup" description:"Suppression of repeated records"`
+}
+
+// KeysConfig defines field names of the log record
+type KeysConfig struct {
+	Time       string `yaml:"time" description:"Time key" default:"time"`
+	Level      string `yaml:"level" description:"Level key" default:"level"`
+	Message    string `yaml:"message" description:"Message key" default:"msg"`
+	Name       string `yaml:"name" description:"Logger name key" default:"logger"`
+	Caller     string `yaml:"caller" description:"Caller key" default:"caller"`
+	Stacktrace string `yaml:"stacktrace" description:"Stacktrace key" default:"stacktrace"`
+}
+
+// SinkConfig defines a log output
+type SinkConfig struct {
+	Type     string `yaml:"type" description:"Sink type" choices:"stderr,stdout,file,syslog,network"`
+	Level    string `yaml:"level" description:"Minimum level for this sink (logger level if empty)"`
+	Encoding string `yaml:"encoding" description:"Record encoding (logger encoding if empty)" choices:"json,console"`
+	// file
+	Path        string        `yaml:"path" description:"Log file path (file)"`
+	MaxSizeMB   int           `yaml:"maxSizeMB" description:"Rotate the file when it exceeds this size (file, 0 = no limit)"`
+	RotateEvery time.Duration `yaml:"rotateEvery" description:"Rotate the file every interval, e.g. 24h (file, 0 = never)"`
+	MaxAge      time.Duration `yaml:"maxAge" description:"Delete rotated files older than this (file, 0 = keep)"`
+	MaxBackups  int           `yaml:"maxBackups" description:"Keep at most this many rotated files (file, 0 = all)"`
+	// syslog, network
+	Address  string `yaml:"address" description:"scheme://host:port, scheme is tcp, udp or unix (network; syslog: local syslog if empty)"`
+	Facility string `yaml:"facility" description:"Syslog facility (syslog)" choices:"user,daemon,local0,local1,local2,local3,local4,local5,local6,local7"`
+	Tag      string `yaml:"tag" description:"Syslog tag (syslog, program name if empty)"`
+}
+
+var zapLevels = map[string]zapcore.Level{ //nolint:gochecknoglobals
+	"debug":  zap.DebugLevel,
+	"info":   zap.InfoLevel,
+	"warn":   zap.WarnLevel,
+	"error":  zap.ErrorLevel,
+	"dpanic": zap.DPanicLevel,
+	"panic":  zap.PanicLevel,
+	"fatal":  zap.FatalLevel,
+}
+
+func parseLevel(level string) (zapcore.Level, error) {
+	if level == "" {
+		return zap.InfoLevel, nil
+	}
+	l, found := zapLevels[strings.ToLower(level)]
+	if !found {
+		return l, fmt.Errorf("%w: %s", errUnknownLevel, level)
+	}
+	return l, nil
+}
+
+// NewWithConfig returns a new logger writing to the configured sinks
+func NewWithConfig(cfg Config) (MetaLogger, error) {
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	sinks := cfg.Sinks
+	if len(sinks) == 0 {
+		sinks = []SinkConfig{{Type: SinkStderr}}
+	}
+	cores := make([]zapcore.Core, 0, len(sinks))
+	closers := make([]func() error, 0, len(sinks))
+	closeAll := func() {
+		for _, closer := range closers {
+			_ = closer()
+		}
+	}
+	var redactor *redactor
+	if cfg.Redact.Enabled {
+		if redactor, err = newRedactor(cfg.Redact); err != nil {
+			return nil, err
+		}
+	}
+	for i, sink := range sinks {
+		core, closer, err := newSinkCore(cfg, sink)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("log sink #%d (%s): %w", i+1, sink.Type, err)
+		}
+		if redactor != nil {
+			core = &redactCore{Core: core, redactor: redactor}
+		}
+		cores = append(cores, core)
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+	}
+
+	levels := newLevels(level)
+	for module, moduleLevel := range cfg.Modules {
+		if err := levels.SetLevel(module, moduleLevel); err != nil {
+			closeAll()
+			return nil, fmt.Errorf("module %s: %w", module, err)
+		}
+	}
+	var core zapcore.Core = zapcore.NewTee(cores...)
+	var options []zap.Option
+	if level == zap.DebugLevel {
+		// as in zap production config
+		core = zapcore.NewSamplerWithOptions(core, samplingTick, samplingFirst, samplingFirst)
+		options = append(options, zap.AddCaller())
+	}
+	options = append(options, zap.ErrorOutput(zapcore.Lock(os.Stderr)))
+
+	var dedup *deduplicator
+	if cfg.Dedup.Enabled {
+		if dedup, err = newDeduplicator(cfg.Dedup, core); err != nil {
+			closeAll()
+			return nil, fmt.Errorf("dedup: %w", err)
+		}
+		core = &dedupCore{Core: core, dedup: dedup}
+	}
+	core = &filterCore{Core: core, level: levels.levelOf("")}
+
+	return &logger{externalLogger: zap.New(core, options...), oneline: cfg.Oneline, level: zeroLevel, levels: levels, dedup: dedup}, nil
+}
+
+// newSinkCore returns zap core writing to the sink and optional close function
+func newSinkCore(cfg Config, sink SinkConfig) (zapcore.Core, func() error, error) {
+	sinkLevel := zap.DebugLevel // logger level is applied at runtime (see Levels)
+	if sink.Level != "" {
+		l, err := parseLevel(sink.Level)
+		if err != nil {
+			return nil, nil, err
+		}
+		sinkLevel = l
+	}
+	encoding := sink.Encoding
+	if encoding == "" {
+		encoding = cfg.Encoding
+	}
+	encoder, err := newEncoder(cfg, encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		ws     zapcore.WriteSyncer
+		closer func() error
+	)
+	switch sink.Type {
+	case "", SinkStderr:
+		ws = zapcore.Lock(os.Stderr)
+	case SinkStdout:
+		ws = zapcore.Lock(os.Stdout)
+	case SinkFile:
+		if sink.Path == "" {
+			return nil, nil, errNoSinkPath
+		}
+		f, err := newRotatingFile(sink)
+		if err != nil {
+			return nil, nil, err
+		}
+		ws, closer = f, f.Close
+	case SinkNetwork:
+		if sink.Address == "" {
+			return nil, nil, errNoSinkAddress
+		}
+		n, err := newNetworkWriter(sink.Address)
+		if err != nil {
+			return nil, nil, err
+		}
+		ws, closer = n, n.Close
+	case SinkSyslog:
+		return newSyslogCore(sink, encoder, sinkLevel)
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", errUnknownSink, sink.Type)
+	}
+	return zapcore.NewCore(encoder, ws, sinkLevel), closer, nil
+}
+
+func newEncoder(cfg Config, encoding string) (zapcore.Encoder, error) {
+	encCfg := zap.NewProductionEncoderConfig()
+	keys := cfg.Keys
+	encCfg.TimeKey = keyOr(keys.Time, "time")
+	encCfg.LevelKey = keyOr(keys.Level, "level")
+	encCfg.MessageKey = keyOr(keys.Message, "msg")
+	encCfg.NameKey = keyOr(keys.Name, "logger")
+	encCfg.CallerKey = keyOr(keys.Caller, "caller")
+	encCfg.StacktraceKey = keyOr(keys.Stacktrace, "stacktrace")
+	encCfg.EncodeTime = newTimeEncoder(cfg.TimeFormat, cfg.LocalTime)
+
+	switch encoding {
+	case "", EncodingJSON:
+		return zapcore.NewJSONEncoder(encCfg), nil
+	case EncodingConsole:
+		encCfg.EncodeLevel = zapcore.CapitalLevelEncoder
+		if cfg.Color {
+			encCfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		return zapcore.NewConsoleEncoder(encCfg), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownEncoding, encoding)
+	}
+}
+
+func keyOr(key, def string) string {
+	if key == "" {
+		return def
+	}
+	return key
+}
+
+func newTimeEncoder(format string, local bool) zapcore.TimeEncoder {
+	switch format {
+	case "unix":
+		return zapcore.EpochTimeEncoder
+	case "unixMillis":
+		return zapcore.EpochMillisTimeEncoder
+	case "unixNano":
+		return zapcore.EpochNanosTimeEncoder
+	case "", defaultTimeFormat:
+		if !local {
+			return timeEncoder
+		}
+		format = "2006-01-02T15:04:05.000Z07:00"
+	}
+	return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		if !local {
+			t = t.UTC()
+		}
+		enc.AppendByteString(t.AppendFormat([]byte{}, format))
+	}
+}
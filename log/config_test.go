@@ -0,0 +1,175 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSinks(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	all, errs := filepath.Join(dir, "all.log"), filepath.Join(dir, "errors.log")
+	logger, err := NewWithConfig(Config{
+		Level: "debug",
+		Keys:  KeysConfig{Time: "ts", Message: "message"},
+		Sinks: []SinkConfig{
+			{Type: SinkFile, Path: all},
+			{Type: SinkFile, Path: errs, Level: "error", Encoding: EncodingConsole},
+		},
+	})
+	a.NoError(err)
+	logger.Info("hello", String("key", "value"))
+	logger.Error("failure", Int("code", 42))
+	a.NoError(logger.Sync())
+
+	data, err := os.ReadFile(all)
+	a.NoError(err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	a.Len(lines, 2)
+	var record map[string]any
+	a.NoError(json.Unmarshal([]byte(lines[0]), &record))
+	a.Equal("hello", record["message"])
+	a.Equal("value", record["key"])
+	a.Contains(record, "ts")
+
+	data, err = os.ReadFile(errs)
+	a.NoError(err)
+	lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+	a.Len(lines, 1)
+	a.Contains(lines[0], "ERROR")
+	a.Contains(lines[0], "failure")
+	a.Contains(lines[0], `{"code": 42}`)
+
+	_, err = NewWithConfig(Config{Sinks: []SinkConfig{{Type: "pigeon"}}})
+	a.Error(err)
+	_, err = NewWithConfig(Config{Level: "loud"})
+	a.Error(err)
+}
+
+func TestRotatingFile(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	f, err := newRotatingFile(SinkConfig{Path: filepath.Join(dir, "app.log"), RotateEvery: time.Hour, MaxBackups: 2})
+	a.NoError(err)
+	f.now = func() time.Time { return now }
+	f.period = f.periodOf(now)
+	f.maxSize = 10
+
+	write := func(s string) {
+		_, err := f.Write([]byte(s))
+		a.NoError(err)
+	}
+	write("12345\n")
+	write("12345\n") // size exceeded: rotated
+	now = now.Add(time.Minute)
+	write("abc\n")
+	now = now.Add(time.Hour) // next period: rotated
+	write("def\n")
+	now = now.Add(time.Hour)
+	write("ghi\n") // third backup, the oldest is removed
+	a.NoError(f.Close())
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	a.Len(backups, 2)
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	a.Equal("ghi\n", string(data))
+}
+
+func TestRotationBackups(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	sibling := filepath.Join(dir, "app-access.log")
+	siblingBackup := filepath.Join(dir, "app-access-20250101T000000.000.log")
+	for _, name := range []string{sibling, siblingBackup} {
+		a.NoError(os.WriteFile(name, []byte("access\n"), 0o600))
+		a.NoError(os.Chtimes(name, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour)))
+	}
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.Local)
+	f, err := newRotatingFile(SinkConfig{Path: filepath.Join(dir, "app.log"), MaxBackups: 2, MaxAge: time.Hour})
+	a.NoError(err)
+	f.now = func() time.Time { return now }
+	f.maxSize = 4
+
+	for _, s := range []string{"1\n", "2\n", "3\n", "4\n", "5\n", "6\n", "7\n", "8\n"} {
+		_, err := f.Write([]byte(s)) // rotated every second write within the same millisecond
+		a.NoError(err)
+	}
+	a.NoError(f.Close())
+
+	a.FileExists(sibling, "other sink files are kept")
+	a.FileExists(siblingBackup)
+	backups := f.backups()
+	a.Len(backups, 2)
+	var content []string
+	for _, b := range backups {
+		data, _ := os.ReadFile(b.path)
+		content = append(content, string(data))
+	}
+	a.Equal([]string{"3\n4\n", "5\n6\n"}, content, "the newest backups are kept, none overwritten")
+}
+
+func TestNetworkSink(t *testing.T) {
+	a := assert.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NoError(err)
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	logger, err := NewWithConfig(Config{Sinks: []SinkConfig{{Type: SinkNetwork, Address: "tcp://" + ln.Addr().String()}}})
+	a.NoError(err)
+	logger.Warn("over the wire")
+
+	select {
+	case line := <-received:
+		a.Contains(line, `"msg":"over the wire"`)
+	case <-time.After(time.Second):
+		a.Fail("log record not received")
+	}
+}
+
+func TestNetworkSinkNonBlocking(t *testing.T) {
+	a := assert.New(t)
+
+	stalled, err := net.Listen("tcp", "127.0.0.1:0") // accepts but never reads
+	a.NoError(err)
+	defer stalled.Close()
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NoError(err)
+	down.Close() // refuses connections
+
+	record := []byte(strings.Repeat("x", 64<<10) + "\n")
+	for _, addr := range []string{stalled.Addr().String(), down.Addr().String()} {
+		w, err := newNetworkWriter(addr)
+		a.NoError(err)
+		start := time.Now()
+		for range 2 * networkQueueSize {
+			n, err := w.Write(record)
+			a.NoError(err)
+			a.Equal(len(record), n)
+		}
+		a.Less(time.Since(start), time.Second, addr)
+		a.NoError(w.Close())
+	}
+}
@@ -0,0 +1,95 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// ContextFields returns log fields describing the request of the context (see RegisterContextFields)
+type ContextFields func(ctx context.Context) []Field
+
+var (
+	defaultLogger atomic.Pointer[MetaLogger] //nolint:gochecknoglobals
+	extractorsMu  sync.RWMutex               //nolint:gochecknoglobals
+	extractors    []ContextFields            //nolint:gochecknoglobals
+)
+
+// RegisterContextFields adds fields the default logger is enriched with by FromContext. Packages carrying request
+// data in context register their fields on init (correlation: request ID, trace and span; apiauth: principal).
+func RegisterContextFields(f ContextFields) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	extractors = append(extractors, f)
+}
+
+// SetDefault sets the logger returned by FromContext when context carries none (app sets its logger on Run)
+func SetDefault(l MetaLogger) {
+	defaultLogger.Store(&l)
+}
+
+// Default returns the default logger (info level JSON to stderr unless set by SetDefault)
+func Default() MetaLogger {
+	if l := defaultLogger.Load(); l != nil {
+		return *l
+	}
+	l, err := New("info", false)
+	if err != nil {
+		l = NewNop()
+	}
+	if defaultLogger.CompareAndSwap(nil, &l) {
+		return l
+	}
+	return *defaultLogger.Load()
+}
+
+// WithContext returns context carrying the logger
+func WithContext(ctx context.Context, l MetaLogger) context.Context {
+	return context.WithValue(ctx, ContextMetaLogger, l)
+}
+
+// FromContext returns the logger of the context (see WithContext, httpserver puts a request logger there) or the
+// default logger enriched with the registered context fields (see RegisterContextFields). It never panics.
+func FromContext(ctx context.Context) MetaLogger {
+	if ctx == nil {
+		return Default()
+	}
+	if l, ok := ctx.Value(ContextMetaLogger).(MetaLogger); ok && l != nil {
+		return l
+	}
+	if fields := contextFields(ctx); len(fields) > 0 {
+		return Default().With(fields...)
+	}
+	return Default()
+}
+
+// contextFields returns the registered fields of the context
+func contextFields(ctx context.Context) []Field {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	var fields []Field
+	for _, f := range extractors {
+		fields = append(fields, f(ctx)...)
+	}
+	return fields
+}
+
+// DebugCtx logs a debug message with the logger of the context (see FromContext)
+func DebugCtx(ctx context.Context, msg string, fields ...Field) {
+	FromContext(ctx).Debug(msg, fields...)
+}
+
+// InfoCtx logs an info message with the logger of the context (see FromContext)
+func InfoCtx(ctx context.Context, msg string, fields ...Field) {
+	FromContext(ctx).Info(msg, fields...)
+}
+
+// WarnCtx logs a warning with the logger of the context (see FromContext)
+func WarnCtx(ctx context.Context, msg string, fields ...Field) {
+	FromContext(ctx).Warn(msg, fields...)
+}
+
+// ErrorCtx logs an error with the logger of the context (see FromContext)
+func ErrorCtx(ctx context.Context, msg string, fields ...Field) {
+	FromContext(ctx).Error(msg, fields...)
+}
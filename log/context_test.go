@@ -0,0 +1,52 @@
+package log_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bhmj/goblocks/apiauth"
+	"github.com/bhmj/goblocks/correlation"
+	"github.com/bhmj/goblocks/log"
+	"github.com/bhmj/goblocks/log/logtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	a := assert.New(t)
+
+	defaults := logtest.New()
+	log.SetDefault(defaults)
+	a.Same(defaults, log.Default())
+
+	// no logger in context: default one, enriched
+	log.InfoCtx(context.Background(), "plain")
+	defaults.AssertLogged(t, "info", "plain")
+	a.Empty(defaults.Entries()[0].Fields)
+
+	trace := correlation.NewTrace()
+	ctx := correlation.WithRequestID(context.Background(), "r1")
+	ctx = correlation.WithTrace(ctx, trace)
+	ctx = apiauth.WithPrincipal(ctx, &apiauth.Principal{Name: "alice", Provider: "jwt"})
+	log.WarnCtx(ctx, "enriched", log.Int("n", 1))
+	defaults.AssertLogged(t, "warn", "enriched",
+		log.String("rid", "r1"),
+		log.String("trace", trace.TraceIDString()),
+		log.String("span", trace.SpanIDString()),
+		log.String("principal", "alice"),
+		log.String("auth", "jwt"),
+		log.Int("n", 1),
+	)
+
+	// logger in context is used as is
+	request := logtest.New()
+	ctx = log.WithContext(ctx, request)
+	a.Same(request, log.FromContext(ctx))
+	log.ErrorCtx(ctx, "failed")
+	log.DebugCtx(ctx, "details")
+	request.AssertLogged(t, "error", "failed")
+	request.AssertLogged(t, "debug", "details")
+	a.Empty(request.FilterField(log.String("rid", "r1")))
+	defaults.AssertNotLogged(t, "", "failed")
+
+	a.Same(defaults, log.FromContext(nil)) //nolint:staticcheck
+}
@@ -0,0 +1,258 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultDedupWindow = 10 * time.Second
+	defaultDedupBurst  = 5
+	maxDedupKeys       = 10000
+	summaryMessage     = "suppressed similar messages"
+)
+
+// DedupConfig defines rate limiting of repeated log records
+type DedupConfig struct {
+	Enabled bool          `yaml:"enabled" description:"Suppress repeated records logging a summary instead"`
+	Window  time.Duration `yaml:"window" description:"Period of counting similar records" default:"10s"`
+	Burst   int           `yaml:"burst" description:"Similar records logged per window before suppressing" default:"5"`
+	Keys    []string      `yaml:"keys" description:"Fields whose values (along with level and message) make records similar, e.g. error"`
+	Level   string        `yaml:"level" description:"Minimum level of deduplicated records (all if empty)" choices:"debug,info,warn,error"`
+}
+
+// deduplicator counts similar records shared by all clones of the logger
+type deduplicator struct {
+	mu         sync.Mutex
+	window     time.Duration
+	burst      int
+	keys       map[string]bool
+	level      zapcore.Level
+	root       zapcore.Core // summaries are written without context fields
+	similar    map[string]*similarRecords
+	messages   *prometheus.CounterVec
+	suppressed *prometheus.CounterVec
+}
+
+// similarRecords is a window of similar records
+type similarRecords struct {
+	start      time.Time
+	count      int
+	suppressed int
+	level      zapcore.Level
+	message    string
+	fields     []zapcore.Field // key fields
+	timer      *time.Timer     // summary at the end of the window
+}
+
+func newDeduplicator(cfg DedupConfig, root zapcore.Core) (*deduplicator, error) {
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Level == "" {
+		level = zapcore.DebugLevel
+	}
+	d := &deduplicator{
+		window:  cfg.Window,
+		burst:   cfg.Burst,
+		keys:    make(map[string]bool, len(cfg.Keys)),
+		level:   level,
+		root:    root,
+		similar: make(map[string]*similarRecords),
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "messages_total",
+			Help: "Log records by level (before deduplication)",
+		}, []string{"level"}),
+		suppressed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "suppressed_messages_total",
+			Help: "Log records suppressed as similar to recent ones",
+		}, []string{"level"}),
+	}
+	if d.window <= 0 {
+		d.window = defaultDedupWindow
+	}
+	if d.burst <= 0 {
+		d.burst = defaultDedupBurst
+	}
+	for _, key := range cfg.Keys {
+		d.keys[key] = true
+	}
+	return d, nil
+}
+
+// RegisterMetrics registers log record counters (log_messages_total, log_suppressed_messages_total) if deduplication is enabled
+func RegisterMetrics(l MetaLogger, registry prometheus.Registerer) error {
+	lg, ok := unwrap(l)
+	if !ok || lg.dedup == nil {
+		return nil
+	}
+	registry = prometheus.WrapRegistererWithPrefix("log_", registry)
+	for _, c := range []prometheus.Collector{lg.dedup.messages, lg.dedup.suppressed} {
+		if err := registry.Register(c); err != nil {
+			return fmt.Errorf("register log metrics: %w", err)
+		}
+	}
+	return nil
+}
+
+// allow reports whether the record is to be written, counting suppressed ones
+func (d *deduplicator) allow(entry zapcore.Entry, context, fields []zapcore.Field) bool {
+	level := entry.Level.String()
+	d.messages.WithLabelValues(level).Inc()
+	if entry.Level < d.level || entry.Level >= zapcore.DPanicLevel {
+		return true
+	}
+	keyFields := d.keyFields(context, fields)
+	key := similarKey(entry, keyFields)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, found := d.similar[key]
+	if !found || entry.Time.Sub(s.start) >= d.window {
+		if !found && len(d.similar) >= maxDedupKeys && !d.cleanup(entry.Time) {
+			return true // too many distinct records: pass
+		}
+		s = &similarRecords{start: entry.Time, level: entry.Level, message: entry.Message, fields: keyFields}
+		d.similar[key] = s
+	}
+	s.count++
+	if s.count <= d.burst {
+		return true
+	}
+	s.suppressed++
+	d.suppressed.WithLabelValues(level).Inc()
+	if s.timer == nil {
+		s.timer = time.AfterFunc(d.window-entry.Time.Sub(s.start), func() { d.flush(key, s) })
+	}
+	return false
+}
+
+// flush writes the summary of the window
+func (d *deduplicator) flush(key string, s *similarRecords) {
+	d.mu.Lock()
+	if d.similar[key] == s {
+		delete(d.similar, key)
+	}
+	suppressed := s.suppressed
+	s.suppressed = 0
+	d.mu.Unlock()
+	d.summary(s, suppressed)
+}
+
+// flushAll writes summaries of all windows having suppressed records
+func (d *deduplicator) flushAll() {
+	d.mu.Lock()
+	var pending []*similarRecords
+	var counts []int
+	for key, s := range d.similar {
+		if s.suppressed > 0 {
+			if s.timer != nil {
+				s.timer.Stop()
+			}
+			pending, counts = append(pending, s), append(counts, s.suppressed)
+			s.suppressed = 0
+			delete(d.similar, key)
+		}
+	}
+	d.mu.Unlock()
+	for i, s := range pending {
+		d.summary(s, counts[i])
+	}
+}
+
+func (d *deduplicator) summary(s *similarRecords, suppressed int) {
+	if suppressed == 0 {
+		return
+	}
+	entry := zapcore.Entry{Level: s.level, Time: time.Now(), Message: summaryMessage}
+	fields := append([]zapcore.Field{
+		zap.String("similar", s.message),
+		zap.Int("suppressed", suppressed),
+		zap.Duration("window", d.window),
+	}, s.fields...)
+	if checked := d.root.Check(entry, nil); checked != nil {
+		checked.Write(fields...)
+	}
+}
+
+// cleanup removes expired windows without suppressed records, reports whether there is room for a new one
+func (d *deduplicator) cleanup(now time.Time) bool {
+	for key, s := range d.similar {
+		if s.timer == nil && now.Sub(s.start) >= d.window {
+			delete(d.similar, key)
+		}
+	}
+	return len(d.similar) < maxDedupKeys
+}
+
+// keyFields returns the fields making records similar
+func (d *deduplicator) keyFields(context, fields []zapcore.Field) []zapcore.Field {
+	if len(d.keys) == 0 {
+		return nil
+	}
+	var result []zapcore.Field
+	for _, list := range [][]zapcore.Field{context, fields} {
+		for _, field := range list {
+			if d.keys[field.Key] {
+				result = append(result, field)
+			}
+		}
+	}
+	return result
+}
+
+func similarKey(entry zapcore.Entry, fields []zapcore.Field) string {
+	var sb strings.Builder
+	sb.WriteString(entry.Level.String())
+	sb.WriteByte(0)
+	sb.WriteString(entry.Message)
+	if len(fields) > 0 {
+		enc := zapcore.NewMapObjectEncoder()
+		for _, field := range fields {
+			field.AddTo(enc)
+			fmt.Fprintf(&sb, "\x00%s=%v", field.Key, enc.Fields[field.Key])
+		}
+	}
+	return sb.String()
+}
+
+// dedupCore suppresses repeated records
+type dedupCore struct {
+	zapcore.Core
+	dedup   *deduplicator
+	context []zapcore.Field // key fields added by With
+}
+
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dedupCore{Core: c.Core.With(fields), dedup: c.dedup, context: append(c.context[:len(c.context):len(c.context)], c.dedup.keyFields(nil, fields)...)}
+}
+
+func (c *dedupCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write passes the record through Check of the wrapped core to keep sampling and sink levels
+func (c *dedupCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if !c.dedup.allow(entry, c.context, fields) {
+		return nil
+	}
+	if checked := c.Core.Check(entry, nil); checked != nil {
+		checked.Write(fields...)
+	}
+	return nil
+}
+
+func (c *dedupCore) Sync() error {
+	c.dedup.flushAll()
+	return c.Core.Sync() //nolint:wrapcheck
+}
@@ -0,0 +1,75 @@
+package log
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDedup(t *testing.T) {
+	a := assert.New(t)
+
+	observed, logs := observer.New(zapcore.DebugLevel)
+	dedup, err := newDeduplicator(DedupConfig{Window: 50 * time.Millisecond, Burst: 2, Keys: []string{"error"}, Level: "warn"}, observed)
+	a.NoError(err)
+	lg := &logger{externalLogger: zap.New(&dedupCore{Core: observed, dedup: dedup}), level: zeroLevel, dedup: dedup}
+	registry := prometheus.NewRegistry()
+	a.NoError(RegisterMetrics(lg, registry))
+
+	request := lg.With(String("rid", "r1"))
+	for range 10 {
+		request.Error("query failed", Error(errors.New("timeout")))
+	}
+	for range 3 {
+		lg.Error("query failed", Error(errors.New("refused"))) // different key field
+		lg.Info("request")                                     // below dedup level
+	}
+	a.Equal(2, logs.FilterMessage("query failed").FilterField(zap.String("error", "timeout")).Len())
+	a.Equal(2, logs.FilterMessage("query failed").FilterField(zap.String("error", "refused")).Len())
+	a.Equal(3, logs.FilterMessage("request").Len())
+
+	// summary at the end of the window
+	a.Eventually(func() bool { return logs.FilterMessage(summaryMessage).Len() == 2 }, time.Second, 10*time.Millisecond)
+	summary := logs.FilterMessage(summaryMessage).FilterField(zap.String("error", "timeout")).All()[0]
+	a.Equal(zapcore.ErrorLevel, summary.Level)
+	a.Equal(map[string]any{"similar": "query failed", "suppressed": int64(8), "window": 50 * time.Millisecond, "error": "timeout"}, summary.ContextMap())
+
+	// new window
+	request.Error("query failed", Error(errors.New("timeout")))
+	a.Equal(3, logs.FilterMessage("query failed").FilterField(zap.String("error", "timeout")).Len())
+
+	// pending summaries are written on Sync
+	for range 3 {
+		lg.Warn("slow")
+	}
+	a.NoError(lg.Sync())
+	a.Equal(1, logs.FilterMessage(summaryMessage).FilterField(zap.String("similar", "slow")).Len())
+
+	a.InDelta(9.0, testutil.ToFloat64(dedup.suppressed.WithLabelValues("error")), 0)
+	a.InDelta(1.0, testutil.ToFloat64(dedup.suppressed.WithLabelValues("warn")), 0)
+	a.InDelta(3.0, testutil.ToFloat64(dedup.messages.WithLabelValues("info")), 0)
+	count, err := testutil.GatherAndCount(registry, "log_messages_total", "log_suppressed_messages_total")
+	a.NoError(err)
+	a.Equal(5, count)
+}
+
+func TestDedupConfig(t *testing.T) {
+	a := assert.New(t)
+
+	l, err := NewWithConfig(Config{Dedup: DedupConfig{Enabled: true}, Sinks: []SinkConfig{{Type: SinkStdout}}})
+	a.NoError(err)
+	a.NotNil(l.(*logger).dedup)
+	a.Equal(defaultDedupWindow, l.(*logger).dedup.window)
+	a.NoError(RegisterMetrics(l, prometheus.NewRegistry()))
+
+	_, err = NewWithConfig(Config{Dedup: DedupConfig{Enabled: true, Level: "loud"}})
+	a.Error(err)
+	a.NoError(RegisterMetrics(NewNop(), prometheus.NewRegistry()))
+}
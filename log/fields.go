@@ -0,0 +1,157 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type (
+	// ObjectEncoder receives fields of a LogObject
+	ObjectEncoder = zapcore.ObjectEncoder
+	// ArrayEncoder receives elements of a LogArray
+	ArrayEncoder = zapcore.ArrayEncoder
+)
+
+// LogObject is a type logged as a nested object without reflection (zapcore.ObjectMarshaler satisfies it)
+type LogObject interface {
+	MarshalLogObject(enc ObjectEncoder) error
+}
+
+// LogArray is a type logged as an array without reflection (zapcore.ArrayMarshaler satisfies it)
+type LogArray interface {
+	MarshalLogArray(enc ArrayEncoder) error
+}
+
+// Uint64 logs an unsigned integer
+func Uint64(key string, val uint64) Field {
+	return Field{Key: key, Type: Uint64Type, Integer: int64(val)} //nolint:gosec
+}
+
+// Bytes logs binary data as base64
+func Bytes(key string, val []byte) Field {
+	return Field{Key: key, Type: BytesType, Interface: val}
+}
+
+// Hex logs binary data as a hex string
+func Hex(key string, val []byte) Field {
+	return Field{Key: key, Type: HexType, Interface: val}
+}
+
+// Object logs a nested object
+func Object(key string, val LogObject) Field {
+	return Field{Key: key, Type: ObjectType, Interface: val}
+}
+
+// Array logs an array
+func Array(key string, val LogArray) Field {
+	return Field{Key: key, Type: ArrayType, Interface: val}
+}
+
+// Namespace puts the fields following it (also added by With) into a nested object
+func Namespace(key string) Field {
+	return Field{Key: key, Type: NamespaceType}
+}
+
+// Errors logs errors as an array of {"msg", "causes", "errors", "stack"} objects: causes are messages of wrapped errors,
+// errors are members of a joined error and stack is the verbose (%+v) form if it differs from the message.
+func Errors(key string, errs ...error) Field {
+	return Field{Key: key, Type: ErrorsType, Interface: errs}
+}
+
+type errorArray []error
+
+func (a errorArray) MarshalLogArray(enc ArrayEncoder) error {
+	for _, err := range a {
+		if err == nil {
+			continue
+		}
+		if err := enc.AppendObject(errorObject{err}); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+	return nil
+}
+
+type errorObject struct {
+	err error
+}
+
+func (e errorObject) MarshalLogObject(enc ObjectEncoder) error {
+	msg := e.err.Error()
+	enc.AddString("msg", msg)
+	if verbose := fmt.Sprintf("%+v", e.err); verbose != msg {
+		enc.AddString("stack", verbose)
+	}
+	var causes []string
+	for cause := e.err; cause != nil; {
+		if joined, ok := cause.(interface{ Unwrap() []error }); ok {
+			return addCausesAndErrors(enc, causes, joined.Unwrap())
+		}
+		if cause = errors.Unwrap(cause); cause != nil {
+			causes = append(causes, cause.Error())
+		}
+	}
+	return addCausesAndErrors(enc, causes, nil)
+}
+
+func addCausesAndErrors(enc ObjectEncoder, causes []string, joined []error) error {
+	if len(causes) > 0 {
+		if err := enc.AddArray("causes", stringArray(causes)); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+	if len(joined) > 0 {
+		return enc.AddArray("errors", errorArray(joined)) //nolint:wrapcheck
+	}
+	return nil
+}
+
+type stringArray []string
+
+func (s stringArray) MarshalLogArray(enc ArrayEncoder) error {
+	for _, str := range s {
+		enc.AppendString(str)
+	}
+	return nil
+}
+
+// SlogAttrs converts fields to slog attributes producing the same output via SlogHandler.
+// Fields following a Namespace are grouped under its key.
+func SlogAttrs(fields ...Field) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for i, field := range fields {
+		var attr slog.Attr
+		switch field.Type {
+		case BoolType:
+			attr = slog.Bool(field.Key, field.Integer > 0)
+		case IntType, IntpType:
+			attr = slog.Int64(field.Key, field.Integer)
+		case Uint64Type:
+			attr = slog.Uint64(field.Key, uint64(field.Integer)) //nolint:gosec
+		case Float64Type:
+			attr = slog.Float64(field.Key, field.Float64)
+		case StringType:
+			attr = slog.String(field.Key, field.String)
+		case ErrorType:
+			attr = slog.String(field.Key, field.Interface.(error).Error()) //nolint:forcetypeassert
+		case TimeType, DurationType:
+			attr = slog.Any(field.Key, field.Interface)
+		case HexType:
+			attr = slog.String(field.Key, fmt.Sprintf("%x", field.Interface))
+		case ErrorsType:
+			attr = slog.Any(field.Key, errorArray(field.Interface.([]error))) //nolint:forcetypeassert
+		case NamespaceType:
+			rest := SlogAttrs(fields[i+1:]...)
+			return append(attrs, slog.Attr{Key: field.Key, Value: slog.GroupValue(rest...)})
+		case MessageType:
+			continue
+		default: // pointers, slices, objects and arrays are passed to zap as is
+			attr = slog.Any(field.Key, field.Interface)
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs
+}
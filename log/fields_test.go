@@ -0,0 +1,140 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type point struct {
+	X, Y int
+}
+
+func (p point) MarshalLogObject(enc ObjectEncoder) error {
+	enc.AddInt("x", p.X)
+	enc.AddInt("y", p.Y)
+	return nil
+}
+
+type points []point
+
+func (ps points) MarshalLogArray(enc ArrayEncoder) error {
+	for _, p := range ps {
+		if err := enc.AppendObject(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileLogger returns a logger writing to a temp file and a function reading the decoded records
+func fileLogger(t *testing.T, cfg Config) (MetaLogger, func() []map[string]any) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.log")
+	cfg.Sinks = []SinkConfig{{Type: SinkFile, Path: path}}
+	logger, err := NewWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger, func() []map[string]any {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, record)
+		}
+		return records
+	}
+}
+
+func TestFieldTypes(t *testing.T) {
+	f, s := 2.5, "pointer"
+	at := time.Date(2026, 5, 4, 3, 2, 1, 0, time.UTC)
+	base := errors.New("disk full")
+	wrapped := fmt.Errorf("save: %w", base)
+
+	tests := []struct {
+		field Field
+		want  any
+	}{
+		{Bool("bool", true), true},
+		{Int("int", -7), float64(-7)},
+		{Int64("int64", 1<<40), float64(1 << 40)},
+		{Uint64("uint64", 1<<63+1), float64(1<<63 + 1)},
+		{Float64("float64", 3.25), 3.25},
+		{Float64p("float64p", &f), 2.5},
+		{String("string", "text"), "text"},
+		{Strings("strings", []string{"a", "b"}), []any{"a", "b"}},
+		{Stringp("stringp", &s), "pointer"},
+		{Error(errors.New("boom")), "boom"},
+		{Time("time", at), "2026-05-04T03:02:01.000Z"},
+		{Duration("duration", 1500*time.Millisecond), 1.5},
+		{Any("any", map[string]int{"k": 1}), map[string]any{"k": float64(1)}},
+		{Bytes("bytes", []byte("hi")), "aGk="},
+		{Hex("hex", []byte{0xde, 0xad}), "dead"},
+		{Object("object", point{1, 2}), map[string]any{"x": float64(1), "y": float64(2)}},
+		{Array("array", points{{1, 2}}), []any{map[string]any{"x": float64(1), "y": float64(2)}}},
+		{Errors("errors", wrapped, errors.Join(base, errors.New("no space"))), []any{
+			map[string]any{"msg": "save: disk full", "causes": []any{"disk full"}},
+			map[string]any{"msg": "disk full\nno space", "errors": []any{
+				map[string]any{"msg": "disk full"},
+				map[string]any{"msg": "no space"},
+			}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field.Key, func(t *testing.T) {
+			a := assert.New(t)
+			logger, records := fileLogger(t, Config{Level: "info"})
+			logger.Info("zap", tt.field)
+			slog.New(logger.SlogHandler()).LogAttrs(context.Background(), slog.LevelInfo, "slog", SlogAttrs(tt.field)...)
+
+			result := records()
+			a.Len(result, 2)
+			for _, record := range result {
+				a.Equal(tt.want, record[tt.field.Key], record["msg"])
+			}
+		})
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	a := assert.New(t)
+
+	logger, records := fileLogger(t, Config{})
+	fields := []Field{String("outer", "o"), Namespace("request"), String("id", "r1"), Int("size", 3)}
+	logger.Info("zap", fields...)
+	slog.New(logger.SlogHandler()).LogAttrs(context.Background(), slog.LevelInfo, "slog", SlogAttrs(fields...)...)
+
+	for _, record := range records() {
+		a.Equal("o", record["outer"])
+		a.Equal(map[string]any{"id": "r1", "size": float64(3)}, record["request"])
+	}
+}
+
+func TestDPanic(t *testing.T) {
+	a := assert.New(t)
+
+	logger, records := fileLogger(t, Config{})
+	logger.DPanic("should not panic in production", Int("n", 1))
+
+	result := records()
+	a.Len(result, 1)
+	a.Equal("dpanic", result[0]["level"])
+}
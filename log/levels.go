@@ -0,0 +1,275 @@
+package log
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var errNoDuration = errors.New("escalation duration must be positive")
+
+// Levels holds runtime log levels of the root logger and named sub-loggers (modules).
+// A module without its own level inherits the level of its parent ("a.b" -> "a" -> root).
+// Effective levels of the modules are recalculated on changes, so loggers check them without locking.
+type Levels struct {
+	mu          sync.Mutex
+	root        zapcore.Level
+	modules     map[string]zapcore.Level
+	escalations map[string]escalation
+	effective   map[string]zap.AtomicLevel // effective levels of the modules having loggers
+	expiry      *time.Timer                // fires at the nearest escalation expiry
+	now         func() time.Time
+}
+
+type escalation struct {
+	level zapcore.Level
+	until time.Time
+}
+
+// LevelState describes the level of a module
+type LevelState struct {
+	Level     string     `json:"level"`
+	Escalated string     `json:"escalated,omitempty"` // temporary level
+	Until     *time.Time `json:"until,omitempty"`     // escalation expiry
+}
+
+// LevelsState is a snapshot of runtime levels
+type LevelsState struct {
+	LevelState
+	Modules map[string]LevelState `json:"modules,omitempty"`
+}
+
+func newLevels(root zapcore.Level) *Levels {
+	return &Levels{
+		root:        root,
+		modules:     make(map[string]zapcore.Level),
+		escalations: make(map[string]escalation),
+		effective:   make(map[string]zap.AtomicLevel),
+		now:         time.Now,
+	}
+}
+
+// LevelsOf returns runtime levels of the logger or nil if the logger does not support them
+func LevelsOf(l MetaLogger) *Levels {
+	if lg, ok := unwrap(l); ok {
+		return lg.levels
+	}
+	return nil
+}
+
+// Named returns a sub-logger of the module (e.g. "dbase", "httpserver", a service name) having its own runtime level.
+// Nested names are joined with a dot.
+func Named(l MetaLogger, name string) MetaLogger {
+	lg, ok := unwrap(l)
+	if !ok || lg.levels == nil {
+		return l.With(String("logger", name))
+	}
+	module := name
+	if lg.module != "" {
+		module = lg.module + "." + name
+	}
+	external := lg.externalLogger.Named(name).WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if f, ok := core.(*filterCore); ok {
+			return &filterCore{Core: f.Core, level: lg.levels.levelOf(module)}
+		}
+		return core
+	}))
+	return &logger{externalLogger: external, oneline: lg.oneline, level: zeroLevel, levels: lg.levels, module: module, dedup: lg.dedup}
+}
+
+// unwrap returns the logger implementation, also of a wrapper providing Unwrap (e.g. logtest.Logger)
+func unwrap(l MetaLogger) (*logger, bool) {
+	if w, ok := l.(interface{ Unwrap() MetaLogger }); ok {
+		l = w.Unwrap()
+	}
+	lg, ok := l.(*logger)
+	return lg, ok
+}
+
+// SetLevel sets the level of the module (root logger if module is empty).
+// Empty level removes the module's own level so that it inherits the parent's one.
+func (l *Levels) SetLevel(module, level string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level == "" {
+		if module != "" {
+			delete(l.modules, module)
+			l.update()
+		}
+		return nil
+	}
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	if module == "" {
+		l.root = lvl
+	} else {
+		l.modules[module] = lvl
+	}
+	l.update()
+	return nil
+}
+
+// Escalate temporarily sets the level of the module (root logger if module is empty) for the duration.
+// The escalated level applies only if it is lower (more verbose) than the module level.
+func (l *Levels) Escalate(module, level string, duration time.Duration) error {
+	if duration <= 0 {
+		return errNoDuration
+	}
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.escalations[module] = escalation{level: lvl, until: l.now().Add(duration)}
+	l.update()
+	return nil
+}
+
+// Level returns the effective level of the module
+func (l *Levels) Level(module string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.level(module).String()
+}
+
+// State returns a snapshot of configured levels and active escalations
+func (l *Levels) State() LevelsState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.update()
+	state := func(module string, level zapcore.Level) LevelState {
+		s := LevelState{Level: level.String()}
+		if e, found := l.escalations[module]; found {
+			until := e.until
+			s.Escalated, s.Until = e.level.String(), &until
+		}
+		return s
+	}
+	result := LevelsState{LevelState: state("", l.root), Modules: make(map[string]LevelState)}
+	for module, level := range l.modules {
+		result.Modules[module] = state(module, level)
+	}
+	for module := range l.escalations {
+		if _, found := result.Modules[module]; !found && module != "" {
+			result.Modules[module] = state(module, l.moduleLevel(module))
+		}
+	}
+	return result
+}
+
+// levelOf returns the effective level of the module kept up to date by update
+func (l *Levels) levelOf(module string) zap.AtomicLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	level, found := l.effective[module]
+	if !found {
+		level = zap.NewAtomicLevelAt(l.level(module))
+		l.effective[module] = level
+	}
+	return level
+}
+
+// update drops expired escalations, recalculates effective levels and schedules the next expiry.
+// The caller holds the lock.
+func (l *Levels) update() {
+	now := l.now()
+	var next time.Time
+	for module, e := range l.escalations {
+		switch {
+		case !now.Before(e.until):
+			delete(l.escalations, module)
+		case next.IsZero() || e.until.Before(next):
+			next = e.until
+		}
+	}
+	for module, level := range l.effective {
+		level.SetLevel(l.level(module))
+	}
+	if l.expiry != nil {
+		l.expiry.Stop()
+		l.expiry = nil
+	}
+	if !next.IsZero() {
+		l.expiry = time.AfterFunc(next.Sub(now), l.expire)
+	}
+}
+
+// expire is called by the timer at escalation expiry
+func (l *Levels) expire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.update()
+}
+
+// level returns the effective level: the most specific configured level lowered by the most specific escalation.
+// The caller holds the lock.
+func (l *Levels) level(module string) zapcore.Level {
+	level := l.moduleLevel(module)
+	for name := module; ; name = parentModule(name) {
+		if e, found := l.escalations[name]; found {
+			return min(level, e.level)
+		}
+		if name == "" {
+			return level
+		}
+	}
+}
+
+// moduleLevel returns the most specific configured level
+func (l *Levels) moduleLevel(module string) zapcore.Level {
+	for name := module; name != ""; name = parentModule(name) {
+		if level, found := l.modules[name]; found {
+			return level
+		}
+	}
+	return l.root
+}
+
+func parentModule(module string) string {
+	if i := strings.LastIndexByte(module, '.'); i >= 0 {
+		return module[:i]
+	}
+	return ""
+}
+
+// filterCore applies runtime level of the module
+type filterCore struct {
+	zapcore.Core
+	level zap.AtomicLevel // effective level of the module (see Levels.levelOf)
+}
+
+func (c *filterCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level) && c.Core.Enabled(level)
+}
+
+func (c *filterCore) With(fields []zapcore.Field) zapcore.Core {
+	return &filterCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *filterCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(entry.Level) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
+
+// slogLeveler reports the runtime level of the module to slog handler
+type slogLeveler struct {
+	level zap.AtomicLevel
+}
+
+func (s slogLeveler) Level() slog.Level {
+	level := s.level.Level()
+	if l, found := logLevels[level]; found {
+		return l
+	}
+	return slog.LevelError + 1 // dpanic and above
+}
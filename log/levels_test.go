@@ -0,0 +1,75 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestRuntimeLevels(t *testing.T) {
+	a := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	logger, err := NewWithConfig(Config{
+		Level:   "info",
+		Modules: map[string]string{"dbase": "warn"},
+		Sinks:   []SinkConfig{{Type: SinkFile, Path: path}},
+	})
+	a.NoError(err)
+	levels := LevelsOf(logger)
+	a.NotNil(levels)
+	db := Named(logger, "dbase")
+	pool := Named(db, "pool")
+	service := Named(logger, "orders").With(String("k", "v"))
+
+	logger.Debug("root debug")   // off
+	db.Info("db info")           // off: dbase is warn
+	pool.Warn("pool warn")       // inherits dbase level
+	service.Info("service info") // inherits root level
+	a.NoError(levels.SetLevel("dbase.pool", "debug"))
+	pool.Debug("pool debug")
+	a.NoError(levels.SetLevel("", "error"))
+	service.Warn("service warn") // off: root is error
+
+	now := time.Now()
+	levels.now = func() time.Time { return now }
+	a.NoError(levels.Escalate("orders", "debug", time.Minute))
+	service.Debug("escalated debug")
+	a.Equal("debug", levels.State().Modules["orders"].Escalated)
+	now = now.Add(2 * time.Minute)
+	levels.expire()                // the expiry timer fires
+	service.Debug("expired debug") // off: escalation expired
+	a.Empty(levels.State().Modules["orders"].Escalated)
+
+	a.Error(levels.SetLevel("dbase", "loud"))
+	a.Error(levels.Escalate("", "debug", 0))
+
+	data, err := os.ReadFile(path)
+	a.NoError(err)
+	out := string(data)
+	for _, msg := range []string{"pool warn", "service info", "pool debug", "escalated debug"} {
+		a.Contains(out, `"msg":"`+msg+`"`)
+	}
+	for _, msg := range []string{"root debug", "db info", "service warn", "expired debug"} {
+		a.NotContains(out, `"msg":"`+msg+`"`)
+	}
+	a.Contains(out, `"logger":"dbase.pool"`)
+	a.Len(strings.Split(strings.TrimSpace(out), "\n"), 4)
+}
+
+func TestEscalationExpiry(t *testing.T) {
+	a := assert.New(t)
+
+	levels := newLevels(zap.InfoLevel)
+	orders := levels.levelOf("orders")
+	a.False(orders.Enabled(zap.DebugLevel))
+	a.NoError(levels.Escalate("orders", "debug", 20*time.Millisecond))
+	a.True(orders.Enabled(zap.DebugLevel))
+	a.Eventually(func() bool { return !orders.Enabled(zap.DebugLevel) }, time.Second, 5*time.Millisecond, "timer restores the level")
+	a.Empty(levels.State().Modules)
+}
@@ -2,7 +2,7 @@ package log
 
 import (
 	"context"
-	"fmt"
+	"encoding/hex"
 	"log/slog"
 	"time"
 
@@ -30,6 +30,13 @@ const (
 	DurationType
 	AnyType
 	MessageType
+	Uint64Type
+	BytesType
+	HexType
+	ObjectType
+	ArrayType
+	NamespaceType
+	ErrorsType
 )
 
 const (
@@ -72,6 +79,9 @@ type logger struct {
 	level          int
 	message        string
 	fields         []Field
+	levels         *Levels       // runtime levels (nil for no-op logger)
+	module         string        // sub-logger name (see Named)
+	dedup          *deduplicator // nil if deduplication is off
 }
 
 // NewNop returns no-op logger
@@ -79,46 +89,24 @@ func NewNop() MetaLogger {
 	return &logger{externalLogger: zap.NewNop()}
 }
 
-// New returns new logger. 'level' defines required logging level.
-func New(level string, oneline bool) (MetaLogger, error) {
-	// check level values
-	zapLevels := map[string]zapcore.Level{
-		"debug":  zap.DebugLevel,
-		"info":   zap.InfoLevel,
-		"warn":   zap.WarnLevel,
-		"error":  zap.ErrorLevel,
-		"dpanic": zap.DPanicLevel,
-		"panic":  zap.PanicLevel,
-		"fatal":  zap.FatalLevel,
-	}
-
-	config := zap.NewProductionConfig()
-	if level == "debug" {
-		config.DisableCaller = false
-	} else {
-		config.Sampling = nil
-		config.DisableCaller = true
-	}
-
-	config.Level.SetLevel(zapLevels[level])
-	config.Encoding = "json"
-	config.EncoderConfig.TimeKey = "time"
-	config.EncoderConfig.EncodeTime = timeEncoder
-	config.DisableStacktrace = true
+// NewWithCore returns a logger writing to the zap core (e.g. zaptest/observer in tests) with runtime levels starting at debug.
+func NewWithCore(core zapcore.Core) MetaLogger {
+	levels := newLevels(zap.DebugLevel)
+	external := zap.New(&filterCore{Core: core, level: levels.levelOf("")})
+	return &logger{externalLogger: external, level: zeroLevel, levels: levels}
+}
 
-	l, err := config.Build()
-	if err != nil {
-		return nil, fmt.Errorf("construct logger from config: %w", err)
-	}
-	return &logger{externalLogger: l, oneline: oneline, level: zeroLevel}, nil
+// New returns new logger writing JSON to stderr. 'level' defines required logging level.
+func New(level string, oneline bool) (MetaLogger, error) {
+	return NewWithConfig(Config{Level: level, Oneline: oneline})
 }
 
 // SetContextLogger puts a meta logger into the context.
 func (l *logger) SetContextLogger(ctx context.Context) context.Context {
-	return context.WithValue(ctx, ContextMetaLogger, l)
+	return WithContext(ctx, l)
 }
 
-// GetContextLogger returns a meta logger extracted from context.
+// GetContextLogger returns a meta logger extracted from context. It panics if there is none (see FromContext).
 func GetContextLogger(ctx context.Context) MetaLogger {
 	loggerReference := ctx.Value(ContextMetaLogger)
 	ref, ok := loggerReference.(MetaLogger)
@@ -151,7 +139,7 @@ func Int64(key string, val int64) Field {
 }
 
 func Float64(key string, val float64) Field {
-	return Field{Key: key, Type: IntType, Float64: val}
+	return Field{Key: key, Type: Float64Type, Float64: val}
 }
 
 func Float64p(key string, val *float64) Field {
@@ -220,6 +208,20 @@ func convert(fields []Field) []zap.Field {
 			zapfields = append(zapfields, zap.String(field.Key, field.Interface.(error).Error())) //nolint:forcetypeassert
 		case AnyType:
 			zapfields = append(zapfields, zap.Any(field.Key, field.Interface))
+		case Uint64Type:
+			zapfields = append(zapfields, zap.Uint64(field.Key, uint64(field.Integer))) //nolint:gosec
+		case BytesType:
+			zapfields = append(zapfields, zap.Binary(field.Key, field.Interface.([]byte))) //nolint:forcetypeassert
+		case HexType:
+			zapfields = append(zapfields, zap.String(field.Key, hex.EncodeToString(field.Interface.([]byte)))) //nolint:forcetypeassert
+		case ObjectType:
+			zapfields = append(zapfields, zap.Object(field.Key, field.Interface.(zapcore.ObjectMarshaler))) //nolint:forcetypeassert
+		case ArrayType:
+			zapfields = append(zapfields, zap.Array(field.Key, field.Interface.(zapcore.ArrayMarshaler))) //nolint:forcetypeassert
+		case NamespaceType:
+			zapfields = append(zapfields, zap.Namespace(field.Key))
+		case ErrorsType:
+			zapfields = append(zapfields, zap.Array(field.Key, errorArray(field.Interface.([]error)))) //nolint:forcetypeassert
 		}
 	}
 	return zapfields
@@ -270,6 +272,9 @@ func (l *logger) With(fields ...Field) MetaLogger {
 		externalLogger: l.externalLogger.With(zapfields...),
 		oneline:        l.oneline,
 		level:          l.level,
+		levels:         l.levels,
+		module:         l.module,
+		dedup:          l.dedup,
 	}
 }
 
@@ -296,6 +301,9 @@ func (l *logger) Verbose() MetaLogger {
 		externalLogger: l.externalLogger,
 		oneline:        false,
 		level:          zeroLevel,
+		levels:         l.levels,
+		module:         l.module,
+		dedup:          l.dedup,
 	}
 }
 
@@ -331,6 +339,8 @@ func (l *logger) directLog(level int, msg string, fields ...zap.Field) {
 		l.externalLogger.Warn(msg, fields...)
 	case int(zap.ErrorLevel):
 		l.externalLogger.Error(msg, fields...)
+	case int(zap.DPanicLevel):
+		l.externalLogger.DPanic(msg, fields...)
 	case int(zap.PanicLevel):
 		l.externalLogger.Panic(msg, fields...)
 	case int(zap.FatalLevel):
@@ -346,5 +356,9 @@ var logLevels = map[zapcore.Level]slog.Level{ //nolint:gochecknoglobals
 }
 
 func (l *logger) SlogHandler() slog.Handler {
-	return slogzap.Option{Level: logLevels[l.externalLogger.Level()], Logger: l.externalLogger}.NewZapHandler()
+	var level slog.Leveler = logLevels[l.externalLogger.Level()]
+	if l.levels != nil {
+		level = slogLeveler{level: l.levels.levelOf(l.module)}
+	}
+	return slogzap.Option{Level: level, Logger: l.externalLogger}.NewZapHandler()
 }
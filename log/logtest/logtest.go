@@ -0,0 +1,135 @@
+// Package logtest provides a MetaLogger recording log entries in memory for assertions in tests.
+package logtest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/bhmj/goblocks/log"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestingT is the subset of testing.TB used by assertions
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Entry is a recorded log entry
+type Entry struct {
+	Level   string         // debug, info, warn, error, dpanic, panic, fatal
+	Message string         //
+	Logger  string         // module name (see log.Named)
+	Fields  map[string]any // entry fields and context fields added by With, as encoded by zap (ints are int64, etc.)
+}
+
+// Logger is a MetaLogger recording all entries (debug level and above) including those of its clones (With, Named, SlogHandler)
+type Logger struct {
+	log.MetaLogger
+	observed *observer.ObservedLogs
+}
+
+// New returns a recording logger
+func New() *Logger {
+	core, observed := observer.New(zapcore.DebugLevel)
+	return &Logger{MetaLogger: log.NewWithCore(core), observed: observed}
+}
+
+// Unwrap returns the underlying logger (used by log.Named and log.LevelsOf)
+func (l *Logger) Unwrap() log.MetaLogger {
+	return l.MetaLogger
+}
+
+// Entries returns all recorded entries
+func (l *Logger) Entries() []Entry {
+	return convert(l.observed.All())
+}
+
+// Reset removes recorded entries
+func (l *Logger) Reset() {
+	l.observed.TakeAll()
+}
+
+// Filter returns entries of the level with the message and all the fields (empty level or message matches any)
+func (l *Logger) Filter(level, msg string, fields ...log.Field) []Entry {
+	want := encode(fields)
+	var result []Entry
+	for _, entry := range l.Entries() {
+		if entry.matches(level, msg, want) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// FilterField returns entries having all the fields
+func (l *Logger) FilterField(fields ...log.Field) []Entry {
+	return l.Filter("", "", fields...)
+}
+
+// AssertLogged checks that an entry of the level with the message and the fields was logged (empty level or message matches any)
+func (l *Logger) AssertLogged(t TestingT, level, msg string, fields ...log.Field) bool {
+	t.Helper()
+	if len(l.Filter(level, msg, fields...)) > 0 {
+		return true
+	}
+	t.Errorf("log entry not found: %s\nlogged:\n%s", describe(level, msg, encode(fields)), l.dump())
+	return false
+}
+
+// AssertNotLogged checks that no entry of the level with the message and the fields was logged
+func (l *Logger) AssertNotLogged(t TestingT, level, msg string, fields ...log.Field) bool {
+	t.Helper()
+	found := l.Filter(level, msg, fields...)
+	if len(found) == 0 {
+		return true
+	}
+	t.Errorf("unexpected log entry: %s", describe(found[0].Level, found[0].Message, found[0].Fields))
+	return false
+}
+
+func (e Entry) matches(level, msg string, fields map[string]any) bool {
+	if level != "" && e.Level != level || msg != "" && e.Message != msg {
+		return false
+	}
+	for key, value := range fields {
+		if actual, found := e.Fields[key]; !found || !reflect.DeepEqual(actual, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *Logger) dump() string {
+	var sb strings.Builder
+	for _, entry := range l.Entries() {
+		sb.WriteString("  " + describe(entry.Level, entry.Message, entry.Fields) + "\n")
+	}
+	return sb.String()
+}
+
+func describe(level, msg string, fields map[string]any) string {
+	return fmt.Sprintf("[%s] %q %v", level, msg, fields)
+}
+
+func convert(logged []observer.LoggedEntry) []Entry {
+	entries := make([]Entry, len(logged))
+	for i, entry := range logged {
+		entries[i] = Entry{
+			Level:   entry.Level.String(),
+			Message: entry.Message,
+			Logger:  entry.LoggerName,
+			Fields:  entry.ContextMap(),
+		}
+	}
+	return entries
+}
+
+// encode returns field values as recorded in Entry.Fields
+func encode(fields []log.Field) map[string]any {
+	core, observed := observer.New(zapcore.DebugLevel)
+	log.NewWithCore(core).Info("", fields...)
+	return observed.All()[0].ContextMap()
+}
@@ -0,0 +1,56 @@
+package logtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/bhmj/goblocks/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder collects assertion failures
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestLogger(t *testing.T) {
+	a := assert.New(t)
+
+	logger := New()
+	logger.Debug("starting")
+	request := logger.With(log.String("rid", "r1"))
+	request.Info("request", log.Int("status", 200), log.Strings("tags", []string{"a", "b"}))
+	log.Named(request, "db").Error("query failed", log.Error(errors.New("timeout")))
+	slog.New(logger.SlogHandler()).WarnContext(context.Background(), "slow", "ms", 1500)
+
+	entries := logger.Entries()
+	a.Len(entries, 4)
+	a.Equal(Entry{Level: "debug", Message: "starting", Fields: map[string]any{}}, entries[0])
+	a.Equal(map[string]any{"rid": "r1", "status": int64(200), "tags": []any{"a", "b"}}, entries[1].Fields)
+	a.Equal("db", entries[2].Logger)
+
+	a.True(logger.AssertLogged(t, "info", "request", log.Int("status", 200)))
+	a.True(logger.AssertLogged(t, "", "", log.String("rid", "r1"), log.String("error", "timeout")))
+	a.True(logger.AssertLogged(t, "warn", "slow"))
+	a.True(logger.AssertNotLogged(t, "error", "request"))
+	a.Len(logger.FilterField(log.String("rid", "r1")), 2)
+	a.Len(logger.Filter("error", ""), 1)
+
+	r := &recorder{}
+	a.False(logger.AssertLogged(r, "info", "request", log.Int("status", 500)))
+	a.False(logger.AssertNotLogged(r, "", "starting"))
+	a.Len(r.errors, 2)
+	a.Contains(r.errors[0], `[info] "request"`)
+
+	logger.Reset()
+	a.Empty(logger.Entries())
+}
@@ -0,0 +1,141 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	networkDialTimeout  = 3 * time.Second
+	networkWriteTimeout = time.Second
+	networkQueueSize    = 1024
+	networkMinBackoff   = 100 * time.Millisecond
+	networkMaxBackoff   = 10 * time.Second
+)
+
+// networkWriter sends log records to a TCP, UDP or unix socket, reconnecting on failure.
+// Records are queued and sent by a background goroutine; they are dropped while the queue is full
+// or the receiver is unavailable so that logging never blocks the service.
+type networkWriter struct {
+	network, address string
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan []byte
+	done   chan struct{}
+
+	// owned by the sender goroutine
+	conn     net.Conn
+	backoff  time.Duration
+	nextDial time.Time
+}
+
+// splitAddress splits scheme://address (tcp if scheme is omitted)
+func splitAddress(address string) (string, string) {
+	if network, addr, found := strings.Cut(address, "://"); found {
+		return network, addr
+	}
+	return "tcp", address
+}
+
+func newNetworkWriter(address string) (*networkWriter, error) {
+	w := &networkWriter{
+		queue: make(chan []byte, networkQueueSize),
+		done:  make(chan struct{}),
+	}
+	w.network, w.address = splitAddress(address)
+	switch w.network {
+	case "tcp", "udp", "unix":
+	default:
+		return nil, fmt.Errorf("%w: unsupported network %s", errUnknownSink, w.network)
+	}
+	go w.run()
+	return w, nil
+}
+
+// run sends queued records until the writer is closed
+func (w *networkWriter) run() {
+	defer close(w.done)
+	for p := range w.queue {
+		w.send(p)
+	}
+	if w.conn != nil {
+		w.conn.Close()
+	}
+}
+
+// send writes the record reconnecting once, or drops it if the receiver is unavailable
+func (w *networkWriter) send(p []byte) {
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil && !w.connect() {
+			return
+		}
+		_ = w.conn.SetWriteDeadline(time.Now().Add(networkWriteTimeout))
+		_, err := w.conn.Write(p)
+		if err == nil {
+			w.backoff = 0
+			return
+		}
+		w.conn.Close()
+		w.conn = nil
+		if ne, ok := err.(net.Error); ok && ne.Timeout() { //nolint:errorlint
+			w.fail() // stalled receiver
+			return
+		}
+	}
+}
+
+// connect dials the receiver unless the previous attempt failed recently
+func (w *networkWriter) connect() bool {
+	if time.Now().Before(w.nextDial) {
+		return false
+	}
+	conn, err := net.DialTimeout(w.network, w.address, networkDialTimeout)
+	if err != nil {
+		w.fail()
+		return false
+	}
+	w.conn = conn
+	return true
+}
+
+// fail postpones the next connection attempt with exponential backoff
+func (w *networkWriter) fail() {
+	w.backoff = min(max(2*w.backoff, networkMinBackoff), networkMaxBackoff) //nolint:mnd
+	w.nextDial = time.Now().Add(w.backoff)
+}
+
+func (w *networkWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return len(p), nil // dropped
+	}
+	select {
+	case w.queue <- append([]byte(nil), p...): // p is reused by the encoder
+	default: // dropped
+	}
+	return len(p), nil
+}
+
+func (w *networkWriter) Sync() error {
+	return nil
+}
+
+// Close sends the queued records and closes the connection, waiting for a stalled receiver no longer than a single write
+func (w *networkWriter) Close() error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+	case <-time.After(networkDialTimeout + networkWriteTimeout):
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultMask     = "***"
+	maxRedactDepth  = 10
+	redactTag       = "redact"
+	detectorEmail   = "email"
+	detectorCard    = "card"
+	detectorJWT     = "jwt"
+	cardDigitsMin   = 13
+	luhnDoubleLimit = 9
+)
+
+var errUnknownDetector = errors.New("unknown redact detector")
+
+// default key name patterns of sensitive values
+var defaultRedactKeys = []string{ //nolint:gochecknoglobals
+	"*password*", "*passwd*", "*secret*", "*token*", "*apikey*", "*api?key*", "authorization", "cookie", "set-cookie", "sid",
+}
+
+var detectors = map[string]*regexp.Regexp{ //nolint:gochecknoglobals
+	detectorEmail: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	detectorCard:  regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+	detectorJWT:   regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+}
+
+// RedactConfig defines masking of sensitive values in log records
+type RedactConfig struct {
+	Enabled bool     `yaml:"enabled" description:"Mask sensitive values in log records"`
+	Keys    []string `yaml:"keys" description:"Case-insensitive key name globs whose values are masked, also in key=value text (password, token, secret, authorization, cookie, sid, ... if empty)"`
+	Values  []string `yaml:"values" description:"Regular expressions of sensitive values"`
+	Detect  string   `yaml:"detect" description:"Built-in value detectors, comma separated" default:"email,card,jwt"`
+	Mask    string   `yaml:"mask" description:"Replacement of masked values" default:"***"`
+}
+
+// redactor masks sensitive values by key names, value patterns and `log:"redact"` struct tags
+type redactor struct {
+	keys   []string
+	pairs  *regexp.Regexp // key=value in text
+	values []*regexp.Regexp
+	card   bool
+	mask   string
+}
+
+func newRedactor(cfg RedactConfig) (*redactor, error) {
+	r := &redactor{mask: cfg.Mask}
+	if r.mask == "" {
+		r.mask = defaultMask
+	}
+	keys := cfg.Keys
+	if len(keys) == 0 {
+		keys = defaultRedactKeys
+	}
+	alternatives := make([]string, 0, len(keys))
+	for _, key := range keys {
+		key = strings.ToLower(key)
+		if _, err := path.Match(key, ""); err != nil {
+			return nil, fmt.Errorf("redact key %q: %w", key, err)
+		}
+		r.keys = append(r.keys, key)
+		glob := regexp.QuoteMeta(key)
+		glob = strings.ReplaceAll(glob, `\*`, `[\w-]*`)
+		glob = strings.ReplaceAll(glob, `\?`, `[\w-]`)
+		alternatives = append(alternatives, glob)
+	}
+	r.pairs = regexp.MustCompile(`(?i)\b((?:` + strings.Join(alternatives, "|") + `)=)[^&\s"',;]+`)
+	for _, name := range strings.Split(cfg.Detect, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "", "none":
+		case detectorCard:
+			r.card = true
+		default:
+			re, found := detectors[name]
+			if !found {
+				return nil, fmt.Errorf("%w: %s", errUnknownDetector, name)
+			}
+			r.values = append(r.values, re)
+		}
+	}
+	for _, expr := range cfg.Values {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("redact value %q: %w", expr, err)
+		}
+		r.values = append(r.values, re)
+	}
+	return r, nil
+}
+
+// key reports whether the values of the key are to be masked
+func (r *redactor) key(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range r.keys {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// string masks key=value pairs and sensitive values in text
+func (r *redactor) string(s string) string {
+	s = r.pairs.ReplaceAllString(s, "${1}"+r.mask)
+	for _, re := range r.values {
+		s = re.ReplaceAllString(s, r.mask)
+	}
+	if r.card {
+		s = detectors[detectorCard].ReplaceAllStringFunc(s, func(number string) string {
+			if luhn(number) {
+				return r.mask
+			}
+			return number
+		})
+	}
+	return s
+}
+
+// luhn validates card number checksum
+func luhn(number string) bool {
+	sum, digits := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > luhnDoubleLimit {
+				d -= luhnDoubleLimit
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= cardDigitsMin && sum%10 == 0
+}
+
+// fields returns fields with sensitive values masked
+func (r *redactor) fields(fields []zapcore.Field) []zapcore.Field {
+	result := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		result[i] = r.field(field)
+	}
+	return result
+}
+
+func (r *redactor) field(field zapcore.Field) zapcore.Field {
+	switch field.Type { //nolint:exhaustive
+	case zapcore.NamespaceType, zapcore.SkipType:
+		return field
+	}
+	if r.key(field.Key) {
+		return zap.String(field.Key, r.mask)
+	}
+	switch field.Type { //nolint:exhaustive
+	case zapcore.StringType:
+		return zap.String(field.Key, r.string(field.String))
+	case zapcore.ByteStringType:
+		return zap.String(field.Key, r.string(string(field.Interface.([]byte)))) //nolint:forcetypeassert
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok && err != nil {
+			return zap.String(field.Key, r.string(err.Error()))
+		}
+	case zapcore.StringerType:
+		if s, ok := field.Interface.(fmt.Stringer); ok && s != nil {
+			return zap.String(field.Key, r.string(s.String()))
+		}
+	case zapcore.ReflectType:
+		return zap.Any(field.Key, r.value(reflect.ValueOf(field.Interface), 0))
+	case zapcore.ObjectMarshalerType, zapcore.InlineMarshalerType, zapcore.ArrayMarshalerType:
+		// encode into a map to inspect the content
+		enc := zapcore.NewMapObjectEncoder()
+		field.AddTo(enc)
+		if field.Type == zapcore.InlineMarshalerType {
+			return zap.Inline(redactedObject{r.value(reflect.ValueOf(enc.Fields), 0)})
+		}
+		return zap.Any(field.Key, r.value(reflect.ValueOf(enc.Fields[field.Key]), 0))
+	}
+	return field
+}
+
+// value returns a copy of the value with sensitive parts masked (structs become maps keyed as in JSON)
+func (r *redactor) value(v reflect.Value, depth int) any {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	if depth > maxRedactDepth {
+		return v.Interface()
+	}
+	switch v.Kind() { //nolint:exhaustive
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return r.value(v.Elem(), depth+1)
+	case reflect.String:
+		return r.string(v.String())
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		result := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			if r.key(key) {
+				result[key] = r.mask
+			} else {
+				result[key] = r.value(iter.Value(), depth+1)
+			}
+		}
+		return result
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		result := make([]any, v.Len())
+		for i := range result {
+			result[i] = r.value(v.Index(i), depth+1)
+		}
+		return result
+	case reflect.Struct:
+		if _, ok := v.Interface().(json.Marshaler); ok {
+			return v.Interface()
+		}
+		result := make(map[string]any, v.NumField())
+		r.structFields(v, result, depth)
+		return result
+	}
+	return v.Interface()
+}
+
+// structFields adds exported struct fields named as in JSON to the map
+func (r *redactor) structFields(v reflect.Value, result map[string]any, depth int) {
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		name, opts, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		fv := v.Field(i)
+		if sf.Anonymous && name == "" && fv.Kind() == reflect.Struct {
+			r.structFields(fv, result, depth)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if strings.Contains(opts, "omitempty") && fv.IsZero() {
+			continue
+		}
+		if sf.Tag.Get("log") == redactTag || r.key(name) {
+			result[name] = r.mask
+			continue
+		}
+		result[name] = r.value(fv, depth+1)
+	}
+}
+
+// redactedObject adds map fields of an inline object
+type redactedObject struct {
+	fields any
+}
+
+func (o redactedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if m, ok := o.fields.(map[string]any); ok {
+		for key, value := range m {
+			if err := enc.AddReflected(key, value); err != nil {
+				return err //nolint:wrapcheck
+			}
+		}
+	}
+	return nil
+}
+
+// redactCore masks sensitive values written to the wrapped sink core
+type redactCore struct {
+	zapcore.Core
+	redactor *redactor
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.redactor.fields(fields)), redactor: c.redactor}
+}
+
+func (c *redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = c.redactor.string(entry.Message)
+	return c.Core.Write(entry, c.redactor.fields(fields)) //nolint:wrapcheck
+}
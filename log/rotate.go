@@ -0,0 +1,188 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	backupTimeFormat = "20060102T150405.000"
+	megabyte         = 1 << 20
+)
+
+// rotatingFile is a log file rotated by size and/or time. Rotated files are renamed to <name>-<time><ext>.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	every      time.Duration
+	maxAge     time.Duration
+	maxBackups int
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time // rotation period the file belongs to
+	now    func() time.Time
+}
+
+func newRotatingFile(cfg SinkConfig) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       cfg.Path,
+		maxSize:    int64(cfg.MaxSizeMB) * megabyte,
+		every:      cfg.RotateEvery,
+		maxAge:     cfg.MaxAge,
+		maxBackups: cfg.MaxBackups,
+		now:        time.Now,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil { //nolint:mnd
+		return fmt.Errorf("create log dir: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644) //nolint:mnd,gosec
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	f.file, f.size = file, info.Size()
+	f.period = f.periodOf(info.ModTime())
+	if info.Size() == 0 {
+		f.period = f.periodOf(f.now())
+	}
+	return nil
+}
+
+func (f *rotatingFile) periodOf(t time.Time) time.Time {
+	if f.every <= 0 {
+		return time.Time{}
+	}
+	return t.Truncate(f.every)
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now()
+	if f.size > 0 && ((f.maxSize > 0 && f.size+int64(len(p)) > f.maxSize) || !f.periodOf(now).Equal(f.period)) {
+		if err := f.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err //nolint:wrapcheck
+}
+
+func (f *rotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Sync() //nolint:wrapcheck
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close() //nolint:wrapcheck
+}
+
+// rotate renames the current file, opens a new one and removes expired backups
+func (f *rotatingFile) rotate(now time.Time) error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("close log file: %w", err)
+	}
+	backup := f.backupName(now)
+	if err := os.Rename(f.path, backup); err != nil {
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.period = f.periodOf(now)
+	f.cleanup(now)
+	return nil
+}
+
+// backupName returns a free backup file name: <name>-<time><ext> or <name>-<time>-<n><ext> if rotated twice within a millisecond
+func (f *rotatingFile) backupName(now time.Time) string {
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(f.path, ext) + "-" + now.Format(backupTimeFormat)
+	backup := base + ext
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(backup); os.IsNotExist(err) {
+			return backup
+		}
+		backup = base + "-" + strconv.Itoa(n) + ext
+	}
+}
+
+// backup is a rotated file of this sink
+type backup struct {
+	path string
+	time time.Time
+	seq  int
+}
+
+// backups returns rotated files of this sink, oldest first. Files of other sinks sharing the name prefix
+// (e.g. app-access.log for app.log) are not matched since their suffix is not a backup timestamp.
+func (f *rotatingFile) backups() []backup {
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(f.path, ext) + "-"
+	paths, _ := filepath.Glob(prefix + "*" + ext)
+	var backups []backup
+	for _, path := range paths {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(path, prefix), ext)
+		stamp, seqStr, hasSeq := strings.Cut(stamp, "-")
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		seq := 0
+		if hasSeq {
+			if seq, err = strconv.Atoi(seqStr); err != nil || seq <= 0 {
+				continue
+			}
+		}
+		backups = append(backups, backup{path: path, time: t, seq: seq})
+	}
+	slices.SortFunc(backups, func(a, b backup) int {
+		if c := a.time.Compare(b.time); c != 0 {
+			return c
+		}
+		return a.seq - b.seq
+	})
+	return backups
+}
+
+// cleanup removes backups exceeding maxBackups or older than maxAge
+func (f *rotatingFile) cleanup(now time.Time) {
+	if f.maxBackups <= 0 && f.maxAge <= 0 {
+		return
+	}
+	backups := f.backups()
+	for i, backup := range backups {
+		expired := f.maxBackups > 0 && i < len(backups)-f.maxBackups
+		if !expired && f.maxAge > 0 {
+			if info, err := os.Stat(backup.path); err == nil {
+				expired = now.Sub(info.ModTime()) > f.maxAge
+			}
+		}
+		if expired {
+			_ = os.Remove(backup.path)
+		}
+	}
+}
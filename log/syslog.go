@@ -0,0 +1,97 @@
+//go:build !windows && !plan9
+
+package log
+
+import (
+	"fmt"
+	"log/syslog"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var syslogFacilities = map[string]syslog.Priority{ //nolint:gochecknoglobals
+	"":       syslog.LOG_USER,
+	"user":   syslog.LOG_USER,
+	"daemon": syslog.LOG_DAEMON,
+	"local0": syslog.LOG_LOCAL0,
+	"local1": syslog.LOG_LOCAL1,
+	"local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4,
+	"local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6,
+	"local7": syslog.LOG_LOCAL7,
+}
+
+// syslogCore writes records to syslog with severity matching the record level
+type syslogCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	writer  *syslog.Writer
+}
+
+func newSyslogCore(sink SinkConfig, encoder zapcore.Encoder, level zapcore.LevelEnabler) (zapcore.Core, func() error, error) {
+	facility, found := syslogFacilities[sink.Facility]
+	if !found {
+		return nil, nil, fmt.Errorf("%w: syslog facility %s", errUnknownSink, sink.Facility)
+	}
+	tag := sink.Tag
+	if tag == "" {
+		tag = filepath.Base(os.Args[0])
+	}
+	var network, address string
+	if sink.Address != "" {
+		network, address = splitAddress(sink.Address)
+	}
+	writer, err := syslog.Dial(network, address, facility|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect to syslog: %w", err)
+	}
+	return &syslogCore{LevelEnabler: level, encoder: encoder, writer: writer}, writer.Close, nil
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.encoder = c.encoder.Clone()
+	for _, field := range fields {
+		field.AddTo(clone.encoder)
+	}
+	return &clone
+}
+
+func (c *syslogCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *syslogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return fmt.Errorf("encode log record: %w", err)
+	}
+	defer buf.Free()
+	msg := buf.String()
+	switch entry.Level {
+	case zapcore.DebugLevel:
+		err = c.writer.Debug(msg)
+	case zapcore.InfoLevel:
+		err = c.writer.Info(msg)
+	case zapcore.WarnLevel:
+		err = c.writer.Warning(msg)
+	case zapcore.ErrorLevel:
+		err = c.writer.Err(msg)
+	case zapcore.DPanicLevel, zapcore.PanicLevel:
+		err = c.writer.Crit(msg)
+	default:
+		err = c.writer.Emerg(msg)
+	}
+	return err //nolint:wrapcheck
+}
+
+func (c *syslogCore) Sync() error {
+	return nil
+}
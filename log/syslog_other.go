@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+package log
+
+import (
+	"fmt"
+	"runtime"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func newSyslogCore(SinkConfig, zapcore.Encoder, zapcore.LevelEnabler) (zapcore.Core, func() error, error) {
+	return nil, nil, fmt.Errorf("%w: syslog is not supported on %s", errUnknownSink, runtime.GOOS)
+}
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bhmj/goblocks/log"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	finalPushTimeout = 5 * time.Second
+	maxErrorBody     = 512
+)
+
+var (
+	errNoURL         = errors.New("url is not set")
+	errUnknownPush   = errors.New("unknown push type")
+	errUnknownFlavor = errors.New("unknown statsd flavor")
+	errPushStatus    = errors.New("unexpected push response")
+	errInterval      = errors.New("interval must be positive")
+)
+
+// exporter pushes gathered metrics to a backend
+type exporter interface {
+	name() string
+	interval() time.Duration
+	export(ctx context.Context, families []*dto.MetricFamily) error
+}
+
+func newExporters(cfg Config, start time.Time) ([]exporter, error) {
+	var exporters []exporter
+	if cfg.StatsD.Enabled {
+		e, err := newStatsDExporter(cfg.StatsD)
+		if err != nil {
+			return nil, fmt.Errorf("statsd: %w", err)
+		}
+		exporters = append(exporters, e)
+	}
+	if cfg.Push.Enabled {
+		e, err := newPushExporter(cfg.Push, cfg.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("push: %w", err)
+		}
+		exporters = append(exporters, e)
+	}
+	if cfg.OTLP.Enabled {
+		e, err := newOTLPExporter(cfg.OTLP, cfg.Namespace, start)
+		if err != nil {
+			return nil, fmt.Errorf("otlp: %w", err)
+		}
+		exporters = append(exporters, e)
+	}
+	return exporters, nil
+}
+
+// Run pushes metrics to the configured exporters (statsd, push, otlp) periodically until the context is done,
+// then pushes them once more. Export errors are logged.
+func (r *Registry) Run(ctx context.Context, logger log.MetaLogger) error {
+	var wg sync.WaitGroup
+	for _, e := range r.exporters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(e.interval())
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					final, cancel := context.WithTimeout(context.WithoutCancel(ctx), finalPushTimeout)
+					err := r.export(final, e)
+					cancel()
+					if err != nil {
+						logger.Error("metrics export", log.String("exporter", e.name()), log.Error(err))
+					}
+					return
+				case <-ticker.C:
+					if err := r.export(ctx, e); err != nil {
+						logger.Error("metrics export", log.String("exporter", e.name()), log.Error(err))
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// Push pushes metrics to the configured exporters once (e.g. at the end of a short-lived job)
+func (r *Registry) Push(ctx context.Context) error {
+	var errs []error
+	for _, e := range r.exporters {
+		if err := r.export(ctx, e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Registry) export(ctx context.Context, e exporter) error {
+	families, err := r.registry.Gather()
+	if err != nil {
+		return fmt.Errorf("gather: %w", err)
+	}
+	return e.export(ctx, families)
+}
+
+// post sends the body to the HTTP endpoint expecting a 2xx response
+func post(ctx context.Context, client *http.Client, url string, body io.Reader, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return fmt.Errorf("request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 { //nolint:mnd
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("%w: %s %s", errPushStatus, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// labelPairs returns metric labels with extra ones appended
+func labelPairs(m *dto.Metric, extra ...string) [][2]string {
+	pairs := make([][2]string, 0, len(m.GetLabel())+len(extra)/2) //nolint:mnd
+	for _, l := range m.GetLabel() {
+		pairs = append(pairs, [2]string{l.GetName(), l.GetValue()})
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, [2]string{extra[i], extra[i+1]})
+	}
+	return pairs
+}
+
+// pushInterval returns the interval or the default if it is not set
+func pushInterval(interval, def time.Duration) (time.Duration, error) {
+	if interval < 0 {
+		return 0, fmt.Errorf("%w: %s", errInterval, interval)
+	}
+	return orDefault(interval, def), nil
+}
+
+// orDefault returns the value or the default if the value is zero
+func orDefault[T comparable](value, def T) T {
+	var zero T
+	if value == zero {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,247 @@
+package metrics
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bhmj/goblocks/log"
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+// testRegistry returns a registry with a counter, a gauge and a histogram having an exemplar
+func testRegistry(t *testing.T, cfg Config) (*Registry, prometheus.Counter) {
+	t.Helper()
+	cfg.Namespace = "test"
+	r, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "jobs_total", Help: "Jobs"}, []string{"queue"})
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "queue_length", Help: "Queue length"})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "job_seconds", Help: "Job duration", Buckets: []float64{0.1, 1}})
+	r.Get().MustRegister(counter, gauge, histogram)
+	counter.WithLabelValues("main").Add(3)
+	gauge.Set(7)
+	histogram.(prometheus.ExemplarObserver).ObserveWithExemplar(0.05, prometheus.Labels{"trace_id": traceID}) //nolint:forcetypeassert
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+	return r, counter.WithLabelValues("main")
+}
+
+func TestOpenMetrics(t *testing.T) {
+	a := assert.New(t)
+
+	r, _ := testRegistry(t, Config{OpenMetrics: true})
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, req)
+	a.Contains(w.Header().Get("Content-Type"), "application/openmetrics-text")
+	a.Contains(w.Body.String(), `test_job_seconds_bucket{le="0.1"} 1 # {trace_id="`+traceID+`"} 0.05`)
+}
+
+func TestStatsD(t *testing.T) {
+	a := assert.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	a.NoError(err)
+	defer conn.Close()
+	receive := func() string { // all packets of a push
+		var packets []string
+		buf := make([]byte, maxStatsDPacket)
+		for {
+			_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return strings.Join(packets, "\n")
+			}
+			a.LessOrEqual(n, maxStatsDPacket)
+			packets = append(packets, string(buf[:n]))
+		}
+	}
+
+	r, counter := testRegistry(t, Config{StatsD: StatsDConfig{Enabled: true, Address: conn.LocalAddr().String(), Flavor: FlavorDogStatsD}})
+	a.NoError(r.Push(context.Background()))
+	lines := strings.Split(receive(), "\n")
+	a.Contains(lines, "test_jobs_total:3|c|#queue:main")
+	a.Contains(lines, "test_queue_length:7|g")
+	a.Contains(lines, "test_job_seconds_count:3|c")
+
+	counter.Add(2)
+	a.NoError(r.Push(context.Background()))
+	packet := receive()
+	a.Contains(packet, "test_jobs_total:2|c|#queue:main") // delta
+	a.NotContains(packet, "test_job_seconds_count")       // unchanged
+
+	var wg sync.WaitGroup // Push concurrent with Run
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.NoError(r.Push(context.Background()))
+		}()
+	}
+	wg.Wait()
+
+	e, err := newStatsDExporter(StatsDConfig{Address: conn.LocalAddr().String()})
+	a.NoError(err)
+	a.Equal("test_jobs_total.main:1|c", e.line("test_jobs_total", [][2]string{{"queue", "main"}}, 1, "c"))
+	_, err = newStatsDExporter(StatsDConfig{Flavor: "graphite"})
+	a.Error(err)
+}
+
+// httpReceiver returns a server recording the last request
+func httpReceiver(t *testing.T) (*httptest.Server, func() (*http.Request, []byte)) {
+	t.Helper()
+	var (
+		last *http.Request
+		body []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() (*http.Request, []byte) { return last, body }
+}
+
+func TestPushgateway(t *testing.T) {
+	a := assert.New(t)
+
+	srv, received := httpReceiver(t)
+	r, _ := testRegistry(t, Config{Push: PushConfig{Enabled: true, URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer x"}}})
+	a.NoError(r.Push(context.Background()))
+	req, body := received()
+	a.Equal(http.MethodPut, req.Method)
+	a.Equal("/metrics/job/test", req.URL.Path)
+	a.Equal("Bearer x", req.Header.Get("Authorization"))
+	a.Contains(string(body), "test_jobs_total")
+
+	_, err := NewRegistry(Config{Push: PushConfig{Enabled: true}})
+	a.Error(err)
+	_, err = NewRegistry(Config{Push: PushConfig{Enabled: true, URL: srv.URL, Type: "carrier-pigeon"}})
+	a.Error(err)
+}
+
+func TestRemoteWrite(t *testing.T) {
+	a := assert.New(t)
+
+	srv, received := httpReceiver(t)
+	r, _ := testRegistry(t, Config{Push: PushConfig{Enabled: true, Type: PushRemoteWrite, URL: srv.URL, ExternalLabels: map[string]string{"instance": "replica-1", "queue": "default"}}})
+	a.NoError(r.Push(context.Background()))
+	req, body := received()
+	a.Equal("snappy", req.Header.Get("Content-Encoding"))
+	data, err := snappy.Decode(nil, body)
+	a.NoError(err)
+
+	// collect series as "name{label=value,...}" -> value
+	series := make(map[string]float64)
+	for len(data) > 0 {
+		_, _, n := protowire.ConsumeTag(data)
+		ts, m := protowire.ConsumeBytes(data[n:])
+		data = data[n+m:]
+		var labels []string
+		var value float64
+		for len(ts) > 0 {
+			num, typ, n := protowire.ConsumeTag(ts)
+			field, m := protowire.ConsumeBytes(ts[n:])
+			a.Equal(protowire.BytesType, typ)
+			ts = ts[n+m:]
+			if num == 1 { // label
+				_, _, n := protowire.ConsumeTag(field)
+				name, m := protowire.ConsumeString(field[n:])
+				field = field[n+m:]
+				_, _, n = protowire.ConsumeTag(field)
+				val, _ := protowire.ConsumeString(field[n:])
+				labels = append(labels, name+"="+val)
+			} else { // sample
+				_, _, n := protowire.ConsumeTag(field)
+				bits, _ := protowire.ConsumeFixed64(field[n:])
+				value = math.Float64frombits(bits)
+			}
+		}
+		series[strings.Join(labels, ",")] = value
+	}
+	a.InDelta(3.0, series["__name__=test_jobs_total,instance=replica-1,job=test,queue=main"], 0)
+	a.InDelta(7.0, series["__name__=test_queue_length,instance=replica-1,job=test,queue=default"], 0)
+	a.InDelta(2.0, series["__name__=test_job_seconds_bucket,instance=replica-1,job=test,le=1,queue=default"], 0)
+	a.InDelta(3.0, series["__name__=test_job_seconds_bucket,instance=replica-1,job=test,le=+Inf,queue=default"], 0)
+	a.InDelta(3.0, series["__name__=test_job_seconds_count,instance=replica-1,job=test,queue=default"], 0)
+
+	host, _ := os.Hostname()
+	a.Equal([][2]string{{"instance", host}, {"job", "test"}}, externalLabels(nil, "test"))
+}
+
+func TestOTLP(t *testing.T) {
+	a := assert.New(t)
+
+	srv, received := httpReceiver(t)
+	r, _ := testRegistry(t, Config{OTLP: OTLPConfig{Enabled: true, Endpoint: srv.URL + "/v1/metrics"}})
+	a.NoError(r.Push(context.Background()))
+	req, body := received()
+	a.Equal("/v1/metrics", req.URL.Path)
+	var request colmetricspb.ExportMetricsServiceRequest
+	a.NoError(proto.Unmarshal(body, &request))
+
+	rm := request.GetResourceMetrics()[0]
+	a.Equal("test", rm.GetResource().GetAttributes()[0].GetValue().GetStringValue())
+	found := 0
+	for _, m := range rm.GetScopeMetrics()[0].GetMetrics() {
+		switch m.GetName() {
+		case "test_jobs_total":
+			a.True(m.GetSum().GetIsMonotonic())
+			a.InDelta(3.0, m.GetSum().GetDataPoints()[0].GetAsDouble(), 0)
+			found++
+		case "test_job_seconds":
+			point := m.GetHistogram().GetDataPoints()[0]
+			a.Equal([]float64{0.1, 1}, point.GetExplicitBounds())
+			a.Equal([]uint64{1, 1, 1}, point.GetBucketCounts())
+			a.Equal(traceID, hex.EncodeToString(point.GetExemplars()[0].GetTraceId()))
+			found++
+		}
+	}
+	a.Equal(2, found)
+}
+
+func TestExporterInterval(t *testing.T) {
+	a := assert.New(t)
+
+	for _, cfg := range []Config{
+		{StatsD: StatsDConfig{Enabled: true, Interval: -time.Second}},
+		{Push: PushConfig{Enabled: true, URL: "http://localhost:9091", Interval: -time.Second}},
+		{OTLP: OTLPConfig{Enabled: true, Interval: -time.Second}},
+	} {
+		_, err := NewRegistry(cfg)
+		a.ErrorIs(err, errInterval)
+	}
+}
+
+func TestRun(t *testing.T) {
+	a := assert.New(t)
+
+	srv, received := httpReceiver(t)
+	r, _ := testRegistry(t, Config{OTLP: OTLPConfig{Enabled: true, Endpoint: srv.URL, Interval: time.Hour}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a.NoError(r.Run(ctx, log.NewNop())) // final push on exit
+	req, _ := received()
+	a.NotNil(req)
+}
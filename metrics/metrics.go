@@ -3,6 +3,7 @@ package metrics
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
@@ -10,20 +11,37 @@ import (
 )
 
 type Config struct {
-	Namespace string    `yaml:"namespace" description:"Metrics namespace" required:"true"`
-	Buckets   []float64 `yaml:"buckets" description:"List of buckets for request latency histogram metric"`
+	Namespace        string       `yaml:"namespace" description:"Metrics namespace" required:"true"`
+	Buckets          []float64    `yaml:"buckets" description:"List of buckets for request latency histogram metric"`
+	SizeBuckets      []float64    `yaml:"sizeBuckets" description:"List of buckets for request/response size histogram metrics (bytes)"`
+	NativeHistograms bool         `yaml:"nativeHistograms" description:"Also expose histograms as native (sparse) histograms"`
+	LegacyNames      bool         `yaml:"legacyNames" description:"Keep exporting the old error_count and request_latency metrics"`
+	OpenMetrics      bool         `yaml:"openMetrics" description:"Serve OpenMetrics format with exemplars (trace IDs) to scrapers requesting it"`
+	StatsD           StatsDConfig `yaml:"statsd" description:"Push to StatsD/DogStatsD"`
+	Push             PushConfig   `yaml:"push" description:"Push to Pushgateway or Prometheus remote-write endpoint"`
+	OTLP             OTLPConfig   `yaml:"otlp" description:"Push to OTLP/HTTP metrics endpoint"`
 }
 
 type Registry struct {
-	registry *prometheus.Registry
-	prefix   string
+	registry    *prometheus.Registry
+	prefix      string
+	openMetrics bool
+	start       time.Time
+	exporters   []exporter
 }
 
 func NewRegistry(config Config) (*Registry, error) {
 	registry := &Registry{
-		registry: prometheus.NewRegistry(),
-		prefix:   config.Namespace + "_",
+		registry:    prometheus.NewRegistry(),
+		prefix:      config.Namespace + "_",
+		openMetrics: config.OpenMetrics,
+		start:       time.Now(),
 	}
+	exporters, err := newExporters(config, registry.start)
+	if err != nil {
+		return nil, err
+	}
+	registry.exporters = exporters
 
 	// Default system metrics
 	if err := registry.Get().Register(collectors.NewGoCollector()); err != nil {
@@ -38,7 +56,7 @@ func NewRegistry(config Config) (*Registry, error) {
 
 func (r *Registry) Handler() http.Handler {
 	return promhttp.InstrumentMetricHandler(
-		r.Get(), promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{}),
+		r.Get(), promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{EnableOpenMetrics: r.openMetrics}),
 	)
 }
 
@@ -0,0 +1,197 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultOTLPEndpoint = "http://localhost:4318/v1/metrics"
+	defaultOTLPInterval = 30 * time.Second
+	otlpScope           = "github.com/bhmj/goblocks/metrics"
+	exemplarTraceID     = "trace_id"
+)
+
+// OTLPConfig defines push to OpenTelemetry collector over OTLP/HTTP (protobuf)
+type OTLPConfig struct {
+	Enabled  bool              `yaml:"enabled" description:"Push metrics over OTLP/HTTP"`
+	Endpoint string            `yaml:"endpoint" description:"Metrics endpoint URL" default:"http://localhost:4318/v1/metrics"`
+	Interval time.Duration     `yaml:"interval" description:"Push interval" default:"30s"`
+	Headers  map[string]string `yaml:"headers" description:"Extra request headers, e.g. Authorization"`
+}
+
+// otlpExporter sends cumulative sums (counters), gauges, explicit bucket histograms and summaries.
+// Resource attribute service.name is the metrics namespace.
+type otlpExporter struct {
+	cfg     OTLPConfig
+	service string
+	start   uint64
+	client  *http.Client
+}
+
+func newOTLPExporter(cfg OTLPConfig, namespace string, start time.Time) (*otlpExporter, error) {
+	var err error
+	cfg.Endpoint = orDefault(cfg.Endpoint, defaultOTLPEndpoint)
+	if cfg.Interval, err = pushInterval(cfg.Interval, defaultOTLPInterval); err != nil {
+		return nil, err
+	}
+	return &otlpExporter{cfg: cfg, service: namespace, start: uint64(start.UnixNano()), client: &http.Client{Timeout: pushTimeout}}, nil //nolint:gosec
+}
+
+func (e *otlpExporter) name() string            { return "otlp" }
+func (e *otlpExporter) interval() time.Duration { return e.cfg.Interval }
+
+func (e *otlpExporter) export(ctx context.Context, families []*dto.MetricFamily) error {
+	body, err := proto.Marshal(e.request(families, uint64(time.Now().UnixNano()))) //nolint:gosec
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	headers := map[string]string{"Content-Type": "application/x-protobuf"}
+	for k, v := range e.cfg.Headers {
+		headers[k] = v
+	}
+	return post(ctx, e.client, e.cfg.Endpoint, bytes.NewReader(body), headers)
+}
+
+func (e *otlpExporter) request(families []*dto.MetricFamily, now uint64) *colmetricspb.ExportMetricsServiceRequest {
+	metrics := make([]*metricspb.Metric, 0, len(families))
+	for _, family := range families {
+		if metric := e.metric(family, now); metric != nil {
+			metrics = append(metrics, metric)
+		}
+	}
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource:     &resourcepb.Resource{Attributes: []*commonpb.KeyValue{stringAttribute("service.name", e.service)}},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{Scope: &commonpb.InstrumentationScope{Name: otlpScope}, Metrics: metrics}},
+		}},
+	}
+}
+
+func (e *otlpExporter) metric(family *dto.MetricFamily, now uint64) *metricspb.Metric {
+	metric := &metricspb.Metric{Name: family.GetName(), Description: family.GetHelp()}
+	number := func(m *dto.Metric, value float64, exemplar *dto.Exemplar) *metricspb.NumberDataPoint {
+		return &metricspb.NumberDataPoint{
+			Attributes:        attributes(m),
+			StartTimeUnixNano: e.start,
+			TimeUnixNano:      now,
+			Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+			Exemplars:         exemplars(exemplar),
+		}
+	}
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		sum := &metricspb.Sum{AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, IsMonotonic: true}
+		for _, m := range family.GetMetric() {
+			sum.DataPoints = append(sum.DataPoints, number(m, m.GetCounter().GetValue(), m.GetCounter().GetExemplar()))
+		}
+		metric.Data = &metricspb.Metric_Sum{Sum: sum}
+	case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+		gauge := &metricspb.Gauge{}
+		for _, m := range family.GetMetric() {
+			value := m.GetGauge().GetValue()
+			if family.GetType() == dto.MetricType_UNTYPED {
+				value = m.GetUntyped().GetValue()
+			}
+			gauge.DataPoints = append(gauge.DataPoints, number(m, value, nil))
+		}
+		metric.Data = &metricspb.Metric_Gauge{Gauge: gauge}
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		histogram := &metricspb.Histogram{AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE}
+		for _, m := range family.GetMetric() {
+			histogram.DataPoints = append(histogram.DataPoints, e.histogramPoint(m, now))
+		}
+		metric.Data = &metricspb.Metric_Histogram{Histogram: histogram}
+	case dto.MetricType_SUMMARY:
+		summary := &metricspb.Summary{}
+		for _, m := range family.GetMetric() {
+			s := m.GetSummary()
+			point := &metricspb.SummaryDataPoint{
+				Attributes:        attributes(m),
+				StartTimeUnixNano: e.start,
+				TimeUnixNano:      now,
+				Count:             s.GetSampleCount(),
+				Sum:               s.GetSampleSum(),
+			}
+			for _, q := range s.GetQuantile() {
+				point.QuantileValues = append(point.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{Quantile: q.GetQuantile(), Value: q.GetValue()})
+			}
+			summary.DataPoints = append(summary.DataPoints, point)
+		}
+		metric.Data = &metricspb.Metric_Summary{Summary: summary}
+	default:
+		return nil
+	}
+	return metric
+}
+
+// histogramPoint converts cumulative Prometheus buckets to OTLP per-bucket counts
+func (e *otlpExporter) histogramPoint(m *dto.Metric, now uint64) *metricspb.HistogramDataPoint {
+	h := m.GetHistogram()
+	sum := h.GetSampleSum()
+	point := &metricspb.HistogramDataPoint{
+		Attributes:        attributes(m),
+		StartTimeUnixNano: e.start,
+		TimeUnixNano:      now,
+		Count:             h.GetSampleCount(),
+		Sum:               &sum,
+	}
+	var previous uint64
+	for _, b := range h.GetBucket() {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+		point.ExplicitBounds = append(point.ExplicitBounds, b.GetUpperBound())
+		point.BucketCounts = append(point.BucketCounts, b.GetCumulativeCount()-previous)
+		previous = b.GetCumulativeCount()
+		point.Exemplars = append(point.Exemplars, exemplars(b.GetExemplar())...)
+	}
+	point.BucketCounts = append(point.BucketCounts, h.GetSampleCount()-previous) // +Inf
+	for _, exemplar := range h.GetExemplars() {                                  // native histograms
+		point.Exemplars = append(point.Exemplars, exemplars(exemplar)...)
+	}
+	return point
+}
+
+func attributes(m *dto.Metric) []*commonpb.KeyValue {
+	attrs := make([]*commonpb.KeyValue, 0, len(m.GetLabel()))
+	for _, l := range m.GetLabel() {
+		attrs = append(attrs, stringAttribute(l.GetName(), l.GetValue()))
+	}
+	return attrs
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+// exemplars converts the exemplar taking trace ID from the trace_id label
+func exemplars(exemplar *dto.Exemplar) []*metricspb.Exemplar {
+	if exemplar == nil {
+		return nil
+	}
+	result := &metricspb.Exemplar{
+		TimeUnixNano: uint64(exemplar.GetTimestamp().AsTime().UnixNano()), //nolint:gosec
+		Value:        &metricspb.Exemplar_AsDouble{AsDouble: exemplar.GetValue()},
+	}
+	for _, l := range exemplar.GetLabel() {
+		if traceID, err := hex.DecodeString(l.GetValue()); l.GetName() == exemplarTraceID && err == nil {
+			result.TraceId = traceID
+		} else {
+			result.FilteredAttributes = append(result.FilteredAttributes, stringAttribute(l.GetName(), l.GetValue()))
+		}
+	}
+	return []*metricspb.Exemplar{result}
+}
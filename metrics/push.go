@@ -0,0 +1,202 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	PushGateway     = "pushgateway"
+	PushRemoteWrite = "remoteWrite"
+
+	defaultPushInterval = 15 * time.Second
+	pushTimeout         = 10 * time.Second
+)
+
+// PushConfig defines push to Prometheus Pushgateway or remote-write endpoint
+type PushConfig struct {
+	Enabled  bool              `yaml:"enabled" description:"Push metrics"`
+	Type     string            `yaml:"type" description:"Receiver type" default:"pushgateway" choices:"pushgateway,remoteWrite"`
+	URL      string            `yaml:"url" description:"Pushgateway base URL or remote-write endpoint URL"`
+	Job      string            `yaml:"job" description:"Pushgateway job name (metrics namespace if empty)"`
+	Interval time.Duration     `yaml:"interval" description:"Push interval" default:"15s"`
+	Headers  map[string]string `yaml:"headers" description:"Extra request headers, e.g. Authorization"`
+	// ExternalLabels tell replicas apart: without them every instance writes identical series
+	ExternalLabels map[string]string `yaml:"externalLabels" description:"Labels added to remote-write series (job: namespace, instance: hostname if not set)"`
+}
+
+func newPushExporter(cfg PushConfig, namespace string) (exporter, error) {
+	var err error
+	cfg.Type = orDefault(cfg.Type, PushGateway)
+	if cfg.Interval, err = pushInterval(cfg.Interval, defaultPushInterval); err != nil {
+		return nil, err
+	}
+	if cfg.URL == "" {
+		return nil, errNoURL
+	}
+	client := &http.Client{Timeout: pushTimeout}
+	switch cfg.Type {
+	case PushGateway:
+		return &pushgatewayExporter{cfg: cfg, job: orDefault(cfg.Job, namespace), client: client}, nil
+	case PushRemoteWrite:
+		return &remoteWriteExporter{cfg: cfg, labels: externalLabels(cfg.ExternalLabels, namespace), client: client}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", errUnknownPush, cfg.Type)
+}
+
+// pushgatewayExporter replaces metrics of the job group in Pushgateway
+type pushgatewayExporter struct {
+	cfg    PushConfig
+	job    string
+	client *http.Client
+}
+
+func (e *pushgatewayExporter) name() string            { return PushGateway }
+func (e *pushgatewayExporter) interval() time.Duration { return e.cfg.Interval }
+
+func (e *pushgatewayExporter) export(ctx context.Context, families []*dto.MetricFamily) error {
+	header := make(http.Header, len(e.cfg.Headers))
+	for k, v := range e.cfg.Headers {
+		header.Set(k, v)
+	}
+	gatherer := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return families, nil })
+	err := push.New(e.cfg.URL, e.job).Gatherer(gatherer).Client(e.client).Header(header).PushContext(ctx)
+	if err != nil {
+		return fmt.Errorf("pushgateway: %w", err)
+	}
+	return nil
+}
+
+// remoteWriteExporter sends samples using Prometheus remote-write protocol 1.0 (snappy-compressed protobuf WriteRequest)
+type remoteWriteExporter struct {
+	cfg    PushConfig
+	labels [][2]string // external labels
+	client *http.Client
+}
+
+// externalLabels returns configured labels with job and instance defaults, sorted by name
+func externalLabels(configured map[string]string, namespace string) [][2]string {
+	labels := map[string]string{"job": namespace}
+	if host, err := os.Hostname(); err == nil {
+		labels["instance"] = host
+	}
+	maps.Copy(labels, configured)
+	pairs := make([][2]string, 0, len(labels))
+	for k, v := range labels {
+		if v != "" {
+			pairs = append(pairs, [2]string{k, v})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return pairs
+}
+
+func (e *remoteWriteExporter) name() string            { return PushRemoteWrite }
+func (e *remoteWriteExporter) interval() time.Duration { return e.cfg.Interval }
+
+func (e *remoteWriteExporter) export(ctx context.Context, families []*dto.MetricFamily) error {
+	body := snappy.Encode(nil, writeRequest(families, e.labels, time.Now().UnixMilli()))
+	headers := map[string]string{
+		"Content-Type":                      "application/x-protobuf",
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	}
+	for k, v := range e.cfg.Headers {
+		headers[k] = v
+	}
+	return post(ctx, e.client, e.cfg.URL, bytes.NewReader(body), headers)
+}
+
+// writeRequest encodes families as prometheus.WriteRequest: series of histograms and summaries are flattened
+// into _bucket/quantile, _sum and _count series as in the text exposition format.
+// External labels are added unless the metric has a label of the same name.
+func writeRequest(families []*dto.MetricFamily, external [][2]string, timestamp int64) []byte {
+	var request []byte
+	series := func(name string, labels [][2]string, value float64) {
+		for _, l := range external {
+			if !slices.ContainsFunc(labels, func(m [2]string) bool { return m[0] == l[0] }) {
+				labels = append(labels, l)
+			}
+		}
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, timeSeries(name, labels, value, timestamp))
+	}
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				series(name, labelPairs(m), m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				series(name, labelPairs(m), m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				series(name, labelPairs(m), m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				infSeen := false
+				for _, b := range h.GetBucket() {
+					infSeen = infSeen || math.IsInf(b.GetUpperBound(), 1)
+					series(name+"_bucket", labelPairs(m, "le", formatFloat(b.GetUpperBound())), float64(b.GetCumulativeCount()))
+				}
+				if !infSeen {
+					series(name+"_bucket", labelPairs(m, "le", "+Inf"), float64(h.GetSampleCount()))
+				}
+				series(name+"_sum", labelPairs(m), h.GetSampleSum())
+				series(name+"_count", labelPairs(m), float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					series(name, labelPairs(m, "quantile", formatFloat(q.GetQuantile())), q.GetValue())
+				}
+				series(name+"_sum", labelPairs(m), s.GetSampleSum())
+				series(name+"_count", labelPairs(m), float64(s.GetSampleCount()))
+			}
+		}
+	}
+	return request
+}
+
+// timeSeries encodes prometheus.TimeSeries of one sample with labels sorted by name
+func timeSeries(name string, labels [][2]string, value float64, timestamp int64) []byte {
+	labels = append(labels, [2]string{"__name__", name})
+	sort.Slice(labels, func(i, j int) bool { return labels[i][0] < labels[j][0] })
+	var ts []byte
+	for _, l := range labels {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, l[0])
+		label = protowire.AppendTag(label, 2, protowire.BytesType) //nolint:mnd
+		label = protowire.AppendString(label, l[1])
+		ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, label)
+	}
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType) //nolint:mnd
+	sample = protowire.AppendVarint(sample, uint64(timestamp))    //nolint:gosec
+	ts = protowire.AppendTag(ts, 2, protowire.BytesType)          //nolint:mnd
+	return protowire.AppendBytes(ts, sample)
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	FlavorStatsD    = "statsd"
+	FlavorDogStatsD = "dogstatsd"
+
+	defaultStatsDAddress  = "127.0.0.1:8125"
+	defaultStatsDInterval = 10 * time.Second
+	maxStatsDPacket       = 1432 // fits Ethernet MTU
+)
+
+// StatsDConfig defines StatsD/DogStatsD push over UDP
+type StatsDConfig struct {
+	Enabled  bool          `yaml:"enabled" description:"Push metrics to StatsD"`
+	Address  string        `yaml:"address" description:"StatsD host:port" default:"127.0.0.1:8125"`
+	Flavor   string        `yaml:"flavor" description:"Line format: statsd puts label values into the metric name, dogstatsd sends labels as tags" default:"statsd" choices:"statsd,dogstatsd"`
+	Interval time.Duration `yaml:"interval" description:"Push interval" default:"10s"`
+}
+
+// statsdExporter sends counters as deltas since the previous push (|c) and gauges as values (|g).
+// Histograms and summaries are sent as _count and _sum counters, summary quantiles as gauges.
+type statsdExporter struct {
+	cfg  StatsDConfig
+	conn net.Conn
+
+	mu   sync.Mutex         // Push may run concurrently with Run
+	last map[string]float64 // previous counter values
+}
+
+func newStatsDExporter(cfg StatsDConfig) (*statsdExporter, error) {
+	cfg.Address = orDefault(cfg.Address, defaultStatsDAddress)
+	cfg.Flavor = orDefault(cfg.Flavor, FlavorStatsD)
+	var err error
+	if cfg.Interval, err = pushInterval(cfg.Interval, defaultStatsDInterval); err != nil {
+		return nil, err
+	}
+	if cfg.Flavor != FlavorStatsD && cfg.Flavor != FlavorDogStatsD {
+		return nil, fmt.Errorf("%w: %s", errUnknownFlavor, cfg.Flavor)
+	}
+	conn, err := net.Dial("udp", cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", cfg.Address, err)
+	}
+	return &statsdExporter{cfg: cfg, conn: conn, last: make(map[string]float64)}, nil
+}
+
+func (e *statsdExporter) name() string            { return "statsd" }
+func (e *statsdExporter) interval() time.Duration { return e.cfg.Interval }
+
+func (e *statsdExporter) export(_ context.Context, families []*dto.MetricFamily) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var packet []byte
+	for _, line := range e.lines(families) {
+		if len(packet)+len(line)+1 > maxStatsDPacket && len(packet) > 0 {
+			if _, err := e.conn.Write(packet); err != nil {
+				return fmt.Errorf("send: %w", err)
+			}
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		if _, err := e.conn.Write(packet); err != nil {
+			return fmt.Errorf("send: %w", err)
+		}
+	}
+	return nil
+}
+
+func (e *statsdExporter) lines(families []*dto.MetricFamily) []string {
+	var lines []string
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				lines = e.counter(lines, name, labelPairs(m), m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				lines = append(lines, e.line(name, labelPairs(m), m.GetGauge().GetValue(), "g"))
+			case dto.MetricType_UNTYPED:
+				lines = append(lines, e.line(name, labelPairs(m), m.GetUntyped().GetValue(), "g"))
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				lines = e.counter(lines, name+"_count", labelPairs(m), float64(h.GetSampleCount()))
+				lines = e.counter(lines, name+"_sum", labelPairs(m), h.GetSampleSum())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				lines = e.counter(lines, name+"_count", labelPairs(m), float64(s.GetSampleCount()))
+				lines = e.counter(lines, name+"_sum", labelPairs(m), s.GetSampleSum())
+				for _, q := range s.GetQuantile() {
+					quantile := strconv.FormatFloat(q.GetQuantile(), 'f', -1, 64)
+					lines = append(lines, e.line(name, labelPairs(m, "quantile", quantile), q.GetValue(), "g"))
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// counter appends the increment of the counter since the previous push
+func (e *statsdExporter) counter(lines []string, name string, labels [][2]string, value float64) []string {
+	key := e.line(name, labels, 0, "")
+	delta := value - e.last[key]
+	if delta < 0 { // reset
+		delta = value
+	}
+	e.last[key] = value
+	if delta == 0 {
+		return lines
+	}
+	return append(lines, e.line(name, labels, delta, "c"))
+}
+
+func (e *statsdExporter) line(name string, labels [][2]string, value float64, kind string) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	if e.cfg.Flavor == FlavorStatsD {
+		for _, l := range labels {
+			sb.WriteByte('.')
+			sb.WriteString(sanitizeStatsD(l[1]))
+		}
+	}
+	sb.WriteByte(':')
+	sb.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	sb.WriteByte('|')
+	sb.WriteString(kind)
+	if e.cfg.Flavor == FlavorDogStatsD && len(labels) > 0 {
+		sb.WriteString("|#")
+		for i, l := range labels {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(sanitizeStatsD(l[0]) + ":" + sanitizeStatsD(l[1]))
+		}
+	}
+	return sb.String()
+}
+
+// sanitizeStatsD replaces characters having special meaning in StatsD lines
+func sanitizeStatsD(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', ',', '.', ' ', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,72 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const minSecretSize = 32
+
+var errShortSecret = errors.New("cookie store secret must be at least 32 bytes")
+
+// CookieStore keeps the whole session in the cookie signed with HMAC-SHA256. The data is not encrypted,
+// so do not put secrets into it. JSON decoding applies: numbers are loaded as float64.
+// Delete is a no-op: a destroyed session remains valid until it expires if the client keeps the cookie.
+type CookieStore struct {
+	secret []byte
+}
+
+func NewCookieStore(secret string) (*CookieStore, error) {
+	if len(secret) < minSecretSize {
+		return nil, errShortSecret
+	}
+	return &CookieStore{secret: []byte(secret)}, nil
+}
+
+func (s *CookieStore) Load(token string) (*Session, error) {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, ErrNotFound
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, s.sign(payload)) {
+		return nil, ErrNotFound
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, ErrNotFound
+	}
+	if !time.Now().Before(sess.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return &sess, nil
+}
+
+func (s *CookieStore) Save(sess *Session) (string, error) {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return "", fmt.Errorf("marshal session: %w", err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload)), nil
+}
+
+func (s *CookieStore) Delete(string) error {
+	return nil
+}
+
+func (s *CookieStore) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,57 @@
+package session
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process session store
+type MemoryStore struct {
+	sync.Mutex
+	sessions map[string]Session
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]Session)}
+}
+
+func (s *MemoryStore) Load(token string) (*Session, error) {
+	s.Lock()
+	defer s.Unlock()
+	sess, found := s.sessions[token]
+	if !found || !time.Now().Before(sess.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	sess.Data = maps.Clone(sess.Data)
+	return &sess, nil
+}
+
+func (s *MemoryStore) Save(sess *Session) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+	stored := *sess
+	stored.Data = maps.Clone(sess.Data)
+	s.sessions[sess.ID] = stored
+	return sess.ID, nil
+}
+
+func (s *MemoryStore) Delete(token string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.sessions, token)
+	return nil
+}
+
+// Cleanup removes expired sessions
+func (s *MemoryStore) Cleanup() error {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	for token, sess := range s.sessions {
+		if !now.Before(sess.ExpiresAt) {
+			delete(s.sessions, token)
+		}
+	}
+	return nil
+}
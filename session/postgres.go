@@ -0,0 +1,113 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/bhmj/goblocks/dbase/abstract"
+)
+
+// PostgresSchema creates the sessions table. %s is the table name.
+const PostgresSchema = `create table if not exists %s (
+	id         text primary key,
+	data       jsonb not null,
+	created_at timestamptz not null,
+	last_seen  timestamptz not null,
+	expires_at timestamptz not null
+);
+create index if not exists %[1]s_expires_at on %[1]s (expires_at);`
+
+var (
+	errTableName = errors.New("invalid session table name")
+	reTableName  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// PostgresStore keeps sessions in a Postgres table (see PostgresSchema).
+// Session IDs are stored hashed, so a leaked table does not allow session hijacking.
+type PostgresStore struct {
+	db    abstract.DB
+	table string
+}
+
+type sessionRow struct {
+	Data      []byte    `db:"data"`
+	CreatedAt time.Time `db:"created_at"`
+	LastSeen  time.Time `db:"last_seen"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+func NewPostgresStore(db abstract.DB, table string) (*PostgresStore, error) {
+	if table == "" {
+		table = defaultTableName
+	}
+	if !reTableName.MatchString(table) {
+		return nil, fmt.Errorf("%w: %s", errTableName, table)
+	}
+	return &PostgresStore{db: db, table: table}, nil
+}
+
+// CreateTable creates the sessions table if it does not exist
+func (s *PostgresStore) CreateTable() error {
+	if err := s.db.Exec(fmt.Sprintf(PostgresSchema, s.table)); err != nil {
+		return fmt.Errorf("create session table: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Load(token string) (*Session, error) {
+	var row sessionRow
+	found, err := s.db.QueryRow(&row,
+		`select data, created_at, last_seen, expires_at from `+s.table+` where id = $1 and expires_at > now()`,
+		hashID(token))
+	if err != nil {
+		return nil, fmt.Errorf("query session: %w", err)
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	sess := &Session{ID: token, CreatedAt: row.CreatedAt, LastSeen: row.LastSeen, ExpiresAt: row.ExpiresAt}
+	if err := json.Unmarshal(row.Data, &sess.Data); err != nil {
+		return nil, fmt.Errorf("unmarshal session data: %w", err)
+	}
+	return sess, nil
+}
+
+func (s *PostgresStore) Save(sess *Session) (string, error) {
+	data, err := json.Marshal(sess.Data)
+	if err != nil {
+		return "", fmt.Errorf("marshal session data: %w", err)
+	}
+	err = s.db.Exec(
+		`insert into `+s.table+` (id, data, created_at, last_seen, expires_at) values ($1, $2::jsonb, $3, $4, $5)
+		on conflict (id) do update set data = excluded.data, last_seen = excluded.last_seen, expires_at = excluded.expires_at`,
+		hashID(sess.ID), string(data), sess.CreatedAt, sess.LastSeen, sess.ExpiresAt)
+	if err != nil {
+		return "", fmt.Errorf("save session: %w", err)
+	}
+	return sess.ID, nil
+}
+
+func (s *PostgresStore) Delete(token string) error {
+	if err := s.db.Exec(`delete from `+s.table+` where id = $1`, hashID(token)); err != nil {
+		return fmt.Errorf("delete session: %w", err)
+	}
+	return nil
+}
+
+// Cleanup removes expired sessions
+func (s *PostgresStore) Cleanup() error {
+	if err := s.db.Exec(`delete from ` + s.table + ` where expires_at <= now()`); err != nil {
+		return fmt.Errorf("delete expired sessions: %w", err)
+	}
+	return nil
+}
+
+func hashID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
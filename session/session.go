@@ -0,0 +1,266 @@
+// Package session implements server-side (memory, Postgres) and signed cookie sessions
+// with sliding and absolute expiration.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bhmj/goblocks/httpserver"
+)
+
+const (
+	StoreMemory = "memory"
+	StoreCookie = "cookie"
+
+	idSize           = 32
+	touchRatio       = 10 // sliding expiration is extended after IdleTimeout/touchRatio
+	defaultCookie    = "SID"
+	defaultIdle      = 30 * time.Minute
+	defaultLifetime  = 24 * time.Hour
+	cleanupInterval  = 10 * time.Minute
+	defaultTableName = "sessions"
+)
+
+var (
+	ErrNotFound  = errors.New("session not found")
+	ErrNoSession = errors.New("no valid session")
+
+	errUnknownStore = errors.New("unknown session store")
+)
+
+// Config defines session cookie and expiration parameters
+type Config struct {
+	Enabled     bool          `yaml:"enabled" description:"Use built-in sessions for SIDRequired endpoints (instead of Service.GetSessionData)"`
+	Store       string        `yaml:"store" description:"Session store (Postgres store is set in code)" default:"memory" choices:"memory,cookie"`
+	Secret      string        `yaml:"secret" description:"Signing key of cookie store (32+ random bytes)"`
+	CookieName  string        `yaml:"cookieName" description:"Session cookie name" default:"SID"`
+	Domain      string        `yaml:"domain" description:"Cookie domain"`
+	Path        string        `yaml:"path" description:"Cookie path" default:"/"`
+	SameSite    string        `yaml:"sameSite" description:"Cookie SameSite attribute" default:"lax" choices:"lax,strict,none"`
+	Insecure    bool          `yaml:"insecure" description:"Allow session cookie over plain HTTP (development only)"`
+	IdleTimeout time.Duration `yaml:"idleTimeout" description:"Sliding expiration: session expires after this period of inactivity" default:"30m"`
+	MaxLifetime time.Duration `yaml:"maxLifetime" description:"Absolute expiration: session expires this long after login" default:"24h"`
+	Strict      bool          `yaml:"strict" description:"Reply 401 on SIDRequired endpoints if there is no valid session"`
+}
+
+// Session is a user session
+type Session struct {
+	ID        string         `json:"id"`
+	Data      map[string]any `json:"data"`
+	CreatedAt time.Time      `json:"created"`
+	LastSeen  time.Time      `json:"seen"`
+	ExpiresAt time.Time      `json:"expires"`
+}
+
+// SessionID returns the session ID (used to bind CSRF tokens to the session)
+func (s *Session) SessionID() string {
+	return s.ID
+}
+
+// Store persists sessions. Save returns the cookie value (the session ID for server-side stores
+// or the signed session itself for the cookie store), Load and Delete accept it.
+type Store interface {
+	Load(token string) (*Session, error) // ErrNotFound if missing or expired
+	Save(s *Session) (string, error)
+	Delete(token string) error
+}
+
+// Cleaner is implemented by stores which need periodic removal of expired sessions
+type Cleaner interface {
+	Cleanup() error
+}
+
+// Manager issues, loads, refreshes and destroys sessions
+type Manager struct {
+	cfg   Config
+	store Store
+	now   func() time.Time
+}
+
+func NewManager(cfg Config, store Store) *Manager {
+	if cfg.CookieName == "" {
+		cfg.CookieName = defaultCookie
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = defaultIdle
+	}
+	if cfg.MaxLifetime == 0 {
+		cfg.MaxLifetime = defaultLifetime
+	}
+	return &Manager{cfg: cfg, store: store, now: time.Now}
+}
+
+// NewStore creates a store of the configured type. Postgres store requires a DB, so it is not configurable:
+// create it with NewPostgresStore and pass to app.SetSessionStore.
+func NewStore(cfg Config) (Store, error) {
+	switch cfg.Store {
+	case "", StoreMemory:
+		return NewMemoryStore(), nil
+	case StoreCookie:
+		return NewCookieStore(cfg.Secret)
+	}
+	return nil, fmt.Errorf("%w: %q", errUnknownStore, cfg.Store)
+}
+
+// Strict reports whether SIDRequired endpoints require a valid session
+func (m *Manager) Strict() bool {
+	return m.cfg.Strict
+}
+
+// Start creates a new session (e.g. on login) and sets the cookie. The previous session of the request
+// is destroyed, so the session ID always changes on login (session fixation protection).
+func (m *Manager) Start(w http.ResponseWriter, r *http.Request, data map[string]any) (*Session, error) {
+	if cookie, err := r.Cookie(m.cfg.CookieName); err == nil {
+		_ = m.store.Delete(cookie.Value)
+	}
+	now := m.now()
+	if data == nil {
+		data = make(map[string]any)
+	}
+	s := &Session{ID: newID(), Data: data, CreatedAt: now}
+	return s, m.save(w, s, now)
+}
+
+// Get returns the valid session of the request
+func (m *Manager) Get(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(m.cfg.CookieName)
+	if err != nil {
+		return nil, ErrNoSession
+	}
+	s, err := m.store.Load(cookie.Value)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrNoSession
+		}
+		return nil, fmt.Errorf("load session: %w", err)
+	}
+	if !m.now().Before(s.ExpiresAt) {
+		_ = m.store.Delete(cookie.Value)
+		return nil, ErrNoSession
+	}
+	return s, nil
+}
+
+// LoadSession returns the valid session of the request extending its sliding expiration.
+// It implements httpserver.SessionLoader.
+func (m *Manager) LoadSession(w http.ResponseWriter, r *http.Request) (any, error) {
+	s, err := m.Get(r)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	if now.Sub(s.LastSeen) > m.cfg.IdleTimeout/touchRatio {
+		if err := m.save(w, s, now); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Save stores modified session data
+func (m *Manager) Save(w http.ResponseWriter, s *Session) error {
+	return m.save(w, s, m.now())
+}
+
+// Renew changes the session ID keeping the data (e.g. on privilege change)
+func (m *Manager) Renew(w http.ResponseWriter, r *http.Request) (*Session, error) {
+	s, err := m.Get(r)
+	if err != nil {
+		return nil, err
+	}
+	if cookie, err := r.Cookie(m.cfg.CookieName); err == nil {
+		_ = m.store.Delete(cookie.Value)
+	}
+	s.ID = newID()
+	return s, m.save(w, s, m.now())
+}
+
+// Destroy deletes the session and clears the cookie (logout)
+func (m *Manager) Destroy(w http.ResponseWriter, r *http.Request) error {
+	var err error
+	if cookie, cerr := r.Cookie(m.cfg.CookieName); cerr == nil {
+		err = m.store.Delete(cookie.Value)
+	}
+	c := m.cookie("")
+	c.MaxAge = -1
+	http.SetCookie(w, c)
+	if err != nil {
+		return fmt.Errorf("delete session: %w", err)
+	}
+	return nil
+}
+
+// Run periodically removes expired sessions (if the store needs it)
+func (m *Manager) Run(ctx context.Context) error {
+	cleaner, ok := m.store.(Cleaner)
+	if !ok {
+		return nil
+	}
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			_ = cleaner.Cleanup()
+		}
+	}
+}
+
+func (m *Manager) save(w http.ResponseWriter, s *Session, now time.Time) error {
+	s.LastSeen = now
+	s.ExpiresAt = now.Add(m.cfg.IdleTimeout)
+	if absolute := s.CreatedAt.Add(m.cfg.MaxLifetime); absolute.Before(s.ExpiresAt) {
+		s.ExpiresAt = absolute
+	}
+	token, err := m.store.Save(s)
+	if err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	c := m.cookie(token)
+	c.Expires = s.ExpiresAt
+	http.SetCookie(w, c)
+	return nil
+}
+
+func (m *Manager) cookie(value string) *http.Cookie {
+	c := &http.Cookie{
+		Name:     m.cfg.CookieName,
+		Value:    value,
+		Domain:   m.cfg.Domain,
+		Path:     m.cfg.Path,
+		Secure:   !m.cfg.Insecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	switch strings.ToLower(m.cfg.SameSite) {
+	case "strict":
+		c.SameSite = http.SameSiteStrictMode
+	case "none":
+		c.SameSite = http.SameSiteNoneMode
+	}
+	return c
+}
+
+// FromContext returns the session loaded for the request (SIDRequired endpoints)
+func FromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(httpserver.ContextSessionData).(*Session)
+	return s
+}
+
+func newID() string {
+	b := make([]byte, idSize)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
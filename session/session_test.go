@@ -0,0 +1,152 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// roundTrip applies cookies set by the reply to a new request
+func roundTrip(w *httptest.ResponseRecorder) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge >= 0 {
+			req.AddCookie(c)
+		}
+	}
+	return req
+}
+
+func TestNewStore(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := NewStore(Config{Store: "postgres"})
+	a.ErrorIs(err, errUnknownStore, "postgres store requires a DB and is created in code")
+	_, err = NewStore(Config{Store: StoreCookie, Secret: "short"})
+	a.Error(err)
+}
+
+func TestManager(t *testing.T) {
+	for _, name := range []string{StoreMemory, StoreCookie} {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			store, err := NewStore(Config{Store: name, Secret: testSecret})
+			a.NoError(err)
+			m := NewManager(Config{IdleTimeout: 10 * time.Minute, MaxLifetime: time.Hour, SameSite: "strict"}, store)
+			now := time.Now()
+			m.now = func() time.Time { return now }
+
+			w := httptest.NewRecorder()
+			s, err := m.Start(w, httptest.NewRequest(http.MethodPost, "/login", nil), map[string]any{"user": "alice"})
+			a.NoError(err)
+			cookie := w.Result().Cookies()[0]
+			a.Equal("SID", cookie.Name)
+			a.True(cookie.HttpOnly)
+			a.True(cookie.Secure)
+			a.Equal(http.SameSiteStrictMode, cookie.SameSite)
+
+			req := roundTrip(w)
+			loaded, err := m.Get(req)
+			a.NoError(err)
+			a.Equal(s.ID, loaded.ID)
+			a.Equal("alice", loaded.Data["user"])
+
+			// sliding expiration
+			now = now.Add(9 * time.Minute)
+			w = httptest.NewRecorder()
+			_, err = m.LoadSession(w, req)
+			a.NoError(err)
+			req = roundTrip(w)
+			now = now.Add(9 * time.Minute)
+			_, err = m.Get(req)
+			a.NoError(err, "session is extended on access")
+			now = now.Add(11 * time.Minute)
+			_, err = m.Get(req)
+			a.ErrorIs(err, ErrNoSession, "idle timeout")
+
+			// rotation on login
+			w = httptest.NewRecorder()
+			first, err := m.Start(w, httptest.NewRequest(http.MethodPost, "/login", nil), nil)
+			a.NoError(err)
+			req = roundTrip(w)
+			w = httptest.NewRecorder()
+			renewed, err := m.Renew(w, req)
+			a.NoError(err)
+			a.NotEqual(first.ID, renewed.ID)
+			_, err = m.Get(roundTrip(w))
+			a.NoError(err)
+
+			// absolute expiration
+			req = roundTrip(w)
+			for range 7 {
+				now = now.Add(9 * time.Minute)
+				w = httptest.NewRecorder()
+				_, err = m.LoadSession(w, req)
+				if err != nil {
+					break
+				}
+				req = roundTrip(w)
+			}
+			a.ErrorIs(err, ErrNoSession, "max lifetime")
+
+			// logout
+			w = httptest.NewRecorder()
+			_, err = m.Start(w, httptest.NewRequest(http.MethodPost, "/login", nil), nil)
+			a.NoError(err)
+			req = roundTrip(w)
+			w = httptest.NewRecorder()
+			a.NoError(m.Destroy(w, req))
+			a.Equal(-1, w.Result().Cookies()[0].MaxAge)
+		})
+	}
+}
+
+func TestMemoryStoreRotation(t *testing.T) {
+	a := assert.New(t)
+
+	store := NewMemoryStore()
+	m := NewManager(Config{}, store)
+	w := httptest.NewRecorder()
+	_, err := m.Start(w, httptest.NewRequest(http.MethodPost, "/login", nil), nil)
+	a.NoError(err)
+	req := roundTrip(w)
+	w = httptest.NewRecorder()
+	_, err = m.Start(w, req, nil)
+	a.NoError(err)
+	_, err = m.Get(req)
+	a.ErrorIs(err, ErrNoSession, "previous session is destroyed on login")
+	a.Len(store.sessions, 1)
+}
+
+func TestCookieStore(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := NewCookieStore("short")
+	a.ErrorIs(err, errShortSecret)
+
+	store, err := NewCookieStore(testSecret)
+	a.NoError(err)
+	token, err := store.Save(&Session{ID: "id", Data: map[string]any{"user": "alice"}, ExpiresAt: time.Now().Add(time.Minute)})
+	a.NoError(err)
+	s, err := store.Load(token)
+	a.NoError(err)
+	a.Equal("alice", s.Data["user"])
+
+	payload, sig, _ := strings.Cut(token, ".")
+	_, err = store.Load(payload[:len(payload)-2] + "xx." + sig)
+	a.ErrorIs(err, ErrNotFound, "tampered payload")
+	other, _ := NewCookieStore(strings.Repeat("x", minSecretSize))
+	_, err = other.Load(token)
+	a.ErrorIs(err, ErrNotFound, "foreign key")
+
+	token, _ = store.Save(&Session{ID: "id", ExpiresAt: time.Now().Add(-time.Second)})
+	_, err = store.Load(token)
+	a.ErrorIs(err, ErrNotFound, "expired")
+}
@@ -0,0 +1,314 @@
+package static
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+	Static file server.
+
+	Serves files from a directory (symlinks are not allowed to escape it) or from any fs.FS (e.g. embed.FS).
+	Supports ETag/Last-Modified validation and range requests (via http.ServeContent), Cache-Control
+	rules by path pattern, precompressed .br/.gz variants and single page application fallback.
+*/
+
+var (
+	errNoStaticDir = errors.New("static dir is not set")
+	errNotFound    = errors.New("not found")
+)
+
+// CacheRule sets Cache-Control value for files matching the pattern
+type CacheRule struct {
+	Pattern string `yaml:"pattern" description:"File path pattern (path.Match syntax), matched against the full path and the base name"`
+	Value   string `yaml:"value" description:"Cache-Control header value"`
+}
+
+// Config defines static file serving parameters
+type Config struct {
+	Dir           string      `yaml:"dir" description:"Static files root directory (not used when files are embedded)"`
+	Index         string      `yaml:"index" description:"Directory index file" default:"index.html"`
+	SPA           bool        `yaml:"spa" description:"Single page application mode: serve index file for unknown paths"` //nolint:tagliatelle
+	Listing       bool        `yaml:"listing" description:"Allow directory listing"`
+	Precompressed bool        `yaml:"precompressed" description:"Serve precompressed .br/.gz files if available"`
+	CacheControl  []CacheRule `yaml:"cacheControl" description:"Cache-Control rules, first match wins"`
+	DefaultCache  string      `yaml:"defaultCache" description:"Default Cache-Control value" default:"no-cache"`
+}
+
+// Handler serves static files under the URL prefix
+type Handler struct {
+	cfg    Config
+	prefix string
+	fsys   fs.FS
+	etags  sync.Map // file name -> content hash (for files without modification time)
+}
+
+var encodings = []struct { //nolint:gochecknoglobals
+	name string
+	ext  string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// New creates static file handler. prefix is the URL path prefix to strip. If fsys is nil, files are served from cfg.Dir.
+func New(prefix string, cfg Config, fsys fs.FS) (*Handler, error) {
+	if fsys == nil {
+		if cfg.Dir == "" {
+			return nil, errNoStaticDir
+		}
+		root, err := os.OpenRoot(cfg.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("open static dir: %w", err)
+		}
+		fsys = root.FS()
+	}
+	if cfg.Index == "" {
+		cfg.Index = "index.html"
+	}
+	return &Handler{
+		cfg:    cfg,
+		prefix: "/" + strings.Trim(prefix, "/"),
+		fsys:   fsys,
+	}, nil
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, _ = h.Serve(w, r)
+}
+
+// Serve serves the requested file. The signature matches httpserver.HandlerWithResult.
+func (h *Handler) Serve(w http.ResponseWriter, r *http.Request) (int, error) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return http.StatusMethodNotAllowed, nil
+	}
+
+	if r.URL.Path == h.prefix && h.prefix != "/" { // bare prefix: serve the directory with the trailing slash
+		target := h.prefix + "/"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return http.StatusMovedPermanently, nil
+	}
+	if h.prefix != "/" && !strings.HasPrefix(r.URL.Path, h.prefix+"/") { // e.g. /staticfoo for /static
+		http.NotFound(w, r)
+		return http.StatusNotFound, nil
+	}
+
+	name, ok := h.fileName(r.URL.Path)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return http.StatusBadRequest, nil
+	}
+
+	code, err := h.serveFile(w, r, name)
+	if errors.Is(err, errNotFound) && h.cfg.SPA && path.Ext(name) == "" {
+		w.Header().Set("Cache-Control", "no-cache")
+		code, err = h.serveFile(w, r, h.cfg.Index)
+	}
+	if errors.Is(err, errNotFound) {
+		http.NotFound(w, r)
+		return http.StatusNotFound, nil
+	}
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return http.StatusInternalServerError, err
+	}
+	return code, nil
+}
+
+// fileName converts URL path into fs.FS name rejecting traversal attempts.
+func (h *Handler) fileName(urlPath string) (string, bool) {
+	rel := strings.TrimPrefix(urlPath, h.prefix)
+	if strings.Contains(rel, "\x00") || strings.Contains(rel, "\\") {
+		return "", false
+	}
+	for _, elem := range strings.Split(rel, "/") {
+		if elem == ".." {
+			return "", false
+		}
+	}
+	name := strings.TrimPrefix(path.Clean("/"+rel), "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, name string) (int, error) {
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, errNotFound
+		}
+		return 0, err //nolint:wrapcheck
+	}
+
+	if info.IsDir() {
+		index := path.Join(name, h.cfg.Index)
+		if _, err := fs.Stat(h.fsys, index); err == nil {
+			return h.serveFile(w, r, index)
+		}
+		if !h.cfg.Listing {
+			return 0, errNotFound
+		}
+		return h.listDir(w, r, name)
+	}
+
+	servedName := name
+	if h.cfg.Precompressed {
+		servedName = h.negotiateEncoding(w, r, name)
+	}
+
+	f, err := h.fsys.Open(servedName)
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+	defer f.Close()
+	servedInfo, err := f.Stat()
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		return 0, fmt.Errorf("%s: file does not implement io.Seeker", name) //nolint:err113
+	}
+
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", h.cacheControl(name))
+	}
+	etag, err := h.etag(servedName, servedInfo, content)
+	if err != nil {
+		return 0, err
+	}
+	w.Header().Set("ETag", etag)
+
+	rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+	http.ServeContent(rec, r, name, servedInfo.ModTime(), content)
+	return rec.code, nil
+}
+
+// negotiateEncoding picks a precompressed variant of the file accepted by the client.
+func (h *Handler) negotiateEncoding(w http.ResponseWriter, r *http.Request, name string) string {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if r.Header.Get("Range") != "" {
+		return name // ranges refer to the identity encoding
+	}
+	accepted := r.Header.Get("Accept-Encoding")
+	for _, enc := range encodings {
+		if !acceptsEncoding(accepted, enc.name) {
+			continue
+		}
+		if info, err := fs.Stat(h.fsys, name+enc.ext); err == nil && !info.IsDir() {
+			w.Header().Set("Content-Encoding", enc.name)
+			return name + enc.ext
+		}
+	}
+	return name
+}
+
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		token, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(token), encoding) {
+			continue
+		}
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (h *Handler) cacheControl(name string) string {
+	base := path.Base(name)
+	for _, rule := range h.cfg.CacheControl {
+		if ok, _ := path.Match(rule.Pattern, name); ok {
+			return rule.Value
+		}
+		if ok, _ := path.Match(rule.Pattern, base); ok {
+			return rule.Value
+		}
+	}
+	return h.cfg.DefaultCache
+}
+
+// etag is based on size and modification time, or on content hash if modification time is unknown (embed.FS).
+func (h *Handler) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()), nil
+	}
+	if etag, ok := h.etags.Load(name); ok {
+		return etag.(string), nil //nolint:forcetypeassert
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", fmt.Errorf("hash %s: %w", name, err)
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("seek %s: %w", name, err)
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	h.etags.Store(name, etag)
+	return etag, nil
+}
+
+func (h *Handler) listDir(w http.ResponseWriter, r *http.Request, name string) (int, error) {
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		return http.StatusMovedPermanently, nil
+	}
+	entries, err := fs.ReadDir(h.fsys, name)
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+	var sb strings.Builder
+	sb.WriteString("<!doctype html>\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		sb.WriteString(`<a href="` + html.EscapeString(entryName) + `">` + html.EscapeString(entryName) + "</a>\n")
+	}
+	sb.WriteString("</pre>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, sb.String())
+	return http.StatusOK, nil
+}
+
+// statusRecorder captures the status code written by http.ServeContent
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.code = code
+	s.ResponseWriter.WriteHeader(code)
+}
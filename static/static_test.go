@@ -0,0 +1,110 @@
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testHandler(t *testing.T, cfg Config) *Handler {
+	fsys := fstest.MapFS{
+		"index.html":     {Data: []byte("<html>index</html>")},
+		"app.js":         {Data: []byte("console.log('hello')")},
+		"app.js.gz":      {Data: []byte("gzipped")},
+		"assets/a.css":   {Data: []byte("body{}")},
+		"assets/b.css":   {Data: []byte("p{}")},
+		"docs/readme.md": {Data: []byte("readme")},
+	}
+	h, err := New("/static/", cfg, fsys)
+	assert.NoError(t, err)
+	return h
+}
+
+func serve(h *Handler, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeFile(t *testing.T) {
+	a := assert.New(t)
+	h := testHandler(t, Config{
+		DefaultCache: "no-cache",
+		CacheControl: []CacheRule{{Pattern: "assets/*", Value: "max-age=31536000, immutable"}},
+	})
+
+	rec := serve(h, "GET", "/static/app.js", nil)
+	a.Equal(http.StatusOK, rec.Code)
+	a.Equal("console.log('hello')", rec.Body.String())
+	a.Equal("no-cache", rec.Header().Get("Cache-Control"))
+	a.Contains(rec.Header().Get("Content-Type"), "javascript")
+	etag := rec.Header().Get("ETag")
+	a.NotEmpty(etag)
+
+	rec = serve(h, "GET", "/static/app.js", map[string]string{"If-None-Match": etag})
+	a.Equal(http.StatusNotModified, rec.Code)
+
+	rec = serve(h, "GET", "/static/app.js", map[string]string{"Range": "bytes=0-6"})
+	a.Equal(http.StatusPartialContent, rec.Code)
+	a.Equal("console", rec.Body.String())
+
+	rec = serve(h, "GET", "/static/assets/a.css", nil)
+	a.Equal("max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+
+	rec = serve(h, "GET", "/static/", nil)
+	a.Equal("<html>index</html>", rec.Body.String())
+}
+
+func TestPrecompressed(t *testing.T) {
+	a := assert.New(t)
+	h := testHandler(t, Config{Precompressed: true})
+
+	rec := serve(h, "GET", "/static/app.js", map[string]string{"Accept-Encoding": "br, gzip"})
+	a.Equal("gzip", rec.Header().Get("Content-Encoding"))
+	a.Equal("gzipped", rec.Body.String())
+	a.Contains(rec.Header().Get("Content-Type"), "javascript")
+	a.Equal("Accept-Encoding", rec.Header().Get("Vary"))
+
+	rec = serve(h, "GET", "/static/app.js", map[string]string{"Accept-Encoding": "gzip;q=0"})
+	a.Empty(rec.Header().Get("Content-Encoding"))
+	a.Equal("console.log('hello')", rec.Body.String())
+}
+
+func TestNotFoundAndTraversal(t *testing.T) {
+	a := assert.New(t)
+	h := testHandler(t, Config{})
+
+	a.Equal(http.StatusNotFound, serve(h, "GET", "/static/missing", nil).Code)
+	a.Equal(http.StatusNotFound, serve(h, "GET", "/static/docs/", nil).Code) // listing is off
+	a.Equal(http.StatusBadRequest, serve(h, "GET", "/static/../secret", nil).Code)
+	a.Equal(http.StatusBadRequest, serve(h, "GET", "/static/..%5csecret", nil).Code)
+	a.Equal(http.StatusMethodNotAllowed, serve(h, "POST", "/static/app.js", nil).Code)
+	a.Equal(http.StatusNotFound, serve(h, "GET", "/staticassets/a.css", nil).Code, "prefix ends at a path segment")
+	rec := serve(h, "GET", "/static?v=1", nil)
+	a.Equal(http.StatusMovedPermanently, rec.Code)
+	a.Equal("/static/?v=1", rec.Header().Get("Location"))
+
+	h = testHandler(t, Config{Listing: true})
+	rec = serve(h, "GET", "/static/docs/", nil)
+	a.Equal(http.StatusOK, rec.Code)
+	a.Contains(rec.Body.String(), `<a href="readme.md">`)
+}
+
+func TestSPA(t *testing.T) {
+	a := assert.New(t)
+	h := testHandler(t, Config{SPA: true})
+
+	rec := serve(h, "GET", "/static/users/42", nil)
+	a.Equal(http.StatusOK, rec.Code)
+	a.Equal("<html>index</html>", rec.Body.String())
+	a.Equal("no-cache", rec.Header().Get("Cache-Control"))
+
+	a.Equal(http.StatusNotFound, serve(h, "GET", "/static/missing.js", nil).Code)
+}
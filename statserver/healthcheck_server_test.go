@@ -2,12 +2,16 @@ package statserver
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/bhmj/goblocks/apiauth/token"
 	"github.com/bhmj/goblocks/appstatus"
 	"github.com/bhmj/goblocks/log"
 	"github.com/stretchr/testify/assert"
@@ -81,6 +85,41 @@ func TestHealthcheckServerContextShutdown(t *testing.T) {
 	a.False(getAlive(port)) // server is stopped
 }
 
+func TestLogLevelEndpoint(t *testing.T) {
+	a := assert.New(t)
+
+	logger, _ := log.New("info", false)
+	server := New(0, logger, appstatus.New(), http.NewServeMux())
+	server.HandleLogLevels(log.LevelsOf(logger), token.New("secret"))
+	handler := server.(*statServer).router
+
+	call := func(method, body, tok string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/loglevel", strings.NewReader(body))
+		req.Header.Set("Api-Token", tok)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	a.Equal(http.StatusUnauthorized, call(http.MethodGet, "", "wrong").Code)
+
+	w := call(http.MethodPut, `{"module":"dbase","level":"debug"}`, "secret")
+	a.Equal(http.StatusOK, w.Code)
+	var state log.LevelsState
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &state))
+	a.Equal("info", state.Level)
+	a.Equal("debug", state.Modules["dbase"].Level)
+
+	w = call(http.MethodPut, `{"level":"debug","duration":"10m"}`, "secret")
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &state))
+	a.Equal("debug", state.Escalated)
+	a.NotNil(state.Until)
+
+	a.Equal(http.StatusBadRequest, call(http.MethodPut, `{"level":"loud"}`, "secret").Code)
+	a.Equal(http.StatusOK, call(http.MethodGet, "", "secret").Code)
+}
+
 func getFreeTCPPorts(n int) []int {
 	var ports []int
 	for port := 10000; port < 65535; port++ {
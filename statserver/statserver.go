@@ -2,6 +2,7 @@ package statserver
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -9,9 +10,13 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/bhmj/goblocks/apiauth"
+	"github.com/bhmj/goblocks/httpreply"
 	"github.com/bhmj/goblocks/log"
 )
 
+const maxRequestSize = 4096
+
 type AppStatus interface {
 	IsReady() bool
 	IsAlive() bool
@@ -19,6 +24,7 @@ type AppStatus interface {
 
 type statServer struct {
 	server    *http.Server
+	router    *http.ServeMux
 	appStatus AppStatus
 	logger    log.MetaLogger
 	port      int
@@ -27,11 +33,21 @@ type statServer struct {
 type Server interface {
 	Run(ctx context.Context) error
 	Shutdown(ctx context.Context) error
+	HandleLogLevels(levels *log.Levels, auth apiauth.Auth)
+}
+
+// logLevelRequest changes the level of a module (root logger if empty); with duration the change is temporary
+type logLevelRequest struct {
+	Module   string `json:"module"`
+	Level    string `json:"level"`
+	Duration string `json:"duration"`
 }
 
+var errNoLevels = errors.New("logger does not support runtime levels")
+
 func New(port int, logger log.MetaLogger, appStatus AppStatus, promHandler http.Handler) Server {
-	result := &statServer{appStatus: appStatus, logger: logger, port: port}
 	router := http.NewServeMux()
+	result := &statServer{appStatus: appStatus, logger: logger, port: port, router: router}
 	router.HandleFunc("GET /ready", result.ReadyHandler)
 	router.HandleFunc("GET /alive", result.AliveHandler)
 	router.Handle("GET /metrics", promHandler)
@@ -100,3 +116,46 @@ func (s *statServer) AliveHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
+
+// HandleLogLevels adds GET/PUT /loglevel endpoints authenticated by auth
+func (s *statServer) HandleLogLevels(levels *log.Levels, auth apiauth.Auth) {
+	authorized := func(next func(w http.ResponseWriter, r *http.Request) (int, error)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if err := auth.Authorized(r); err != nil {
+				_, _ = httpreply.Error(w, fmt.Errorf("unauthorized: %w", err), http.StatusUnauthorized)
+				return
+			}
+			if levels == nil {
+				_, _ = httpreply.Error(w, errNoLevels, http.StatusNotImplemented)
+				return
+			}
+			if code, err := next(w, r); err != nil {
+				_, _ = httpreply.Error(w, err, code)
+			}
+		}
+	}
+	s.router.HandleFunc("GET /loglevel", authorized(func(w http.ResponseWriter, _ *http.Request) (int, error) {
+		return httpreply.Object(w, levels.State())
+	}))
+	s.router.HandleFunc("PUT /loglevel", authorized(func(w http.ResponseWriter, r *http.Request) (int, error) {
+		var req logLevelRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestSize)).Decode(&req); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("decode request: %w", err)
+		}
+		var err error
+		if req.Duration != "" {
+			var duration time.Duration
+			if duration, err = time.ParseDuration(req.Duration); err != nil {
+				return http.StatusBadRequest, fmt.Errorf("duration: %w", err)
+			}
+			err = levels.Escalate(req.Module, req.Level, duration)
+		} else {
+			err = levels.SetLevel(req.Module, req.Level)
+		}
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		s.logger.Warn("log level changed", log.String("module", req.Module), log.String("level", req.Level), log.String("duration", req.Duration))
+		return httpreply.Object(w, levels.State())
+	}))
+}
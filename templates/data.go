@@ -0,0 +1,32 @@
+package templates
+
+import (
+	"net/http"
+
+	"github.com/bhmj/goblocks/httpserver"
+)
+
+// PageData is a template data envelope carrying request-scoped values along with the handler data.
+// In templates: {{.Data.Title}}, {{.RequestID}}, {{.CSRFToken}}, <script nonce="{{.CSPNonce}}">, etc.
+type PageData struct {
+	Data      any
+	Session   any
+	RequestID string
+	CSRFToken string
+	CSPNonce  string
+}
+
+// NewPageData wraps handler data with the values the framework middlewares put into request context.
+func NewPageData(r *http.Request, data any) PageData {
+	ctx := r.Context()
+	requestID, _ := ctx.Value(httpserver.ContextRequestID).(string)
+	csrfToken, _ := ctx.Value(httpserver.ContextCSRFToken).(string)
+	cspNonce, _ := ctx.Value(httpserver.ContextCSPNonce).(string)
+	return PageData{
+		Data:      data,
+		Session:   ctx.Value(httpserver.ContextSessionData),
+		RequestID: requestID,
+		CSRFToken: csrfToken,
+		CSPNonce:  cspNonce,
+	}
+}
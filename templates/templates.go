@@ -0,0 +1,253 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+	HTML template renderer.
+
+	Directory layout (relative to the templates root):
+
+		layouts/*.html   -- layouts, available in every page by file name ("base")
+		partials/*.html  -- partials, available in every page by path without extension
+		                    relative to partials/ ("header", "forms/input")
+		other *.html     -- pages, addressed by path without extension ("index", "users/list")
+
+	A page which defines a "content" template is rendered through the layout
+	(Config.Layout), otherwise the page itself is executed.
+*/
+
+const (
+	contentBlock    = "content"
+	defaultExt      = ".html"
+	defaultLayouts  = "layouts"
+	defaultPartials = "partials"
+	defaultLayout   = "base"
+)
+
+var (
+	errTemplateNotFound = errors.New("template not found")
+	errNoTemplatesDir   = errors.New("templates dir is not set")
+)
+
+// Config defines template loading parameters
+type Config struct {
+	Dir      string `yaml:"dir" description:"Templates root directory (not used when templates are embedded)"`
+	Ext      string `yaml:"ext" description:"Template file extension" default:".html"`
+	Layouts  string `yaml:"layouts" description:"Layouts subdirectory" default:"layouts"`
+	Partials string `yaml:"partials" description:"Partials subdirectory" default:"partials"`
+	Layout   string `yaml:"layout" description:"Default layout name" default:"base"`
+}
+
+// Renderer holds parsed template sets, one per page
+type Renderer struct {
+	cfg    Config
+	fsys   fs.FS
+	funcs  template.FuncMap
+	reload bool
+
+	mu    sync.RWMutex
+	pages map[string]*template.Template
+	stamp string // source files fingerprint, used for hot reload
+}
+
+// New creates a renderer and parses all templates. If fsys is nil, templates are read from cfg.Dir.
+// With reload set, the sources are checked for changes on every render (use in non-production mode).
+func New(cfg Config, fsys fs.FS, funcs template.FuncMap, reload bool) (*Renderer, error) {
+	if fsys == nil {
+		if cfg.Dir == "" {
+			return nil, errNoTemplatesDir
+		}
+		fsys = os.DirFS(cfg.Dir)
+	}
+	cfg.Ext = orDefault(cfg.Ext, defaultExt)
+	if !strings.HasPrefix(cfg.Ext, ".") {
+		cfg.Ext = "." + cfg.Ext
+	}
+	cfg.Layouts = orDefault(strings.Trim(cfg.Layouts, "/"), defaultLayouts)
+	cfg.Partials = orDefault(strings.Trim(cfg.Partials, "/"), defaultPartials)
+	cfg.Layout = orDefault(cfg.Layout, defaultLayout)
+	allFuncs := defaultFuncs()
+	for name, fn := range funcs {
+		allFuncs[name] = fn
+	}
+	r := &Renderer{
+		cfg:    cfg,
+		fsys:   fsys,
+		funcs:  allFuncs,
+		reload: reload,
+	}
+	if err := r.Load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Render executes the named page template with data.
+func (r *Renderer) Render(w io.Writer, name string, data any) error {
+	if r.reload {
+		if err := r.reloadIfChanged(); err != nil {
+			return err
+		}
+	}
+
+	r.mu.RLock()
+	page, found := r.pages[name]
+	r.mu.RUnlock()
+	if !found {
+		return fmt.Errorf("%w: %s", errTemplateNotFound, name)
+	}
+
+	entry := name
+	if page.Lookup(r.cfg.Layout) != nil && definesContent(page, name) {
+		entry = r.cfg.Layout
+	}
+	return page.ExecuteTemplate(w, entry, data) //nolint:wrapcheck
+}
+
+// Load (re)parses all templates.
+func (r *Renderer) Load() error {
+	files, stamp, err := r.scan()
+	if err != nil {
+		return err
+	}
+
+	base := template.New("").Funcs(r.funcs)
+	var pageFiles []string
+	for _, fname := range files {
+		name, shared := r.sharedName(fname)
+		if !shared {
+			pageFiles = append(pageFiles, fname)
+			continue
+		}
+		if err := r.parseFile(base, name, fname); err != nil {
+			return err
+		}
+	}
+
+	pages := make(map[string]*template.Template, len(pageFiles))
+	for _, fname := range pageFiles {
+		set, err := base.Clone()
+		if err != nil {
+			return fmt.Errorf("clone base templates: %w", err)
+		}
+		name := strings.TrimSuffix(fname, r.cfg.Ext)
+		if err := r.parseFile(set, name, fname); err != nil {
+			return err
+		}
+		pages[name] = set
+	}
+
+	r.mu.Lock()
+	r.pages = pages
+	r.stamp = stamp
+	r.mu.Unlock()
+	return nil
+}
+
+// sharedName returns the template name of a layout or partial file (including ones in subdirectories)
+func (r *Renderer) sharedName(fname string) (string, bool) {
+	for _, dir := range []string{r.cfg.Layouts, r.cfg.Partials} {
+		if rel, found := strings.CutPrefix(fname, dir+"/"); found {
+			return strings.TrimSuffix(rel, r.cfg.Ext), true
+		}
+	}
+	return "", false
+}
+
+func (r *Renderer) parseFile(set *template.Template, name, fname string) error {
+	content, err := fs.ReadFile(r.fsys, fname)
+	if err != nil {
+		return fmt.Errorf("read template %s: %w", fname, err)
+	}
+	if _, err := set.New(name).Parse(string(content)); err != nil {
+		return fmt.Errorf("parse template %s: %w", fname, err)
+	}
+	return nil
+}
+
+// scan returns the list of template files along with their fingerprint (names, sizes and mtimes).
+func (r *Renderer) scan() ([]string, string, error) {
+	var files []string
+	var stamp strings.Builder
+	err := fs.WalkDir(r.fsys, ".", func(fname string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(fname) != r.cfg.Ext {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+		files = append(files, fname)
+		stamp.WriteString(fname + ":" + strconv.FormatInt(info.Size(), 10) + ":" + strconv.FormatInt(info.ModTime().UnixNano(), 10) + ";")
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("scan templates: %w", err)
+	}
+	return files, stamp.String(), nil
+}
+
+func (r *Renderer) reloadIfChanged() error {
+	_, stamp, err := r.scan()
+	if err != nil {
+		return err
+	}
+	r.mu.RLock()
+	changed := stamp != r.stamp
+	r.mu.RUnlock()
+	if changed {
+		return r.Load()
+	}
+	return nil
+}
+
+// definesContent reports whether the page source itself (not the layout) defines the content block.
+func definesContent(page *template.Template, name string) bool {
+	content := page.Lookup(contentBlock)
+	return content != nil && content.Tree != nil && content.Tree.ParseName == name
+}
+
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+func defaultFuncs() template.FuncMap {
+	return template.FuncMap{
+		"safeHTML": func(s string) template.HTML { return template.HTML(s) }, //nolint:gosec
+		"safeURL":  func(s string) template.URL { return template.URL(s) },   //nolint:gosec
+		"dict":     dict,
+	}
+}
+
+// dict builds a map from key/value pairs, handy for passing several values to a partial.
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict: odd number of arguments") //nolint:err113
+	}
+	m := make(map[string]any, len(pairs)/2) //nolint:mnd
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: key %v is not a string", pairs[i]) //nolint:err113
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
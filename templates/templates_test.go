@@ -0,0 +1,96 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/bhmj/goblocks/httpserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/base.html":    {Data: []byte(`<html>{{template "header" .}}<body>{{block "content" .}}default{{end}}</body></html>`)},
+		"partials/header.html": {Data: []byte(`<title>{{.Data.Title}}</title>`)},
+		"index.html":           {Data: []byte(`{{define "content"}}<p>{{.Data.Text}}</p>{{end}}`)},
+		"users/list.html":      {Data: []byte(`{{define "content"}}{{range .Data.Users}}<i>{{upper .}}</i>{{end}}{{end}}`)},
+		"plain.html":           {Data: []byte(`plain {{.}}`)},
+	}
+}
+
+func TestRenderLayout(t *testing.T) {
+	a := assert.New(t)
+
+	funcs := template.FuncMap{"upper": strings.ToUpper}
+	r, err := New(Config{Ext: ".html", Layouts: "layouts", Partials: "partials", Layout: "base"}, testFS(), funcs, false)
+	a.NoError(err)
+
+	var buf bytes.Buffer
+	data := PageData{Data: map[string]any{"Title": "Main", "Text": "<hello>"}}
+	a.NoError(r.Render(&buf, "index", data))
+	a.Equal(`<html><title>Main</title><body><p>&lt;hello&gt;</p></body></html>`, buf.String())
+
+	buf.Reset()
+	data = PageData{Data: map[string]any{"Title": "Users", "Users": []string{"ann", "bob"}}}
+	a.NoError(r.Render(&buf, "users/list", data))
+	a.Equal(`<html><title>Users</title><body><i>ANN</i><i>BOB</i></body></html>`, buf.String())
+
+	buf.Reset()
+	a.NoError(r.Render(&buf, "plain", "text"))
+	a.Equal(`plain text`, buf.String())
+
+	a.ErrorIs(r.Render(&buf, "missing", nil), errTemplateNotFound)
+}
+
+func TestDefaultConfig(t *testing.T) {
+	a := assert.New(t)
+
+	fsys := testFS()
+	fsys["partials/forms/input.html"] = &fstest.MapFile{Data: []byte(`<input name="{{.}}">`)}
+	fsys["form.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{template "forms/input" "q"}}{{end}}`)}
+	r, err := New(Config{}, fsys, template.FuncMap{"upper": strings.ToUpper}, false)
+	a.NoError(err)
+
+	var buf bytes.Buffer
+	a.NoError(r.Render(&buf, "form", PageData{Data: map[string]any{"Title": "Search"}}))
+	a.Equal(`<html><title>Search</title><body><input name="q"></body></html>`, buf.String())
+	a.ErrorIs(r.Render(&buf, "partials/forms/input", nil), errTemplateNotFound, "nested partial is not a page")
+}
+
+func TestReload(t *testing.T) {
+	a := assert.New(t)
+
+	fsys := testFS()
+	r, err := New(Config{Ext: ".html", Layouts: "layouts", Partials: "partials", Layout: "base"}, fsys, template.FuncMap{"upper": strings.ToUpper}, true)
+	a.NoError(err)
+
+	var buf bytes.Buffer
+	a.NoError(r.Render(&buf, "plain", "one"))
+	a.Equal(`plain one`, buf.String())
+
+	fsys["plain.html"] = &fstest.MapFile{Data: []byte(`changed {{.}}`), ModTime: time.Now()}
+
+	buf.Reset()
+	a.NoError(r.Render(&buf, "plain", "two"))
+	a.Equal(`changed two`, buf.String())
+}
+
+func TestPageData(t *testing.T) {
+	a := assert.New(t)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	ctx := context.WithValue(req.Context(), httpserver.ContextRequestID, "rid-1")
+	ctx = context.WithValue(ctx, httpserver.ContextSessionData, "session")
+	data := NewPageData(req.WithContext(ctx), 42)
+
+	a.Equal(42, data.Data)
+	a.Equal("rid-1", data.RequestID)
+	a.Equal("session", data.Session)
+	a.Empty(data.CSRFToken)
+}
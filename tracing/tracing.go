@@ -0,0 +1,220 @@
+// Package tracing configures OpenTelemetry tracing and provides span helpers for server, client and DB calls.
+// All helpers are no-op unless tracing is initialized with Init.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bhmj/goblocks/correlation"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterOTLPHTTP = "otlp-http"
+	ExporterOTLPGRPC = "otlp-grpc"
+	ExporterStdout   = "stdout"
+
+	instrumentationName = "github.com/bhmj/goblocks"
+	maxQueryLength      = 2048
+)
+
+var (
+	errUnknownExporter = errors.New("unknown trace exporter")
+
+	enabled atomic.Bool //nolint:gochecknoglobals
+)
+
+// Config defines tracing parameters
+type Config struct {
+	Enabled     bool              `yaml:"enabled" description:"Enable OpenTelemetry tracing"`
+	Exporter    string            `yaml:"exporter" description:"Span exporter" default:"otlp-http" choices:"otlp-http,otlp-grpc,stdout"`
+	Endpoint    string            `yaml:"endpoint" description:"OTLP collector host:port (OTEL_EXPORTER_OTLP_ENDPOINT if empty)"`
+	Insecure    bool              `yaml:"insecure" description:"Plain-text connection to the OTLP collector"`
+	Headers     map[string]string `yaml:"headers" description:"Headers sent to the OTLP collector (e.g. auth)"`
+	SampleRatio float64           `yaml:"sampleRatio" description:"Ratio of sampled new traces (caller's decision is respected)" default:"1"`
+	ServiceName string            `yaml:"serviceName" description:"Service name reported to tracing backend (application name if empty)"`
+}
+
+// Init installs the global tracer provider and W3C propagators. The returned function flushes and stops the exporter.
+func Init(ctx context.Context, cfg Config, serviceName, version string) (func(context.Context) error, error) {
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ServiceName != "" {
+		serviceName = cfg.ServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	enabled.Store(true)
+	return func(ctx context.Context) error {
+		enabled.Store(false)
+		return provider.Shutdown(ctx) //nolint:wrapcheck
+	}, nil
+}
+
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch cfg.Exporter {
+	case "", ExporterOTLPHTTP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithHeaders(cfg.Headers)}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case ExporterOTLPGRPC:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithHeaders(cfg.Headers)}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownExporter, cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("trace exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// Enabled reports whether tracing is initialized
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Tracer returns the goblocks tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// StartServerSpan continues the trace of the incoming request (traceparent) in a server span named after the endpoint
+func StartServerSpan(r *http.Request, name, route string) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return Tracer().Start(ctx, name, //nolint:spancheck
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path),
+			semconv.HTTPRoute(route),
+			semconv.ClientAddress(r.RemoteAddr),
+			semconv.UserAgentOriginal(r.UserAgent()),
+		),
+	)
+}
+
+// EndServerSpan records the reply status and ends the span
+func EndServerSpan(span trace.Span, code int, err error) {
+	if code != 0 {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(code))
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	if code >= http.StatusInternalServerError || (code == 0 && err != nil) {
+		span.SetStatus(codes.Error, http.StatusText(code))
+	}
+	span.End()
+}
+
+// StartClientSpan starts a client span for the outbound request and injects trace context into its headers.
+// The returned function ends the span. If tracing is off, the request is returned as is.
+func StartClientSpan(req *http.Request) (*http.Request, func(*http.Response, error)) {
+	if !Enabled() {
+		return req, func(*http.Response, error) {}
+	}
+	ctx, span := Tracer().Start(req.Context(), "HTTP "+req.Method, //nolint:spancheck
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.Redacted()),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req, func(resp *http.Response, err error) {
+		if resp != nil {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+			if resp.StatusCode >= http.StatusBadRequest {
+				span.SetStatus(codes.Error, resp.Status)
+			}
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// StartDBSpan starts a client span for a DB query. The returned function ends the span.
+func StartDBSpan(ctx context.Context, system attribute.KeyValue, query string) (context.Context, func(error)) {
+	if !Enabled() {
+		return ctx, func(error) {}
+	}
+	operation, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	operation = strings.ToUpper(operation)
+	if len(query) > maxQueryLength {
+		query = query[:maxQueryLength]
+	}
+	ctx, span := Tracer().Start(ctx, operation, //nolint:spancheck
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(system, semconv.DBOperationName(operation), semconv.DBQueryText(query)),
+	)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// Correlation converts span context to correlation trace context (used in log fields and outbound calls)
+func Correlation(sc trace.SpanContext) correlation.TraceContext {
+	return correlation.TraceContext{
+		TraceID: sc.TraceID(),
+		SpanID:  sc.SpanID(),
+		Flags:   byte(sc.TraceFlags()),
+		State:   sc.TraceState().String(),
+	}
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	enabled.Store(true)
+	t.Cleanup(func() { enabled.Store(false) })
+	return recorder
+}
+
+func TestSpans(t *testing.T) {
+	a := assert.New(t)
+	recorder := setupRecorder(t)
+
+	var outbound http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outbound = r.Header
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, server := StartServerSpan(req, "shop/order", "/orders/{id}")
+	a.Equal("4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	a.Equal("4bf92f3577b34da6a3ce929d0e0e4736", Correlation(server.SpanContext()).TraceIDString())
+
+	// client span
+	out, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	out, end := StartClientSpan(out)
+	resp, err := http.DefaultClient.Do(out)
+	a.NoError(err)
+	resp.Body.Close()
+	end(resp, err)
+
+	// DB span
+	_, endDB := StartDBSpan(ctx, semconv.DBSystemNamePostgreSQL, "select 1")
+	endDB(errors.New("boom"))
+
+	EndServerSpan(server, http.StatusInternalServerError, errors.New("failed"))
+
+	spans := recorder.Ended()
+	a.Len(spans, 3)
+	client, db, srvSpan := spans[0], spans[1], spans[2]
+	a.Equal("HTTP GET", client.Name())
+	a.Equal(trace.SpanKindClient, client.SpanKind())
+	a.Equal(srvSpan.SpanContext().SpanID(), client.Parent().SpanID())
+	a.Contains(outbound.Get("Traceparent"), client.SpanContext().SpanID().String(), "client span is the parent of the callee")
+	a.Equal(codes.Error, client.Status().Code, "4xx reply")
+
+	a.Equal("SELECT", db.Name())
+	a.Equal(codes.Error, db.Status().Code)
+	a.Equal(srvSpan.SpanContext().SpanID(), db.Parent().SpanID())
+
+	a.Equal("shop/order", srvSpan.Name())
+	a.Equal(trace.SpanKindServer, srvSpan.SpanKind())
+	a.True(srvSpan.Parent().IsRemote())
+	a.Equal(codes.Error, srvSpan.Status().Code)
+}
+
+func TestDisabled(t *testing.T) {
+	a := assert.New(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	out, end := StartClientSpan(req)
+	a.Same(req, out)
+	end(nil, nil)
+	ctx, endDB := StartDBSpan(context.Background(), semconv.DBSystemNamePostgreSQL, "select 1")
+	a.Equal(context.Background(), ctx)
+	endDB(nil)
+
+	_, err := newExporter(context.Background(), Config{Exporter: "zipkin"})
+	a.ErrorIs(err, errUnknownExporter)
+}
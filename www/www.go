@@ -14,7 +14,9 @@ import (
 	"slices"
 	"time"
 
+	"github.com/bhmj/goblocks/correlation"
 	"github.com/bhmj/goblocks/file"
+	"github.com/bhmj/goblocks/tracing"
 )
 
 var client *http.Client //nolint:gochecknoglobals
@@ -107,10 +109,22 @@ func FetchContent(url string, opts ...RequestOpt) ([]byte, string, *url.URL, int
 	return buf.Bytes(), contentType, newURL, fileSize, err
 }
 
+// FetchContentContext is FetchContent propagating request ID and trace context of ctx (see correlation package).
+func FetchContentContext(ctx context.Context, url string, opts ...RequestOpt) ([]byte, string, *url.URL, int64, error) {
+	buf := &bytes.Buffer{}
+	contentType, newURL, fileSize, err := FetchContext(ctx, url, "", "", "", buf, opts...)
+	return buf.Bytes(), contentType, newURL, fileSize, err
+}
+
 // Fetch downloads a file specified in uri, saves it to root+path+fname (if fname specified), copies the body content into buf
 // (if buf specified) and returns newURL if redirect occurred.
 func Fetch(url, root, path, fname string, buf io.Writer, opts ...RequestOpt) (contentType string, newURL *url.URL, fileSize int64, err error) {
-	body, contentType, newURL, err := getResponse(url, opts...)
+	return FetchContext(context.Background(), url, root, path, fname, buf, opts...)
+}
+
+// FetchContext is Fetch propagating request ID and trace context of ctx (see correlation package).
+func FetchContext(ctx context.Context, url, root, path, fname string, buf io.Writer, opts ...RequestOpt) (contentType string, newURL *url.URL, fileSize int64, err error) {
+	body, contentType, newURL, err := getResponse(ctx, url, opts...)
 	if err != nil {
 		return //nolint:nakedret
 	}
@@ -212,13 +226,16 @@ func redirectURL(response *http.Response) *url.URL {
 	return nil
 }
 
-func doRequest(uri string) (*http.Response, error) {
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, uri, nil)
+func doRequest(ctx context.Context, uri string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
 	if err != nil {
 		return nil, err
 	}
 	setHeaders(req)
+	req, end := tracing.StartClientSpan(req)
+	correlation.Inject(ctx, req.Header)
 	response, err := client.Do(req)
+	end(response, err)
 	if err != nil {
 		if os.IsTimeout(err) {
 			return nil, &timeoutError{}
@@ -229,8 +246,8 @@ func doRequest(uri string) (*http.Response, error) {
 }
 
 // getResponse
-func getResponse(uri string, opts ...RequestOpt) (io.ReadCloser, string, *url.URL, error) {
-	response, err := doRequest(uri)
+func getResponse(ctx context.Context, uri string, opts ...RequestOpt) (io.ReadCloser, string, *url.URL, error) {
+	response, err := doRequest(ctx, uri)
 	if err != nil {
 		return nil, "", nil, err
 	}